@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"image/color"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// # Animation
+//
+// In animated mode, the SVG draws itself: the glyphs and word lines appear
+// one after the other, in reading order (words from right to left, the
+// word line first, and then the glyphs of each syllable from top to bottom),
+// which is the order in which wordsToSVG writes them.
+//
+// To do that, the words are converted to a scene (see parseScene), and
+// written back as plain paths, with a CSS animation of the
+// stroke-dashoffset for each of them. Using pathLength="1", the dashes are
+// the same for all the paths regardless of their length. The filled parts
+// fade in instead. Gradients (like the ones of the effects) are painted
+// with their average color, as in the scene.
+//
+// The animation only changes the initial state, so without CSS animations
+// (or when the user prefers reduced motion, or in the other output formats)
+// the image is shown complete.
+
+type animation struct {
+	enabled bool
+
+	// How long it takes to draw each glyph.
+	glyphTime time.Duration
+
+	// Total time to draw all the glyphs. If set, it takes precedence over
+	// glyphTime.
+	duration time.Duration
+
+	// Repeat the animation forever, with the given pause after each time.
+	loop  bool
+	pause time.Duration
+}
+
+const (
+	defaultGlyphTime = 300 * time.Millisecond
+	defaultPause     = time.Second
+
+	// Limits, so the values make some sense.
+	maxGlyphTime = 10 * time.Second
+	maxDuration  = 5 * time.Minute
+	maxPause     = time.Minute
+)
+
+var (
+	animateFlag = flag.Bool("animate", false,
+		"animate the SVG, drawing the glyphs one by one in reading order")
+	glyphTimeFlag = flag.Duration("glyph-time", defaultGlyphTime,
+		"in animations, how long it takes to draw each glyph")
+	durationFlag = flag.Duration("duration", 0,
+		"in animations, total time to draw all the glyphs; overrides "+
+			"-glyph-time")
+	loopFlag = flag.Bool("loop", false,
+		"in animations, repeat forever")
+	pauseFlag = flag.Duration("pause", defaultPause,
+		"in looping animations, pause before repeating")
+)
+
+// animationFromFlags returns the animation options given in the command
+// line.
+func animationFromFlags() animation {
+	return animation{
+		enabled:   *animateFlag,
+		glyphTime: *glyphTimeFlag,
+		duration:  *durationFlag,
+		loop:      *loopFlag,
+		pause:     *pauseFlag,
+	}
+}
+
+// applyValues overrides the animation options with the ones given as URL
+// values, with the same names as the flags.
+func (a *animation) applyValues(v url.Values) error {
+	if v.Has("animate") {
+		a.enabled = isTrue(v.Get("animate"))
+	}
+	if v.Has("loop") {
+		a.loop = isTrue(v.Get("loop"))
+	}
+	for name, dst := range map[string]*time.Duration{
+		"glyph-time": &a.glyphTime,
+		"duration":   &a.duration,
+		"pause":      &a.pause,
+	} {
+		if s := v.Get(name); s != "" {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return fmt.Errorf("invalid %s %q (e.g. 300ms, 2s)", name, s)
+			}
+			*dst = d
+		}
+	}
+	return nil
+}
+
+// check that the animation options are valid.
+func (a animation) check() error {
+	switch {
+	case a.glyphTime < 0 || a.glyphTime > maxGlyphTime:
+		return fmt.Errorf("glyph-time %v out of range, must be up to %v",
+			a.glyphTime, maxGlyphTime)
+	case a.duration < 0 || a.duration > maxDuration:
+		return fmt.Errorf("duration %v out of range, must be up to %v",
+			a.duration, maxDuration)
+	case a.pause < 0 || a.pause > maxPause:
+		return fmt.Errorf("pause %v out of range, must be up to %v",
+			a.pause, maxPause)
+	}
+	return nil
+}
+
+// animateSVG returns the animated version of the words SVG (as returned by
+// wordsToSVG).
+func animateSVG(wsvg SVG, width, height int, a animation) (SVG, error) {
+	sc, err := wordsScene(wsvg, width, height)
+	if err != nil {
+		return "", err
+	}
+	steps := animationSteps(sc)
+	if len(steps) == 0 {
+		return wsvg, nil
+	}
+
+	step := a.stepTime(len(steps))
+	total := step * time.Duration(len(steps))
+	cycle := total
+	repeat := "1"
+	if a.loop {
+		cycle += a.pause
+		repeat = "infinite"
+	}
+	pct := func(d time.Duration) string {
+		return trimZeros(strconv.FormatFloat(
+			100*float64(d)/float64(cycle), 'f', 2, 64))
+	}
+
+	css := &strings.Builder{}
+	elems := SVG("")
+	for i, shapes := range steps {
+		start, end := step*time.Duration(i), step*time.Duration(i+1)
+		hidden := "0%"
+		if start > 0 {
+			hidden += ", " + pct(start) + "%"
+		}
+		fmt.Fprintf(css, "@keyframes anim-%d {\n"+
+			"  %s { stroke-dashoffset: 1; fill-opacity: 0; }\n"+
+			"  %s%%, 100%% { stroke-dashoffset: 0; fill-opacity: 1; }\n"+
+			"}\n", i, hidden, pct(end))
+		fmt.Fprintf(css,
+			".anim-%d { animation: anim-%d %dms linear %s both; }\n",
+			i, i, cycle.Milliseconds(), repeat)
+
+		for _, s := range shapes {
+			elems += shapeSVG(s, fmt.Sprintf("anim-%d", i))
+		}
+	}
+	css.WriteString("@media (prefers-reduced-motion: reduce) {\n" +
+		"  [class^=\"anim-\"] { animation: none; }\n}\n")
+
+	svg := SVGfn("<!-- Animation: %d steps, %dms -->",
+		len(steps), total.Milliseconds())
+	svg += SVG("<style>\n" + css.String() + "</style>\n")
+	svg += elems
+	return svg, nil
+}
+
+// wordsScene returns the scene of the words SVG (as returned by wordsToSVG),
+// on its own, so it has only their shapes.
+func wordsScene(wsvg SVG, width, height int) (*scene, error) {
+	buf := &bytes.Buffer{}
+	buf.WriteString(string(svgHeader(width, height)))
+	writeDefs(buf)
+	buf.WriteString(string(wsvg))
+	buf.WriteString("</svg>\n")
+	sc, err := parseScene(buf.String())
+	if err != nil {
+		return nil, fmt.Errorf("error parsing the SVG: %v", err)
+	}
+	return sc, nil
+}
+
+// animationSteps groups the shapes of the scene into the steps of the
+// animation, in the order they are drawn: each glyph is one step, and so is
+// every other shape.
+// The word lines are drawn from their start (at the right), like they are
+// read, although in the SVG they go the other way.
+func animationSteps(sc *scene) [][]shape {
+	steps := [][]shape{}
+	for i, s := range sc.shapes {
+		if i == 0 || s.use == 0 || s.use != sc.shapes[i-1].use {
+			steps = append(steps, nil)
+		}
+		if s.class == "word-line" && !s.circle {
+			s = s.reversed()
+		}
+		steps[len(steps)-1] = append(steps[len(steps)-1], s)
+	}
+	return steps
+}
+
+// reversed returns the shape with its subpaths in the opposite order, and
+// going in the opposite direction.
+func (s shape) reversed() shape {
+	sps := make([]subpath, len(s.subpaths))
+	for i, sp := range s.subpaths {
+		pts := slices.Clone(sp.points)
+		slices.Reverse(pts)
+		sps[len(sps)-1-i] = subpath{points: pts, closed: sp.closed}
+	}
+	s.subpaths = sps
+	return s
+}
+
+// stepTime returns how long each of the n steps of the animation takes.
+func (a animation) stepTime(n int) time.Duration {
+	if a.duration > 0 {
+		return (a.duration / time.Duration(n)).Round(time.Millisecond)
+	}
+	if a.glyphTime > 0 {
+		return a.glyphTime
+	}
+	return defaultGlyphTime
+}
+
+// shapeSVG returns the shape as an SVG element, with the given class. The
+// strokes are set up for animating with stroke-dashoffset (see animateSVG).
+func shapeSVG(s shape, class string) SVG {
+	attrs := fmt.Sprintf(`class="%s"`, class)
+	attrs += paintAttrs("fill", s.fill)
+	if s.hasStroke() {
+		attrs += paintAttrs("stroke", s.stroke)
+		attrs += fmt.Sprintf(` stroke-width="%s"`, svgNum(s.strokeWidth))
+		if s.linecap != "" && s.linecap != "butt" {
+			attrs += fmt.Sprintf(` stroke-linecap="%s"`, s.linecap)
+		}
+		if s.linejoin != "" && s.linejoin != "miter" {
+			attrs += fmt.Sprintf(` stroke-linejoin="%s"`, s.linejoin)
+		}
+		attrs += ` pathLength="1" stroke-dasharray="1"`
+	}
+
+	if s.circle {
+		return SVGfn(`<circle cx="%s" cy="%s" r="%s" %s />`,
+			svgNum(s.center.x), svgNum(s.center.y), svgNum(s.r), attrs)
+	}
+
+	return SVGfn(`<path d="%s" %s />`, pathData(s.subpaths), attrs)
+}
+
+// paintAttrs returns the SVG attributes for painting the fill or stroke
+// with the color.
+func paintAttrs(name string, c color.NRGBA) string {
+	if c.A == 0 {
+		return fmt.Sprintf(` %s="none"`, name)
+	}
+	s := fmt.Sprintf(` %s="%s"`, name, hexColor(c))
+	if c.A < 255 {
+		s += fmt.Sprintf(` %s-opacity="%s"`, name,
+			svgNum(float64(c.A)/255))
+	}
+	return s
+}
+
+// svgNum formats the number for SVG, with up to 3 decimals.
+func svgNum(v float64) string {
+	s := trimZeros(strconv.FormatFloat(v, 'f', 3, 64))
+	if s == "-0" {
+		s = "0"
+	}
+	return s
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAnimateSVG(t *testing.T) {
+	opts := Options{syllables: "manual", angle: defaultAngle,
+		sentences: "none", anim: animation{enabled: true}}
+	svg, err := genSVG([]string{"hello", "adora"}, opts, false)
+	if err != nil {
+		t.Fatalf("genSVG error: %v", err)
+	}
+
+	// The glyphs are drawn as paths, not <use>, one step each.
+	if strings.Contains(svg, "<use ") {
+		t.Errorf("animated SVG still has <use> elements")
+	}
+	steps := regexp.MustCompile(`<!-- Animation: (\d+) steps, (\d+)ms -->`).
+		FindStringSubmatch(svg)
+	if steps == nil {
+		t.Fatalf("animation comment not found")
+	}
+	if steps[1] != "17" || steps[2] != "5100" {
+		t.Errorf("unexpected steps/duration: %v", steps[1:])
+	}
+	for _, s := range []string{
+		"@keyframes anim-0 {\n  0% { stroke-dashoffset: 1;",
+		".anim-16 { animation: anim-16 5100ms linear 1 both; }",
+		"prefers-reduced-motion: reduce",
+		`pathLength="1" stroke-dasharray="1"`,
+	} {
+		if !strings.Contains(svg, s) {
+			t.Errorf("SVG does not contain %q", s)
+		}
+	}
+
+	// Reading order: the first word ("hello") is on the right, so its
+	// word line should be drawn first.
+	xs := regexp.MustCompile(`<path d="M([\d.]+) `).
+		FindAllStringSubmatch(svg, -1)
+	first, _ := strconv.ParseFloat(xs[0][1], 64)
+	last, _ := strconv.ParseFloat(xs[len(xs)-1][1], 64)
+	if first <= last {
+		t.Errorf("first path (x=%v) is not right of the last (x=%v)",
+			first, last)
+	}
+
+	// The word lines are drawn right to left too.
+	line := regexp.MustCompile(`<path d="M([\d.]+) [\d.]+ L([\d.]+) `).
+		FindStringSubmatch(svg)
+	if line == nil {
+		t.Fatalf("word line not found")
+	}
+	start, _ := strconv.ParseFloat(line[1], 64)
+	end, _ := strconv.ParseFloat(line[2], 64)
+	if start <= end {
+		t.Errorf("word line goes from x=%v to x=%v, expected right to left",
+			start, end)
+	}
+
+	// The static image is the same as the one without animation.
+	doc := &document{svg: svg}
+	sc, err := doc.scene()
+	if err != nil {
+		t.Fatalf("scene error: %v", err)
+	}
+	plain, _ := renderDocument([]string{"hello", "adora"},
+		Options{syllables: "manual", angle: defaultAngle, sentences: "none"},
+		false)
+	psc, _ := plain.scene()
+	if len(sc.shapes) != len(psc.shapes) {
+		t.Errorf("animated scene has %d shapes, expected %d",
+			len(sc.shapes), len(psc.shapes))
+	}
+}
+
+func TestAnimationTiming(t *testing.T) {
+	opts := Options{syllables: "manual", angle: defaultAngle,
+		sentences: "none"}
+	err := opts.applyValues(url.Values{
+		"animate": {"1"}, "duration": {"2.4s"}, "loop": {"1"},
+		"pause": {"600ms"},
+	})
+	if err != nil {
+		t.Fatalf("applyValues error: %v", err)
+	}
+	expected := animation{enabled: true, duration: 2400 * time.Millisecond,
+		loop: true, pause: 600 * time.Millisecond}
+	if opts.anim != expected {
+		t.Errorf("got %+v, expected %+v", opts.anim, expected)
+	}
+
+	svg, err := genSVG([]string{"hi"}, opts, false)
+	if err != nil {
+		t.Fatalf("genSVG error: %v", err)
+	}
+	// 2.4s over 6 steps (400ms each), plus the pause.
+	for _, s := range []string{
+		"<!-- Animation: 6 steps, 2400ms -->",
+		".anim-5 { animation: anim-5 3000ms linear infinite both; }",
+		"  0%, 66.67% { stroke-dashoffset: 1; fill-opacity: 0; }\n" +
+			"  80%, 100% { stroke-dashoffset: 0; fill-opacity: 1; }",
+	} {
+		if !strings.Contains(svg, s) {
+			t.Errorf("SVG does not contain %q", s)
+		}
+	}
+
+	for _, v := range []string{
+		"glyph-time=1x", "glyph-time=-1s", "duration=1h", "pause=2m",
+	} {
+		o := opts
+		values, _ := url.ParseQuery(v)
+		err := o.applyValues(values)
+		if err == nil {
+			err = o.check()
+		}
+		if err == nil {
+			t.Errorf("%q: expected error", v)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"io"
+)
+
+// # APNG
+//
+// Animated PNG is a PNG with extra chunks for the frames (see
+// https://wiki.mozilla.org/APNG_Specification): acTL, with the number of
+// frames, and for each frame fcTL, with its area and delay, and fdAT, with
+// its image data. Viewers without APNG support show the default image (the
+// IDAT chunks), which here is the final image, and not part of the
+// animation.
+//
+// image/png can't write the extra chunks, nor choose the color type (and
+// all the frames must have the one of the header), so the PNG is written
+// here: always 8-bit RGBA, non-interlaced, with the Sub filter.
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// writeAPNG writes the animation as an animated PNG.
+func writeAPNG(w io.Writer, a *animatedImage) error {
+	buf := &bytes.Buffer{}
+	buf.Write(pngSignature)
+
+	bounds := a.final.Bounds()
+	ihdr := pngUint32(nil, uint32(bounds.Dx()), uint32(bounds.Dy()))
+	ihdr = append(ihdr, 8, 6, 0, 0, 0) // 8-bit RGBA
+	writeChunk(buf, "IHDR", ihdr)
+
+	plays := uint32(1)
+	if a.loop {
+		plays = 0
+	}
+	writeChunk(buf, "acTL", pngUint32(nil, uint32(len(a.frames)), plays))
+
+	data, err := pngData(a.final)
+	if err != nil {
+		return err
+	}
+	writeChunk(buf, "IDAT", data)
+
+	// The frames and their data share the sequence numbers.
+	seq := uint32(0)
+	for i, f := range a.frames {
+		next := a.end
+		if i+1 < len(a.frames) {
+			next = a.frames[i+1].start
+		}
+		delay := min((next - f.start).Milliseconds(), 0xffff)
+
+		r := f.img.Bounds()
+		fctl := pngUint32(nil, seq, uint32(r.Dx()), uint32(r.Dy()),
+			uint32(r.Min.X), uint32(r.Min.Y))
+		fctl = binary.BigEndian.AppendUint16(fctl, uint16(delay))
+		fctl = binary.BigEndian.AppendUint16(fctl, 1000)
+		// Dispose op "none", blend op "source": the frames have the complete
+		// pixels of their area.
+		fctl = append(fctl, 0, 0)
+		writeChunk(buf, "fcTL", fctl)
+		seq++
+
+		data, err := pngData(f.img)
+		if err != nil {
+			return err
+		}
+		writeChunk(buf, "fdAT", append(pngUint32(nil, seq), data...))
+		seq++
+	}
+
+	writeChunk(buf, "IEND", nil)
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// pngData returns the compressed image data, as non-premultiplied RGBA.
+func pngData(img *image.RGBA) ([]byte, error) {
+	r := img.Bounds()
+	buf := &bytes.Buffer{}
+	zw := zlib.NewWriter(buf)
+	row := make([]byte, 1+4*r.Dx())
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		row[0] = 1 // Sub filter: each byte minus the one of the pixel before.
+		prev := [4]byte{}
+		for x := r.Min.X; x < r.Max.X; x++ {
+			i := img.PixOffset(x, y)
+			px := unpremultiply(img.Pix[i : i+4])
+			o := 1 + 4*(x-r.Min.X)
+			for c := range 4 {
+				row[o+c] = px[c] - prev[c]
+			}
+			prev = px
+		}
+		if _, err := zw.Write(row); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func unpremultiply(p []uint8) [4]byte {
+	a := uint32(p[3])
+	if a == 0 {
+		return [4]byte{}
+	}
+	return [4]byte{
+		uint8(uint32(p[0]) * 255 / a), uint8(uint32(p[1]) * 255 / a),
+		uint8(uint32(p[2]) * 255 / a), p[3]}
+}
+
+func pngUint32(b []byte, vs ...uint32) []byte {
+	for _, v := range vs {
+		b = binary.BigEndian.AppendUint32(b, v)
+	}
+	return b
+}
+
+// writeChunk writes a PNG chunk: length, type, data and CRC.
+func writeChunk(buf *bytes.Buffer, typ string, data []byte) {
+	buf.Write(pngUint32(nil, uint32(len(data))))
+	start := buf.Len()
+	buf.WriteString(typ)
+	buf.Write(data)
+	buf.Write(pngUint32(nil, crc32.ChecksumIEEE(buf.Bytes()[start:])))
+}
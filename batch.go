@@ -0,0 +1,335 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// # Batch mode
+//
+// The "batch" command renders many phrases, one file per line of the input,
+// into an output directory. The input can be:
+//
+//   - text: one phrase per line. Empty lines and lines beginning with "#"
+//     are skipped.
+//   - csv: phrase, and optionally the output name and the options (as URL
+//     parameters, like the HTTP server's, e.g. "syllables=auto&stress=1").
+//     A first row beginning with "phrase" is taken as a header and skipped.
+//   - jsonl: one JSON object per line, like
+//     {"phrase": "...", "name": "...", "options": {"stress": "1"}}.
+//
+// When the output name is not given, it's derived from the phrase. The
+// extension of the name selects the output format (see outputBackends).
+// The lines are rendered in parallel; errors are reported for each line,
+// without stopping the others.
+
+var batchFormatFlag = flag.String("batch-format", "",
+	"input format for the batch command: text, csv, or jsonl; by default "+
+		"it is taken from the file extension, or text for stdin")
+
+var batchFormats = map[string]func(io.Reader) ([]batchItem, error){
+	"text":  readBatchText,
+	"csv":   readBatchCSV,
+	"jsonl": readBatchJSONL,
+}
+
+// A phrase to render in batch mode.
+type batchItem struct {
+	// Line (or record) number in the input, for error reporting.
+	line int
+
+	phrase string
+
+	// Output name, without the directory. If empty, it's derived from the
+	// phrase.
+	name string
+
+	// Options for this phrase, on top of the ones from the flags.
+	options url.Values
+}
+
+// batchCmd implements the "batch" command. It reads the phrases from the
+// input (a path, or "" or "-" for stdin), and writes the files to outDir.
+// The errors for each line are reported to errW, and if there were any, an
+// error is returned.
+func batchCmd(errW io.Writer, outDir, input string, opts Options) error {
+	format := *batchFormatFlag
+	var in io.Reader = os.Stdin
+	if input != "" && input != "-" {
+		f, err := os.Open(input)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+
+		if format == "" {
+			format = strings.TrimPrefix(filepath.Ext(input), ".")
+			if format == "ndjson" {
+				format = "jsonl"
+			}
+			if _, ok := batchFormats[format]; !ok {
+				format = "text"
+			}
+		}
+	}
+	if format == "" {
+		format = "text"
+	}
+	read, ok := batchFormats[format]
+	if !ok {
+		return fmt.Errorf("unknown batch format %q", format)
+	}
+
+	items, err := read(in)
+	if err != nil {
+		return fmt.Errorf("error reading %s input: %v", format, err)
+	}
+	if err := os.MkdirAll(outDir, 0o755); err != nil {
+		return err
+	}
+
+	failed := renderBatch(errW, outDir, items, opts)
+	fmt.Fprintf(errW, "%d rendered, %d failed\n",
+		len(items)-failed, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d lines failed", failed, len(items))
+	}
+	return nil
+}
+
+// renderBatch renders the items in parallel, and returns how many failed.
+func renderBatch(errW io.Writer, outDir string, items []batchItem,
+	opts Options) int {
+	paths, errs := batchPaths(outDir, items)
+
+	// Serialize the writes to errW, so the messages don't get mixed up.
+	mu := sync.Mutex{}
+	report := func(line int, format string, args ...interface{}) {
+		mu.Lock()
+		defer mu.Unlock()
+		fmt.Fprintf(errW, "line %d: %s\n",
+			line, fmt.Sprintf(format, args...))
+	}
+
+	jobs := make(chan int)
+	wg := sync.WaitGroup{}
+	for range runtime.GOMAXPROCS(0) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if errs[i] == nil {
+					errs[i] = renderBatchItem(
+						items[i], paths[i], opts, report)
+				}
+			}
+		}()
+	}
+	for i := range items {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	failed := 0
+	for i, err := range errs {
+		if err != nil {
+			report(items[i].line, "error: %v", err)
+			failed++
+		}
+	}
+	return failed
+}
+
+// renderBatchItem renders a single item to the given path.
+func renderBatchItem(item batchItem, path string, opts Options,
+	report func(line int, format string, args ...interface{})) error {
+	if err := opts.applyValues(item.options); err != nil {
+		return err
+	}
+	if err := opts.check(); err != nil {
+		return err
+	}
+	opts.notef = func(format string, args ...interface{}) {
+		report(item.line, "note: "+format, args...)
+	}
+
+	words := tokenize(item.phrase)
+	if len(words) == 0 {
+		return errors.New("no words")
+	}
+	doc, err := renderDocument(words, opts, *showGrid)
+	if err != nil {
+		return err
+	}
+	return writeOutput(path, doc, *overwriteFlag)
+}
+
+// batchPaths returns the output path of each item, or an error if it's not
+// valid. Explicit names must be unique, and are reserved first; names
+// derived from the phrase get the line number appended (and then a counter)
+// until they don't clash with any other.
+func batchPaths(outDir string, items []batchItem) ([]string, []error) {
+	paths := make([]string, len(items))
+	errs := make([]error, len(items))
+	seen := map[string]bool{}
+	for i, item := range items {
+		name := item.name
+		if name == "" {
+			continue
+		}
+		if err := checkBatchName(name); err != nil {
+			errs[i] = err
+			continue
+		}
+		// The name can have the extension of any of the output formats,
+		// otherwise it's an SVG.
+		if _, _, err := backendFor(name); err != nil {
+			name += ".svg"
+		}
+
+		if seen[name] {
+			errs[i] = fmt.Errorf("duplicate output name %q", name)
+			continue
+		}
+		seen[name] = true
+		paths[i] = filepath.Join(outDir, name)
+	}
+
+	for i, item := range items {
+		if item.name != "" {
+			continue
+		}
+		base := nameFromPhrase(item.phrase)
+		name := base + ".svg"
+		if seen[name] {
+			name = fmt.Sprintf("%s-%d.svg", base, item.line)
+		}
+		for n := 2; seen[name]; n++ {
+			name = fmt.Sprintf("%s-%d-%d.svg", base, item.line, n)
+		}
+		seen[name] = true
+		paths[i] = filepath.Join(outDir, name)
+	}
+	return paths, errs
+}
+
+// checkBatchName checks that the output name given by the user is a plain
+// file name, so we don't write outside of the output directory.
+func checkBatchName(name string) error {
+	if name != filepath.Base(name) || strings.ContainsAny(name, `/\`) ||
+		strings.HasPrefix(name, ".") {
+		return fmt.Errorf("invalid output name %q", name)
+	}
+	return nil
+}
+
+// nameFromPhrase returns a file name for the phrase, keeping only letters
+// and numbers, with "_" instead of spaces.
+func nameFromPhrase(phrase string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsNumber(r) || r == '-':
+			return r
+		case unicode.IsSpace(r):
+			return '_'
+		}
+		return -1
+	}, strings.TrimSpace(phrase))
+	if name == "" {
+		name = "phrase"
+	}
+	return name
+}
+
+func readBatchText(r io.Reader) ([]batchItem, error) {
+	items := []batchItem{}
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		items = append(items, batchItem{line: n, phrase: line})
+	}
+	return items, scanner.Err()
+}
+
+func readBatchCSV(r io.Reader) ([]batchItem, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	items := []batchItem{}
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := cr.FieldPos(0)
+		if line == 1 && strings.EqualFold(record[0], "phrase") {
+			continue
+		}
+		if len(record) > 3 {
+			return nil, fmt.Errorf("line %d: too many fields", line)
+		}
+
+		item := batchItem{line: line, phrase: record[0]}
+		if len(record) > 1 {
+			item.name = strings.TrimSpace(record[1])
+		}
+		if len(record) > 2 {
+			item.options, err = url.ParseQuery(record[2])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid options: %v",
+					line, err)
+			}
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func readBatchJSONL(r io.Reader) ([]batchItem, error) {
+	items := []batchItem{}
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		j := struct {
+			Phrase  string                 `json:"phrase"`
+			Name    string                 `json:"name"`
+			Options map[string]interface{} `json:"options"`
+		}{}
+		if err := json.Unmarshal([]byte(line), &j); err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+
+		item := batchItem{line: n, phrase: j.Phrase, name: j.Name,
+			options: url.Values{}}
+		for k, v := range j.Options {
+			item.options.Set(k, fmt.Sprint(v))
+		}
+		items = append(items, item)
+	}
+	return items, scanner.Err()
+}
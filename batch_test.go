@@ -0,0 +1,149 @@
+package main
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReadBatch(t *testing.T) {
+	cases := []struct {
+		format   string
+		input    string
+		expected []batchItem
+	}{
+		{"text", "Hello Adora\n\n# comment\n  Catra  \n",
+			[]batchItem{
+				{line: 1, phrase: "Hello Adora"},
+				{line: 4, phrase: "Catra"},
+			}},
+		{"csv", "phrase,name,options\n" +
+			"\"Hello, Adora\",adora,stress=1&angle=20\n" +
+			"Catra\n",
+			[]batchItem{
+				{line: 2, phrase: "Hello, Adora", name: "adora",
+					options: url.Values{
+						"stress": {"1"}, "angle": {"20"}}},
+				{line: 3, phrase: "Catra"},
+			}},
+		{"jsonl", `{"phrase": "Catra", "name": "c", ` +
+			`"options": {"stress": true, "angle": 15}}` + "\n\n" +
+			`{"phrase": "Adora"}` + "\n",
+			[]batchItem{
+				{line: 1, phrase: "Catra", name: "c",
+					options: url.Values{
+						"stress": {"true"}, "angle": {"15"}}},
+				{line: 3, phrase: "Adora", options: url.Values{}},
+			}},
+	}
+	for _, c := range cases {
+		got, err := batchFormats[c.format](strings.NewReader(c.input))
+		if err != nil {
+			t.Errorf("%s: error: %v", c.format, err)
+			continue
+		}
+		diff := cmp.Diff(c.expected, got, cmp.AllowUnexported(batchItem{}))
+		if diff != "" {
+			t.Errorf("%s: unexpected items (-want +got):\n%s",
+				c.format, diff)
+		}
+	}
+
+	if _, err := readBatchJSONL(strings.NewReader("{bad")); err == nil {
+		t.Errorf("invalid JSON did not fail")
+	}
+}
+
+func TestBatchPaths(t *testing.T) {
+	items := []batchItem{
+		{line: 1, phrase: "Hello, Adora!"},
+		{line: 2, phrase: "Hello Adora"},
+		{line: 3, phrase: "x", name: "tag.svg"},
+		{line: 4, phrase: "x", name: "tag.png"},
+		{line: 5, phrase: "y", name: "tag"},
+		{line: 6, phrase: "z", name: "../evil"},
+	}
+	paths, errs := batchPaths("out", items)
+	expected := []string{
+		"out/Hello_Adora.svg",
+		"out/Hello_Adora-2.svg",
+		"out/tag.svg",
+		"out/tag.png",
+		"",
+		"",
+	}
+	if diff := cmp.Diff(expected, paths); diff != "" {
+		t.Errorf("unexpected paths (-want +got):\n%s", diff)
+	}
+	for i, err := range errs {
+		if (err != nil) != (i >= 4) {
+			t.Errorf("item %d: unexpected error %v", i, err)
+		}
+	}
+}
+
+func TestBatchPathsClash(t *testing.T) {
+	// Derived names don't take the explicit ones, even from later lines,
+	// and get more suffixes until they are unique.
+	items := []batchItem{
+		{line: 1, phrase: "hello", name: "adora-3.svg"},
+		{line: 2, phrase: "adora"},
+		{line: 3, phrase: "adora"},
+		{line: 4, phrase: "catra"},
+		{line: 5, phrase: "x", name: "catra"},
+		{line: 6, phrase: "adora", name: "adora-6"},
+		{line: 7, phrase: "adora"},
+	}
+	paths, errs := batchPaths("out", items)
+	expected := []string{
+		"out/adora-3.svg",
+		"out/adora.svg",
+		"out/adora-3-2.svg",
+		"out/catra-4.svg",
+		"out/catra.svg",
+		"out/adora-6.svg",
+		"out/adora-7.svg",
+	}
+	if diff := cmp.Diff(expected, paths); diff != "" {
+		t.Errorf("unexpected paths (-want +got):\n%s", diff)
+	}
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("item %d: unexpected error %v", i, err)
+		}
+	}
+}
+
+func TestBatchCmd(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input.csv")
+	os.WriteFile(input, []byte(
+		"Catra,catra,angle=20\n"+
+			"xx:bad,bad\n"+
+			"Adora,adora,angle=99\n"), 0o644)
+
+	out := filepath.Join(dir, "out")
+	errW := &strings.Builder{}
+	opts := Options{syllables: "manual", sentences: "none",
+		angle: defaultAngle}
+	err := batchCmd(errW, out, input, opts)
+	if err == nil {
+		t.Errorf("expected an error, got nil")
+	}
+	for _, e := range []string{
+		"line 2: error:", "line 3: error: angle 99", "1 rendered, 2 failed",
+	} {
+		if !strings.Contains(errW.String(), e) {
+			t.Errorf("errors do not contain %q:\n%s", e, errW)
+		}
+	}
+
+	svg, err := os.ReadFile(filepath.Join(out, "catra.svg"))
+	if err != nil || !strings.Contains(string(svg), "rotate(20)") {
+		t.Errorf("unexpected catra.svg: %v\n%s", err, svg)
+	}
+}
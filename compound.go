@@ -0,0 +1,148 @@
+package main
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// # Compound words
+//
+// Many hyphenated and compound words are not in the dictionaries ("she-ra"
+// only works because it's in namesIPA). When a word is not found, we try to
+// split it into parts that are, and join their pronunciations:
+//
+//   - Hyphenated words are split on the hyphens ("rainbow-cat").
+//   - Other words are split greedily into dictionary words
+//     ("rainbowcat" -> "rainbow" + "cat"), preferring the fewest parts.
+//
+// The parts are kept as a syllable group each if opts.parts is set.
+
+// Minimum length of each part of a compound word (without hyphens). Shorter
+// ones match too often, and give silly splits.
+const minCompoundPart = 3
+
+// Maximum length of the words we try to split, in runes. The search is
+// quadratic in the length of the word, and it's done for each dictionary,
+// so longer words (which are not real ones anyway) are left alone.
+const maxCompoundLen = 32
+
+// An IPA part of a word: the text and its pronunciation.
+type ipaPart struct {
+	word, ipa string
+
+	// Letters that were skipped, if the pronunciation comes from the G2P
+	// rules (see g2pRules.convert).
+	skipped []string
+}
+
+// splitCompound splits the word into parts that are in the dictionary.
+// It returns nil if it can't, or if the word is longer than maxCompoundLen.
+func splitCompound(word string, dict IPADict) []string {
+	if utf8.RuneCountInString(word) > maxCompoundLen {
+		return nil
+	}
+	if strings.Contains(word, "-") {
+		parts := []string{}
+		for _, p := range strings.Split(word, "-") {
+			if p == "" {
+				continue
+			}
+			if _, ok := dictLookup(p, dict); ok {
+				parts = append(parts, p)
+				continue
+			}
+			sub := splitCompound(p, dict)
+			if sub == nil {
+				return nil
+			}
+			parts = append(parts, sub...)
+		}
+		if len(parts) == 0 {
+			return nil
+		}
+		return parts
+	}
+
+	// best[i] is the split of word[:i] with the fewest parts, if any.
+	// The indices are in runes, so the accented letters count as one.
+	w := []rune(word)
+	if len(w) < 2*minCompoundPart {
+		return nil
+	}
+	best := make([][]string, len(w)+1)
+	best[0] = []string{}
+	for end := minCompoundPart; end <= len(w); end++ {
+		for start := 0; start <= end-minCompoundPart; start++ {
+			if best[start] == nil {
+				continue
+			}
+			part := string(w[start:end])
+			if _, ok := dictLookup(part, dict); !ok {
+				continue
+			}
+			if best[end] == nil || len(best[start])+1 < len(best[end]) {
+				best[end] = append(append([]string{}, best[start]...), part)
+			}
+		}
+	}
+
+	if len(best[len(w)]) < 2 {
+		// Either no split, or the word itself (which would have been found
+		// before).
+		return nil
+	}
+	return best[len(w)]
+}
+
+// dictLookup looks up the word in the dictionary, also trying the lowercase
+// variant.
+func dictLookup(word string, dict IPADict) (string, bool) {
+	ipa, ok := dict[word]
+	if !ok {
+		// Note we can't just lowercase because some IPA dicts have
+		// intentional uppercase words.
+		ipa, ok = dict[strings.ToLower(word)]
+	}
+	return ipa, ok
+}
+
+// lookupParts returns the IPA of the word, like lookupIPA, but if the word
+// is not in the dictionary, it tries to split it first (see splitCompound).
+// It returns the IPA of each part.
+func lookupParts(word, lang string) ([]ipaPart, error) {
+	_, dict, err := matchDict(lang)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := dictLookup(word, dict); !ok {
+		if parts := splitCompound(word, dict); parts != nil {
+			ipaParts := []ipaPart{}
+			for _, p := range parts {
+				ipa, _ := dictLookup(p, dict)
+				ipaParts = append(ipaParts, ipaPart{word: p, ipa: ipa})
+			}
+			return ipaParts, nil
+		}
+	}
+
+	ipa, skipped, err := lookupIPA(word, lang)
+	if err != nil {
+		return nil, err
+	}
+	return []ipaPart{{word: word, ipa: ipa, skipped: skipped}}, nil
+}
+
+// wordSplits returns the glyph indices at which each syllable of the word
+// (other than the first) begins.
+func wordSplits(word Word) []int {
+	splits := []int{}
+	n := 0
+	for i, syllable := range word {
+		if i > 0 {
+			splits = append(splits, n)
+		}
+		n += len(syllable)
+	}
+	return splits
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSplitCompound(t *testing.T) {
+	dict := IPADict{
+		"rain": "ɹeɪn", "bow": "boʊ", "rainbow": "ˈɹeɪnˌboʊ", "cat": "kæt",
+		"x": "ɛks", "ray": "ɹeɪ", "sun": "sən", "flower": "fɫaʊɝ",
+	}
+	cases := []struct {
+		word     string
+		expected []string
+	}{
+		// The fewest parts win.
+		{"rainbowcat", []string{"rainbow", "cat"}},
+		{"sunflower", []string{"sun", "flower"}},
+		{strings.Repeat("cat", 10), slices.Repeat([]string{"cat"}, 10)},
+		{"SunFlower", []string{"Sun", "Flower"}},
+
+		// Hyphens, where the parts can be short, or compounds themselves.
+		{"x-ray", []string{"x", "ray"}},
+		{"rainbowcat-sun", []string{"rainbow", "cat", "sun"}},
+		{"cat--bow-", []string{"cat", "bow"}},
+
+		// Can't be split.
+		{"rainbow", nil},
+		{"catdog", nil},
+		{"cat-dog", nil},
+		{"bowx", nil},
+		{"-", nil},
+
+		// Too long.
+		{strings.Repeat("cat", 11), nil},
+	}
+	for _, c := range cases {
+		got := splitCompound(c.word, dict)
+		if diff := cmp.Diff(c.expected, got); diff != "" {
+			t.Errorf("splitCompound(%q) mismatch (-expected +got):\n%s",
+				c.word, diff)
+		}
+	}
+}
+
+func TestCompoundParts(t *testing.T) {
+	parts, err := lookupParts("rainbow-cat", "en")
+	if err != nil {
+		t.Fatalf("lookupParts: %v", err)
+	}
+	if len(parts) != 2 || parts[0].word != "rainbow" || parts[1].word != "cat" {
+		t.Errorf("lookupParts(rainbow-cat) = %v", parts)
+	}
+
+	// Words in the dictionary are not split.
+	parts, err = lookupParts("sunflower", "en")
+	if err != nil || len(parts) != 1 {
+		t.Errorf("lookupParts(sunflower) = %v, %v", parts, err)
+	}
+
+	// By default the parts are a single syllable, but can be kept apart.
+	w, err := langWordToGlyphs("rainbowcat", "en", Options{syllables: "manual"})
+	if err != nil || len(w) != 1 {
+		t.Errorf("rainbowcat: got %v, %v; expected 1 syllable", w, err)
+	}
+	w, err = langWordToGlyphs("rainbowcat", "en",
+		Options{syllables: "manual", parts: true})
+	if err != nil || len(w) != 2 || w[1].String() != "K-sAd-T" {
+		t.Errorf("rainbowcat with parts: got %v, %v", w, err)
+	}
+}
+
+func TestWordSplits(t *testing.T) {
+	w := Word{
+		{mustGetGlyph("K"), mustGetGlyph("sAd")},
+		{mustGetGlyph("T")},
+		{mustGetGlyph("R"), mustGetGlyph("sAd")},
+	}
+	if diff := cmp.Diff([]int{2, 3}, wordSplits(w)); diff != "" {
+		t.Errorf("wordSplits mismatch:\n%s", diff)
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// IPA coverage of the dictionaries, to help maintain the ipaToGlyphs1 and
+// ipaToGlyphs2 maps.
+// It counts the symbols used in the dictionaries, finds the ones that we
+// can't map to glyphs, and checks how many of the words can be converted.
+
+// How many example words to keep for each unmapped symbol.
+const coverageExamples = 3
+
+type ipaCoverage struct {
+	// Names of the dictionaries we looked at.
+	dicts []string
+
+	// Number of words, and how many of them can be converted to glyphs.
+	words, converted int
+
+	// Frequency of each symbol.
+	counts map[rune]int
+
+	// Example words for each symbol that can't be converted.
+	unmapped map[rune][]string
+}
+
+func newIPACoverage() *ipaCoverage {
+	return &ipaCoverage{
+		counts:   map[rune]int{},
+		unmapped: map[rune][]string{},
+	}
+}
+
+// addDict adds all the words of the dictionary to the coverage.
+func (c *ipaCoverage) addDict(name string, dict IPADict) {
+	c.dicts = append(c.dicts, name)
+
+	// Go through the words in order, so the examples are reproducible.
+	for _, word := range slices.Sorted(maps.Keys(dict)) {
+		c.add(word, dict[word])
+	}
+}
+
+// add a single word to the coverage.
+func (c *ipaCoverage) add(word, ipa string) {
+	c.words++
+	for _, r := range ipa {
+		c.counts[r]++
+	}
+
+	norm, _ := normalizeIPA(ipa)
+	if _, _, err := syllablesToGlyphs(norm); err == nil {
+		c.converted++
+		return
+	}
+
+	// Find which symbols are the problem. We use the same logic as the
+	// conversion, so symbols that are only valid as part of a two-symbol
+	// sequence are handled correctly.
+	for _, seg := range ipaSegments(norm) {
+		if _, ok := syllableMarks[seg]; ok {
+			continue
+		}
+		if _, err := ipaToGlyphs([]string{seg}); err == nil {
+			continue
+		}
+		r := []rune(seg)[0]
+		examples := c.unmapped[r]
+		if len(examples) < coverageExamples &&
+			!slices.Contains(examples, word) {
+			examples = append(examples, word)
+		}
+		c.unmapped[r] = examples
+	}
+}
+
+// symbolDesc describes how the symbol is handled, for reporting.
+func symbolDesc(r rune) string {
+	s := string(r)
+	if glyph, ok := ipaToGlyphs1[r]; ok {
+		if glyph == "" {
+			return "(ignored)"
+		}
+		return glyph
+	}
+	if _, ok := syllableMarks[s]; ok {
+		return "(syllable mark)"
+	}
+	if norm, removed := normalizeIPA(s); len(removed) > 0 {
+		if norm == "" {
+			return "(removed)"
+		}
+		return fmt.Sprintf("(normalized to %q)", norm)
+	}
+	for _, seq := range slices.Sorted(maps.Keys(ipaToGlyphs2)) {
+		if strings.ContainsRune(seq, r) {
+			return fmt.Sprintf("(only in %q: %s)", seq, ipaToGlyphs2[seq])
+		}
+	}
+	return "(unmapped)"
+}
+
+func (c *ipaCoverage) write(w io.Writer) {
+	fmt.Fprintf(w, "Dictionaries: %s\n", strings.Join(c.dicts, ", "))
+
+	pct := 0.0
+	if c.words > 0 {
+		pct = float64(c.converted) / float64(c.words) * 100
+	}
+	fmt.Fprintf(w, "Words: %d, %d can be converted (%.2f%%)\n",
+		c.words, c.converted, pct)
+
+	total := 0
+	for _, n := range c.counts {
+		total += n
+	}
+	symbols := slices.Collect(maps.Keys(c.counts))
+	slices.SortFunc(symbols, func(a, b rune) int {
+		if c.counts[a] != c.counts[b] {
+			return c.counts[b] - c.counts[a]
+		}
+		return int(a - b)
+	})
+
+	fmt.Fprintf(w, "\nSymbol frequency (%d symbols):\n", len(symbols))
+	for _, r := range symbols {
+		fmt.Fprintf(w, "  %-3s U+%04X  %8d  (%5.2f%%)  %s\n",
+			symbolForDisplay(r), r, c.counts[r],
+			float64(c.counts[r])/float64(total)*100,
+			symbolDesc(r))
+	}
+
+	unmapped := slices.Sorted(maps.Keys(c.unmapped))
+	fmt.Fprintf(w, "\nUnmapped symbols (%d):\n", len(unmapped))
+	for _, r := range unmapped {
+		fmt.Fprintf(w, "  %-3s U+%04X  e.g. %s\n",
+			symbolForDisplay(r), r, strings.Join(c.unmapped[r], ", "))
+	}
+
+	if len(unmapped) > 0 {
+		// Print the unmapped symbols in an easy to copy-paste way, for
+		// adding them to ipaToGlyphs1.
+		fmt.Fprintf(w, "\nFor ipaToGlyphs1:\n")
+		for _, r := range unmapped {
+			fmt.Fprintf(w, "\t'%c': \"\",\n", r)
+		}
+	}
+}
+
+// symbolForDisplay returns the symbol as a string that can be displayed,
+// putting combining marks over a dotted circle, and quoting spaces.
+func symbolForDisplay(r rune) string {
+	if r == ' ' || r == '\t' {
+		return fmt.Sprintf("%q", r)
+	}
+	return strings.Trim(quoteSymbols([]string{string(r)}), `"`)
+}
+
+// ipaCoverageCmd implements the "ipa-coverage" command.
+// If no paths are given, it uses the embedded dictionaries. Otherwise, the
+// format of each dictionary is guessed from its name (see dictFormatFor).
+func ipaCoverageCmd(w io.Writer, paths []string) error {
+	c := newIPACoverage()
+
+	if len(paths) == 0 {
+		des, err := ipaFS.ReadDir("ipa")
+		if err != nil {
+			return err
+		}
+		for _, de := range des {
+			f, err := ipaFS.Open("ipa/" + de.Name())
+			if err != nil {
+				return err
+			}
+			err = c.addFile(de.Name(), f)
+			f.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, path := range paths {
+		dict, err := readDict(path, "")
+		if err != nil {
+			return err
+		}
+		c.addDict(dictName(path), dict)
+	}
+
+	c.write(w)
+	return nil
+}
+
+// addFile parses the dictionary file and adds it to the coverage.
+func (c *ipaCoverage) addFile(name string, r io.Reader) error {
+	dict := IPADict{}
+	if err := parseIPADict(r, dict); err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	c.addDict(dictName(name), dict)
+	return nil
+}
+
+// dictName returns a name for the dictionary, for reporting.
+func dictName(path string) string {
+	base := filepath.Base(path)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
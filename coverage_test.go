@@ -0,0 +1,64 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestIPACoverage(t *testing.T) {
+	c := newIPACoverage()
+	c.addDict("test", IPADict{
+		"she":     "ˈʃi",
+		"feet":    "fiːt",
+		"rue":     "ʕʔ",
+		"bonjour": "bɔ̃ʒuʕ",
+	})
+
+	if c.words != 4 || c.converted != 2 {
+		t.Errorf("words/converted = %d/%d, expected 4/2",
+			c.words, c.converted)
+	}
+	if c.counts['ʕ'] != 2 || c.counts['ː'] != 1 {
+		t.Errorf("unexpected counts: %v", c.counts)
+	}
+
+	expected := map[rune][]string{
+		'ʕ': {"bonjour", "rue"},
+		'ʔ': {"rue"},
+	}
+	if diff := cmp.Diff(expected, c.unmapped); diff != "" {
+		t.Errorf("unmapped mismatch:\n%s", diff)
+	}
+
+	buf := &strings.Builder{}
+	c.write(buf)
+	out := buf.String()
+	for _, s := range []string{
+		"Words: 4, 2 can be converted (50.00%)",
+		"ʃ   U+0283         1  (",
+		"ː   U+02D0         1  ( 6.67%)  (removed)",
+		"ʕ   U+0295  e.g. bonjour, rue",
+		"\t'ʔ': \"\",",
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("output does not contain %q:\n%s", s, out)
+		}
+	}
+}
+
+func TestSymbolDesc(t *testing.T) {
+	cases := map[rune]string{
+		'ʃ': "SH",
+		'ˈ': "(ignored)",
+		'.': "(syllable mark)",
+		'ː': "(removed)",
+		'ʕ': "(unmapped)",
+	}
+	for r, expected := range cases {
+		if got := symbolDesc(r); got != expected {
+			t.Errorf("symbolDesc(%q) = %q, expected %q", r, got, expected)
+		}
+	}
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/language/display"
+)
+
+// # Language detection
+//
+// When a word doesn't have a language prefix, we need to find out which
+// language it's in. Looking at each word on its own is not great: "come" or
+// "once" are valid in both English and Spanish, with very different
+// pronunciations.
+//
+// So we look at the whole phrase, and score each supported language by:
+//
+//   - How many of the words are in its dictionary. Languages without a
+//     dictionary (which use G2P rules) get half a point for each word that
+//     is not in any dictionary, so the words that are unknown everywhere
+//     don't count against them.
+//   - How many letter sequences typical of the language (e.g. "sch" for
+//     German, "ção" for Portuguese) appear in the words.
+//   - The user's preferred languages (e.g. from the Accept-Language header),
+//     which give a small bonus, and are the default when there is no other
+//     evidence.
+//
+// The language with the highest score is used for the whole phrase, except
+// for words with an explicit prefix. Words that are not in its dictionary
+// fall back to the other languages, in score order.
+
+// Letter sequences typical of each language, by base language.
+// They are weighted by their length, since longer ones are more distinctive,
+// and letters outside of ASCII (e.g. "ß") are worth more.
+var langMarkers = map[string][]string{
+	"en": {"th", "wh", "ght", "ing", "ck", "sh", "ee", "oo", "ea", "ow",
+		"w", "y"},
+	"es": {"ñ", "ll", "ción", "rr", "ue", "ie", "á", "í", "ó", "ú"},
+	"fr": {"eau", "ou", "oi", "ui", "ç", "é", "è", "ê", "à", "â", "î", "ô",
+		"û", "aux", "eux", "ais", "ait", "tion", "ille", "qu", "je"},
+	"de": {"sch", "ß", "ä", "ö", "ü", "ei", "ie", "tz", "ck", "cht", "ung",
+		"ich", "z"},
+	"pt": {"ão", "õe", "ç", "nh", "lh", "ã", "õ", "ê", "ô", "á", "ção",
+		"inho"},
+	"it": {"gli", "gn", "zz", "cc", "cch", "zione", "tt", "ia", "io", "ò",
+		"ì", "ù"},
+}
+
+// Weights of the different parts of the score.
+const (
+	dictHitWeight = 2.0
+	markerWeight  = 1.0
+
+	// Bonus for the first preferred language; the next ones get half of the
+	// previous one.
+	preferredBonus = 0.25
+)
+
+// Minimum confidence to use the G2P rules of the detected language for
+// words that are not in any dictionary (unless it was a preferred language).
+// Otherwise, we assume they're glyph names.
+const minG2PConfidence = 0.25
+
+// The result of the language detection for a phrase.
+type langDetection struct {
+	// The detected language, and its dictionary index (see IPADicts).
+	lang language.Tag
+	idx  int
+
+	// How confident we are, from 0 (tied with another language, or no
+	// evidence at all) to 1 (no other language is a candidate). It is based
+	// only on the evidence in the words, not on the preferred languages.
+	confidence float64
+
+	// Whether the language is one of the preferred ones.
+	preferred bool
+
+	// Dictionary indices of all the languages, from best to worst score.
+	ranked []int
+
+	// How many words were considered.
+	words int
+}
+
+func (d langDetection) String() string {
+	return fmt.Sprintf("%s (%s), confidence %.0f%%",
+		display.English.Tags().Name(d.lang), d.lang, d.confidence*100)
+}
+
+// detectLanguage detects the language of the phrase.
+// Words with a language prefix, numbers, and the ones that are glyph names,
+// are not considered.
+func detectLanguage(words []string, prefs []language.Tag) langDetection {
+	// Dictionary index -> score, without and with the preference bonus.
+	evidence := map[int]float64{}
+	scores := map[int]float64{}
+
+	candidates := []string{}
+	for _, word := range words {
+		if word == sentenceEnd || strings.ContainsAny(word, ":0123456789") ||
+			isGlyphNames(word) {
+			continue
+		}
+		word = strings.ReplaceAll(word, "/", "")
+		if word == "" {
+			continue
+		}
+		candidates = append(candidates, word)
+	}
+
+	// Fraction of the words that are not in any dictionary, for the
+	// languages that don't have one. Like in dictHitRatio, the names are
+	// left out: they are in all the dictionaries, so they are no evidence
+	// for the G2P languages either.
+	unknown, n := 0.0, 0
+	for _, word := range candidates {
+		if _, ok := namesIPA[strings.ToLower(word)]; ok {
+			continue
+		}
+		n++
+		inDict := false
+		for idx := range dictLangs {
+			if hasDict(idx) && dictHitRatio([]string{word}, idx) > 0 {
+				inDict = true
+				break
+			}
+		}
+		if !inDict {
+			unknown++
+		}
+	}
+	if n > 0 {
+		unknown /= float64(n)
+	}
+
+	for idx, tag := range dictLangs {
+		if len(candidates) > 0 {
+			dictScore := dictHitRatio(candidates, idx)
+			if !hasDict(idx) {
+				dictScore = max(dictScore, unknown/2)
+			}
+			evidence[idx] = (dictHitWeight*dictScore +
+				markerWeight*markerScore(candidates, tag)) /
+				(dictHitWeight + markerWeight)
+		}
+		scores[idx] = evidence[idx]
+	}
+
+	// Preferred languages that we support get a bonus.
+	prefIdxs := map[int]bool{}
+	bonus := preferredBonus
+	for _, pref := range prefs {
+		_, idx, conf := langMatcher.Match(pref)
+		if conf <= language.Low || prefIdxs[idx] {
+			continue
+		}
+		prefIdxs[idx] = true
+		scores[idx] += bonus
+		bonus /= 2
+	}
+
+	det := langDetection{words: len(candidates)}
+	for idx := range dictLangs {
+		det.ranked = append(det.ranked, idx)
+	}
+	// On ties, the languages with a dictionary go first, so without any
+	// evidence (e.g. a phrase made only of names) we fall back to the
+	// preferred languages, and then to the first dictionary.
+	slices.SortStableFunc(det.ranked, func(a, b int) int {
+		switch {
+		case scores[a] > scores[b]:
+			return -1
+		case scores[a] < scores[b]:
+			return 1
+		case hasDict(a) && !hasDict(b):
+			return -1
+		case !hasDict(a) && hasDict(b):
+			return 1
+		}
+		return 0
+	})
+
+	det.idx = det.ranked[0]
+	det.lang = dictLangs[det.idx]
+	det.preferred = prefIdxs[det.idx]
+
+	// The confidence is how far ahead of the next best language we are.
+	best, second := evidence[det.idx], 0.0
+	for idx, e := range evidence {
+		if idx != det.idx {
+			second = max(second, e)
+		}
+	}
+	if best > 0 {
+		det.confidence = max(best-second, 0) / best
+	}
+
+	return det
+}
+
+// hasDict returns true if the language has a dictionary, and not just the
+// names (like the languages that use G2P rules).
+func hasDict(idx int) bool {
+	return fromDict[idx]
+}
+
+// dictHitRatio returns the fraction of the words that are in the dictionary
+// (or the user dictionary) of the language.
+// The names are in all the dictionaries, so they are not counted.
+func dictHitRatio(words []string, idx int) float64 {
+	dict := IPADicts[idx]
+	lang := dictLangs[idx].String()
+	n, hits := 0, 0
+	for _, word := range words {
+		lower := strings.ToLower(word)
+		if _, ok := namesIPA[lower]; ok {
+			continue
+		}
+		n++
+
+		_, ok := dict[word]
+		if !ok {
+			_, ok = dict[lower]
+		}
+		if !ok {
+			_, ok = userDict.Load().lookupLang(word, lang)
+		}
+		if ok {
+			hits++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return float64(hits) / float64(n)
+}
+
+// markerScore returns how much the words look like the language, from 0 to
+// 1, based on the langMarkers.
+func markerScore(words []string, tag language.Tag) float64 {
+	base, _ := tag.Base()
+	markers := langMarkers[base.String()]
+	if len(markers) == 0 {
+		return 0
+	}
+
+	score := 0.0
+	for _, word := range words {
+		lower := strings.ToLower(word)
+		ws := 0.0
+		for _, m := range markers {
+			if !strings.Contains(lower, m) {
+				continue
+			}
+			for _, r := range m {
+				if r < utf8.RuneSelf {
+					ws++
+				} else {
+					ws += 3
+				}
+			}
+		}
+		// A few distinctive markers are enough to be sure about a word.
+		score += min(ws/4, 1)
+	}
+	return score / float64(len(words))
+}
+
+// isGlyphNames returns true if the word is a sequence of glyph names (e.g.
+// "SH-fEEt-R-All").
+func isGlyphNames(word string) bool {
+	w, err := phonemesToGlyphs(word)
+	return err == nil && len(w) > 0
+}
+
+// wordLang returns the language to use for the word (which must not have a
+// prefix), given the detection for the phrase.
+// It returns "" if the word should be treated as glyph names.
+func wordLang(word string, det langDetection) string {
+	w := strings.ReplaceAll(word, "/", "")
+
+	// Names are in all the dictionaries, so use the detected language.
+	if _, ok := namesIPA[strings.ToLower(w)]; ok {
+		return det.lang.String()
+	}
+
+	// The detected language, if the word is in its dictionary, or if we are
+	// confident enough about it and it has G2P rules. This way the phrase
+	// is consistent, even if some of the words also exist in other
+	// languages.
+	if dictHitRatio([]string{w}, det.idx) > 0 {
+		return det.lang.String()
+	}
+	_, hasG2P := dictG2P[det.idx]
+	useG2P := hasG2P && !isGlyphNames(word) &&
+		(det.confidence >= minG2PConfidence || det.preferred)
+	if useG2P {
+		return det.lang.String()
+	}
+
+	// Otherwise, the first language, in score order, that has the word in
+	// its dictionary.
+	for _, idx := range det.ranked {
+		if dictHitRatio([]string{w}, idx) > 0 {
+			return dictLangs[idx].String()
+		}
+	}
+
+	// Or that has all of its parts, if it's a compound word (glyph names
+	// look like hyphenated words, so they are excluded).
+	if isGlyphNames(word) {
+		return ""
+	}
+	for _, idx := range det.ranked {
+		if splitCompound(w, IPADicts[idx]) != nil {
+			return dictLangs[idx].String()
+		}
+	}
+
+	return ""
+}
+
+// detectCmd implements the "detect" command, which reports the detected
+// language of the phrase, and the language used for each word.
+func detectCmd(w io.Writer, words []string, opts Options) {
+	det := detectLanguage(words, opts.langs)
+	fmt.Fprintf(w, "Language: %s\n", det)
+	for _, word := range words {
+		lang, _ := resolveWordLang(word, det, opts)
+		if lang == "" {
+			lang = "(glyph names)"
+		}
+		fmt.Fprintf(w, "  %s: %s\n", word, lang)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+
+	"golang.org/x/text/language"
+)
+
+func TestDetectLanguage(t *testing.T) {
+	cases := []struct {
+		words    []string
+		prefs    []language.Tag
+		expected string
+	}{
+		{[]string{"the", "house"}, nil, "en-US"},
+		{[]string{"je", "suis", "très", "fatigué"}, nil, "fr"},
+		{[]string{"ich", "möchte", "schlafen"}, nil, "de"},
+		{[]string{"não", "obrigação"}, nil, "pt"},
+		{[]string{"figlio", "pizzeria", "gnomi"}, nil, "it"},
+
+		// Prefixed words and glyph names are not considered.
+		{[]string{"fr:bonjour", "SH-fEEt-R-All"}, nil, "en-US"},
+
+		// Without evidence, the preferred languages are used.
+		{[]string{"SH-fEEt"}, []language.Tag{language.German}, "de"},
+		{[]string{"xyzzy"}, []language.Tag{language.Make("it-IT")}, "it"},
+
+		// Names are no evidence either.
+		{[]string{"Hordak"}, nil, "en-US"},
+		{[]string{"catra", "adora"}, nil, "en-US"},
+		{[]string{"Hordak"}, []language.Tag{language.Italian}, "it"},
+
+		// But the evidence wins.
+		{[]string{"the", "house"}, []language.Tag{language.French}, "en-US"},
+	}
+	for _, c := range cases {
+		det := detectLanguage(c.words, c.prefs)
+		if det.lang.String() != c.expected {
+			t.Errorf("detectLanguage(%q, %v) = %v, expected %s",
+				c.words, c.prefs, det, c.expected)
+		}
+	}
+}
+
+func TestDetectConfidence(t *testing.T) {
+	det := detectLanguage([]string{"SH-fEEt-R-All"}, nil)
+	if det.confidence != 0 || det.words != 0 {
+		t.Errorf("no words: got %v (%d words)", det, det.words)
+	}
+
+	det = detectLanguage([]string{"ich", "möchte", "schlafen"}, nil)
+	if det.confidence < minG2PConfidence {
+		t.Errorf("german: got %v, expected confidence >= %v",
+			det, minG2PConfidence)
+	}
+}
+
+func TestWordLang(t *testing.T) {
+	words := []string{"je", "suis", "très", "fatigué", "SH-fEEt", "catra"}
+	det := detectLanguage(words, nil)
+	expected := []string{"fr", "fr", "fr", "fr", "", "fr"}
+	for i, word := range words {
+		if got := wordLang(word, det); got != expected[i] {
+			t.Errorf("wordLang(%q) = %q, expected %q", word, got, expected[i])
+		}
+	}
+
+	// With no confidence, unknown words are not converted with G2P.
+	det = detectLanguage([]string{"xyzzy"}, nil)
+	if got := wordLang("xyzzy", det); got != "" {
+		t.Errorf("wordLang(xyzzy) = %q, expected \"\"", got)
+	}
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// # English dialects
+//
+// We only have an American English dictionary (en_US), so the other dialects
+// are derived from it, by applying a few transformations to the IPA that
+// cover the most noticeable differences:
+//
+//   - Non-rhotic: the "r" is only pronounced before a vowel ("car",
+//     "bird", "here" lose it, "red" and "sorry" keep it).
+//   - TRAP-BATH split: "a" is pronounced like in "father" before some
+//     consonants ("bath", "fast", and in England also "dance", "plant").
+//   - LOT: the "o" in "lot" is a rounded vowel, not the one in "father".
+//   - GOAT: the "o" in "go" starts in the middle of the mouth.
+//
+// These are rules, so they get some words wrong (e.g. "gas" doesn't follow
+// the TRAP-BATH split); the most common exceptions are listed explicitly.
+//
+// The dialect is picked by region matching (e.g. "en-IN" gets the British
+// one), from the explicit language prefix, or the preferred languages.
+
+// An English dialect, as a set of transformations from the en_US IPA.
+type dialect struct {
+	// The "r" is dropped when it's not followed by a vowel.
+	nonRhotic bool
+
+	// "æ" becomes "ɑ" before "f", "θ" and "s" (when they are not followed by
+	// a vowel), and also before "n" and "m" clusters if trapBathNasal.
+	trapBath      bool
+	trapBathNasal bool
+
+	// "ɑ" becomes "ɒ" in words spelled with "o".
+	lot bool
+
+	// IPA to use for the GOAT vowel ("oʊ" in en_US), if not empty.
+	goat string
+
+	// Pronunciation of the words that the rules get wrong.
+	words map[string]string
+}
+
+// Supported dialects, the first one is the default.
+// The entries in dialects correspond to these; nil means that the dictionary
+// is used as is.
+var dialectTags = []language.Tag{
+	language.AmericanEnglish,
+	language.BritishEnglish,
+	language.MustParse("en-AU"),
+
+	// Otherwise they would match the British one.
+	language.MustParse("en-CA"),
+	language.MustParse("en-NZ"),
+}
+
+var dialects = []*dialect{
+	nil,
+	britishDialect,
+	australianDialect,
+	nil,
+	australianDialect,
+}
+
+var britishDialect = &dialect{
+	nonRhotic:     true,
+	trapBath:      true,
+	trapBathNasal: true,
+	lot:           true,
+	goat:          "əʊ",
+	words: map[string]string{
+		"ant":      "ˈænt",
+		"gas":      "ˈɡæs",
+		"mass":     "ˈmæs",
+		"math":     "ˈmæθ",
+		"maths":    "ˈmæθs",
+		"rather":   "ˈɹɑðə",
+		"schedule": "ˈʃɛˌdjuɫ",
+		"tomato":   "təˈmɑˌtəʊ",
+		"tomatoes": "təˈmɑˌtəʊz",
+		"vase":     "ˈvɑz",
+	},
+}
+
+var australianDialect = &dialect{
+	nonRhotic: true,
+	trapBath:  true,
+	lot:       true,
+	goat:      "əʊ",
+	words: map[string]string{
+		"gas":      "ˈɡæs",
+		"mass":     "ˈmæs",
+		"math":     "ˈmæθ",
+		"maths":    "ˈmæθs",
+		"rather":   "ˈɹɑðə",
+		"tomato":   "təˈmɑˌtəʊ",
+		"tomatoes": "təˈmɑˌtəʊz",
+	},
+}
+
+var dialectMatcher = language.NewMatcher(dialectTags)
+
+// isEnglish returns true if the language is a variant of English.
+func isEnglish(lang string) bool {
+	// Note that the base of an unknown language is guessed as English, so
+	// we need to check the confidence.
+	base, conf := language.Make(lang).Base()
+	return conf == language.Exact && base.String() == "en"
+}
+
+// matchDialect returns the index of the dialect (in dialectTags) closest to
+// the given language, which must be English.
+func matchDialect(tag language.Tag) int {
+	_, idx, _ := dialectMatcher.Match(tag)
+	return idx
+}
+
+// parseDialect parses the name of a dialect given by the user (e.g.
+// "en-GB"), and returns the closest one we support.
+func parseDialect(s string) (language.Tag, error) {
+	tag, err := language.Parse(s)
+	if err != nil {
+		return language.Tag{}, fmt.Errorf("invalid dialect %q: %v", s, err)
+	}
+	if !isEnglish(tag.String()) {
+		return language.Tag{}, fmt.Errorf(
+			"invalid dialect %q: only English dialects are supported", s)
+	}
+	return dialectTags[matchDialect(tag)], nil
+}
+
+// preferredDialect returns the dialect for the first of the preferred
+// languages which is English, or the default one if there isn't any.
+func preferredDialect(prefs []language.Tag) language.Tag {
+	for _, pref := range prefs {
+		if isEnglish(pref.String()) {
+			return dialectTags[matchDialect(pref)]
+		}
+	}
+	return dialectTags[0]
+}
+
+// langDialect returns the dialect to use for the language, given as an IPA
+// dictionary language (e.g. "en-GB"), or nil if it doesn't need any
+// transformations (it's not English, or it's an American-like dialect).
+func langDialect(lang string) *dialect {
+	if !isEnglish(lang) {
+		return nil
+	}
+	tag := language.Make(lang)
+	if _, conf := tag.Region(); conf != language.Exact {
+		return nil
+	}
+	return dialects[matchDialect(tag)]
+}
+
+// transform the en_US IPA of the word into the dialect.
+func (d *dialect) transform(word, ipa string) string {
+	if d == nil {
+		return ipa
+	}
+	if w, ok := d.words[strings.ToLower(word)]; ok {
+		return w
+	}
+
+	lower := strings.ToLower(word)
+	segs := ipaSegments(ipa)
+
+	// next returns the index of the next segment after i, skipping the
+	// stress marks and syllable separators, or len(segs) if there isn't any.
+	next := func(i int) int {
+		for i++; i < len(segs); i++ {
+			if _, ok := syllableMarks[segs[i]]; !ok {
+				break
+			}
+		}
+		return i
+	}
+	segAt := func(i int) string {
+		if i < len(segs) {
+			return segs[i]
+		}
+		return ""
+	}
+	// Whether the "r" at i is dropped.
+	dropsR := func(i int) bool {
+		return d.nonRhotic && segAt(i) == "ɹ" && !isIPAVowel(segAt(next(i)))
+	}
+
+	out := []string{}
+	stressed := false
+	for i, seg := range segs {
+		n := next(i)
+		switch {
+		case seg == "ˈ" || seg == "ˌ":
+			stressed = true
+
+		case seg == "oʊ" && d.goat != "":
+			seg = d.goat
+
+		case seg == "ɑ" && d.lot && strings.Contains(lower, "o") &&
+			!dropsR(n):
+			// "lot", "sorry", but not "for".
+			seg = "ɒ"
+
+		case seg == "æ" && d.trapBath && d.bathConsonants(segs[n:]):
+			seg = "ɑ"
+
+		case seg == "ɝ" && d.nonRhotic:
+			if stressed {
+				seg = "ɜ"
+			} else {
+				seg = "ə"
+			}
+			if isIPAVowel(segAt(n)) {
+				// Linking "r", e.g. "hurry".
+				seg += "ɹ"
+			}
+
+		case seg == "ɹ" && dropsR(i):
+			// The vowels before it become centering diphthongs, e.g.
+			// "here", "hair", "sure".
+			if len(out) > 0 {
+				switch out[len(out)-1] {
+				case "i", "ɪ":
+					out[len(out)-1] = "ɪə"
+				case "ɛ", "e":
+					out[len(out)-1] = "ɛə"
+				case "ʊ", "u":
+					out[len(out)-1] = "ʊə"
+				case "aɪ", "aʊ":
+					out[len(out)-1] += "ə"
+				}
+			}
+			continue
+		}
+
+		if isIPAVowel(seg) {
+			// The stress mark only applies to the first vowel after it.
+			stressed = false
+		}
+		out = append(out, seg)
+	}
+	return strings.Join(out, "")
+}
+
+// bathConsonants returns true if the consonants (the segments following an
+// "æ") trigger the TRAP-BATH split.
+func (d *dialect) bathConsonants(segs []string) bool {
+	at := func(i int) string {
+		if i < len(segs) {
+			return segs[i]
+		}
+		return ""
+	}
+	// Not followed by a vowel, e.g. "bath", "fast", but not "passage".
+	closed := func(i int) bool {
+		for ; i < len(segs); i++ {
+			if _, ok := syllableMarks[segs[i]]; !ok {
+				return !isIPAVowel(segs[i])
+			}
+		}
+		return true
+	}
+
+	switch at(0) {
+	case "f", "θ", "s":
+		return closed(1)
+	case "n":
+		// "dance", "plant", "branch", "answer".
+		return d.trapBathNasal &&
+			(at(1) == "s" || at(1) == "t" || at(1) == "tʃ")
+	case "m":
+		// "example", "sample".
+		return d.trapBathNasal && at(1) == "p" &&
+			(at(2) == "ɫ" || at(2) == "ə" && at(3) == "ɫ")
+	}
+	return false
+}
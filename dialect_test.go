@@ -0,0 +1,104 @@
+package main
+
+import (
+	"testing"
+
+	"golang.org/x/text/language"
+)
+
+func TestDialectTransform(t *testing.T) {
+	cases := []struct {
+		d        *dialect
+		word     string
+		ipa      string
+		expected string
+	}{
+		// Non-rhotic.
+		{britishDialect, "car", "ˈkɑɹ", "ˈkɑ"},
+		{britishDialect, "bird", "ˈbɝd", "ˈbɜd"},
+		{britishDialect, "butter", "ˈbətɝ", "ˈbətə"},
+		{britishDialect, "here", "ˈhiɹ", "ˈhɪə"},
+		{britishDialect, "hair", "ˈhɛɹ", "ˈhɛə"},
+		{britishDialect, "red", "ˈɹɛd", "ˈɹɛd"},
+		{britishDialect, "hurry", "ˈhɝi", "ˈhɜɹi"},
+
+		// TRAP-BATH.
+		{britishDialect, "bath", "ˈbæθ", "ˈbɑθ"},
+		{britishDialect, "fast", "ˈfæst", "ˈfɑst"},
+		{britishDialect, "dance", "ˈdæns", "ˈdɑns"},
+		{britishDialect, "cat", "ˈkæt", "ˈkæt"},
+		{britishDialect, "passage", "ˈpæsɪdʒ", "ˈpæsɪdʒ"},
+		{britishDialect, "gas", "ˈɡæs", "ˈɡæs"},
+		{australianDialect, "dance", "ˈdæns", "ˈdæns"},
+		{australianDialect, "bath", "ˈbæθ", "ˈbɑθ"},
+
+		// LOT and GOAT.
+		{britishDialect, "lot", "ˈɫɑt", "ˈɫɒt"},
+		{britishDialect, "sorry", "ˈsɑɹi", "ˈsɒɹi"},
+		{britishDialect, "father", "ˈfɑðɝ", "ˈfɑðə"},
+		{britishDialect, "go", "ˈɡoʊ", "ˈɡəʊ"},
+
+		// Exceptions.
+		{britishDialect, "Tomato", "təˈmeɪˌtoʊ", "təˈmɑˌtəʊ"},
+
+		// No dialect, no changes.
+		{nil, "car", "ˈkɑɹ", "ˈkɑɹ"},
+	}
+	for _, c := range cases {
+		got := c.d.transform(c.word, c.ipa)
+		if got != c.expected {
+			t.Errorf("transform(%q, %q) = %q, expected %q",
+				c.word, c.ipa, got, c.expected)
+		}
+	}
+}
+
+func TestDialectMatching(t *testing.T) {
+	cases := []struct {
+		lang     string
+		expected *dialect
+	}{
+		{"en", nil},
+		{"en-US", nil},
+		{"en-CA", nil},
+		{"en-GB", britishDialect},
+		{"en-IN", britishDialect},
+		{"en-AU", australianDialect},
+		{"en-NZ", australianDialect},
+		{"es-ES", nil},
+		{"", nil},
+	}
+	for _, c := range cases {
+		if got := langDialect(c.lang); got != c.expected {
+			t.Errorf("langDialect(%q) = %p, expected %p",
+				c.lang, got, c.expected)
+		}
+	}
+
+	prefs := []language.Tag{language.French, language.MustParse("en-AU")}
+	if got := preferredDialect(prefs); got.String() != "en-AU" {
+		t.Errorf("preferredDialect(%v) = %v", prefs, got)
+	}
+	if got := preferredDialect(nil); got != language.AmericanEnglish {
+		t.Errorf("preferredDialect(nil) = %v", got)
+	}
+
+	if _, err := parseDialect("fr"); err == nil {
+		t.Errorf(`parseDialect("fr") did not fail`)
+	}
+}
+
+func TestDialectWords(t *testing.T) {
+	opts := Options{dialect: language.BritishEnglish}
+	det := detectLanguage([]string{"bath"}, nil)
+	word, err := smartWordToGlyphs("bath", det, opts)
+	if err != nil || word.String() != "B-All-TH" {
+		t.Errorf("bath in en-GB: %v, %v", word, err)
+	}
+
+	// An explicit region takes precedence.
+	word, err = smartWordToGlyphs("en-US:bath", det, opts)
+	if err != nil || word.String() != "B-sAd-TH" {
+		t.Errorf("en-US:bath in en-GB: %v, %v", word, err)
+	}
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/language/display"
+)
+
+// # Dictionary formats
+//
+// Besides the embedded dictionaries, more can be loaded at startup with the
+// -dict flag. They can be in different formats, each one handled by a
+// DictReader:
+//
+//   - "ipa-dict": the open-dict-data tab-delimited format, used by the
+//     embedded dictionaries (word<TAB>/ipa/, /ipa2/, ...).
+//   - "cmudict": the CMU Pronouncing Dictionary format, which uses ARPAbet
+//     instead of IPA (WORD  HH AH0 L OW1). It is converted to IPA.
+//   - "csv": a plain two-column CSV file (word,ipa), with an optional
+//     header. The IPA can be optionally surrounded by slashes.
+
+// DictReader reads a dictionary in a specific format.
+type DictReader interface {
+	// Read the dictionary from r, adding the words to dict.
+	Read(r io.Reader, dict IPADict) error
+}
+
+// The known dictionary readers, by format name.
+var dictReaders = map[string]DictReader{
+	"ipa-dict": ipaDictReader{},
+	"cmudict":  cmuDictReader{},
+	"csv":      csvDictReader{},
+}
+
+// dictFormatFor guesses the format of the dictionary from its file name.
+func dictFormatFor(path string) string {
+	base := strings.ToLower(filepath.Base(path))
+	switch {
+	case strings.HasSuffix(base, ".csv"):
+		return "csv"
+	case strings.HasSuffix(base, ".dict"), strings.Contains(base, "cmudict"):
+		return "cmudict"
+	}
+	return "ipa-dict"
+}
+
+// ipaDictReader reads dictionaries in the open-dict-data format.
+type ipaDictReader struct{}
+
+func (ipaDictReader) Read(r io.Reader, dict IPADict) error {
+	return parseIPADict(r, dict)
+}
+
+// csvDictReader reads dictionaries in a two-column CSV format.
+type csvDictReader struct{}
+
+func (csvDictReader) Read(r io.Reader, dict IPADict) error {
+	cr := csv.NewReader(r)
+	cr.Comment = '#'
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	for first := true; ; first = false {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(rec) < 2 {
+			line, _ := cr.FieldPos(0)
+			return fmt.Errorf("line %d: expected 2 columns, got %d",
+				line, len(rec))
+		}
+
+		word := strings.TrimSpace(rec[0])
+		ipa := strings.Trim(strings.TrimSpace(rec[1]), "/")
+		if word == "" || ipa == "" {
+			continue
+		}
+		if first && strings.EqualFold(word, "word") {
+			// Skip the header, if there is one.
+			continue
+		}
+		if _, ok := dict[word]; !ok {
+			// Keep the first pronunciation, like the other formats.
+			dict[word] = ipa
+		}
+	}
+}
+
+// cmuDictReader reads dictionaries in the CMU Pronouncing Dictionary format.
+type cmuDictReader struct{}
+
+func (cmuDictReader) Read(r io.Reader, dict IPADict) error {
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, ";;;") {
+			continue
+		}
+
+		// Comments can also appear at the end of the line, after the
+		// pronunciation. Words can start with "#" (like "#HASH-MARK"), so
+		// the first field is never one.
+		fields := strings.Fields(line)
+		for i := 1; i < len(fields); i++ {
+			if strings.HasPrefix(fields[i], "#") {
+				fields = fields[:i]
+				break
+			}
+		}
+		if len(fields) < 2 {
+			continue
+		}
+
+		// Alternative pronunciations appear as "WORD(2)". We only keep the
+		// first one, like the other formats.
+		word := strings.ToLower(fields[0])
+		if strings.HasSuffix(word, ")") {
+			continue
+		}
+
+		ipa, err := arpabetToIPA(fields[1:])
+		if err != nil {
+			return fmt.Errorf("line %d: %v", n, err)
+		}
+		dict[word] = ipa
+	}
+	return scanner.Err()
+}
+
+// ARPAbet to IPA mapping, for the symbols used in CMUdict.
+// The vowels are chosen to match the ones used in the embedded en_US
+// dictionary (e.g. "AH" is "ə" even when stressed).
+var arpabetIPA = map[string]string{
+	// Vowels.
+	"AA": "ɑ", "AE": "æ", "AH": "ə", "AO": "ɔ", "AW": "aʊ", "AY": "aɪ",
+	"EH": "ɛ", "ER": "ɝ", "EY": "eɪ", "IH": "ɪ", "IY": "i", "OW": "oʊ",
+	"OY": "ɔɪ", "UH": "ʊ", "UW": "u",
+
+	// Consonants.
+	"B": "b", "CH": "tʃ", "D": "d", "DH": "ð", "F": "f", "G": "ɡ",
+	"HH": "h", "JH": "dʒ", "K": "k", "L": "ɫ", "M": "m", "N": "n",
+	"NG": "ŋ", "P": "p", "R": "ɹ", "S": "s", "SH": "ʃ", "T": "t",
+	"TH": "θ", "V": "v", "W": "w", "Y": "j", "Z": "z", "ZH": "ʒ",
+}
+
+// arpabetToIPA converts a sequence of ARPAbet phonemes to IPA.
+// The stress of the vowels (given as a 0, 1 or 2 suffix) is converted to
+// IPA stress marks at the beginning of the syllable.
+func arpabetToIPA(phonemes []string) (string, error) {
+	ipa := ""
+	stresses := []Stress{}
+	for _, p := range phonemes {
+		base := strings.TrimRight(p, "012")
+		sym, ok := arpabetIPA[base]
+		if !ok {
+			return "", fmt.Errorf("unknown ARPAbet phoneme %q", p)
+		}
+		ipa += sym
+
+		if base != p {
+			// Only vowels have a stress suffix.
+			switch p[len(base):] {
+			case "1":
+				stresses = append(stresses, primaryStress)
+			case "2":
+				stresses = append(stresses, secondaryStress)
+			default:
+				stresses = append(stresses, unstressed)
+			}
+		}
+	}
+
+	// ARPAbet gives us the stress of each vowel, but IPA puts the stress
+	// marks at the beginning of the syllables. So we find the syllables,
+	// and if there is one per vowel (which should be the normal case), add
+	// the marks.
+	syllables := syllabifyIPA(ipa)
+	if len(syllables) != len(stresses) {
+		return ipa, nil
+	}
+
+	marks := map[Stress]string{primaryStress: "ˈ", secondaryStress: "ˌ"}
+	ipa = ""
+	for i, syl := range syllables {
+		ipa += marks[stresses[i]] + syl.String()
+	}
+	return ipa, nil
+}
+
+// The languages of the dictionaries in IPADicts; the index in this slice is
+// the key in IPADicts, which is what the langMatcher returns.
+var dictLangs = []language.Tag{}
+
+// The indices (like in IPADicts) of the languages that have a dictionary,
+// and not only G2P rules (see registerG2PLangs).
+var fromDict = map[int]bool{}
+
+// registerDict adds the dictionary for the given language, and updates the
+// language matcher.
+// If there is already a dictionary for the language (e.g. "en-US" for "en"),
+// the new words are merged into it; the existing ones take precedence.
+func registerDict(lang language.Tag, dict IPADict) {
+	fromDict[addDict(lang, dict)] = true
+}
+
+// addDict is like registerDict, but doesn't record that the language has a
+// dictionary. It returns the index of the dictionary.
+func addDict(lang language.Tag, dict IPADict) int {
+	if langMatcher != nil {
+		_, idx, confidence := langMatcher.Match(lang)
+		if confidence == language.Exact {
+			existing := IPADicts[idx]
+			for word, ipa := range dict {
+				if _, ok := existing[word]; !ok {
+					existing[word] = ipa
+				}
+			}
+			return idx
+		}
+	}
+
+	// Add the namesIPA to all dictionaries.
+	// This also overrides the word if it exists. That's okay.
+	for name, ipa := range namesIPA {
+		dict[name] = ipa
+	}
+
+	// The order in which we add it to dictLangs identifies this dictionary.
+	// The matcher will return this index when doing a match.
+	idx := len(dictLangs)
+	IPADicts[idx] = dict
+	dictLangs = append(dictLangs, lang)
+
+	langMatcher = language.NewMatcher(
+		dictLangs, language.PreferSameScript(true))
+	return idx
+}
+
+// supportedLangs returns the supported languages, in the order they were
+// registered.
+func supportedLangs() []string {
+	langs := []string{}
+	for _, tag := range dictLangs {
+		langs = append(langs, tag.String())
+	}
+	return langs
+}
+
+// supportedLangNames returns the (English) names of the supported languages,
+// for display.
+func supportedLangNames() []string {
+	names := []string{}
+	for _, tag := range dictLangs {
+		names = append(names, display.English.Tags().Name(tag))
+	}
+	return names
+}
+
+// readDict reads a dictionary from the given file, in the given format
+// (or guessing it from the file name, if empty).
+func readDict(path, format string) (IPADict, error) {
+	if format == "" {
+		format = dictFormatFor(path)
+	}
+	reader, ok := dictReaders[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown dictionary format %q", format)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	dict := IPADict{}
+	if err := reader.Read(f, dict); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return dict, nil
+}
+
+// dictFlag holds the values of the -dict flag, which can be given multiple
+// times. Each value is "lang=[format:]path".
+type dictFlag []string
+
+func (f *dictFlag) String() string {
+	return strings.Join(*f, " ")
+}
+
+func (f *dictFlag) Set(v string) error {
+	if _, _, _, err := parseDictFlag(v); err != nil {
+		return err
+	}
+	*f = append(*f, v)
+	return nil
+}
+
+// parseDictFlag parses a -dict flag value.
+func parseDictFlag(v string) (lang language.Tag, format, path string, err error) {
+	langS, path, ok := strings.Cut(v, "=")
+	if !ok || path == "" {
+		return lang, "", "", fmt.Errorf("expected lang=[format:]path")
+	}
+	lang, err = language.Parse(langS)
+	if err != nil {
+		return lang, "", "", fmt.Errorf("invalid language %q: %v", langS, err)
+	}
+	if f, p, ok := strings.Cut(path, ":"); ok {
+		if _, known := dictReaders[f]; known {
+			format, path = f, p
+		}
+	}
+	return lang, format, path, nil
+}
+
+// loadDicts loads and registers the dictionaries given in the -dict flags.
+func loadDicts(values []string) error {
+	for _, v := range values {
+		lang, format, path, err := parseDictFlag(v)
+		if err != nil {
+			return err
+		}
+		dict, err := readDict(path, format)
+		if err != nil {
+			return err
+		}
+		registerDict(lang, dict)
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/text/language"
+)
+
+func TestArpabetToIPA(t *testing.T) {
+	cases := []struct {
+		arpabet  string
+		expected string
+	}{
+		{"HH AH0 L OW1", "həˈɫoʊ"},
+		{"AH0 D AO1 R AH0", "əˈdɔɹə"},
+		{"EH1 K S T R AH0", "ˈɛkstɹə"},
+		{"T AH0 M EY1 T OW2", "təˈmeɪˌtoʊ"},
+		{"SH IY1", "ˈʃi"},
+
+		// Without stress (not common, but possible).
+		{"SH IY", "ʃi"},
+	}
+	for _, c := range cases {
+		got, err := arpabetToIPA(strings.Fields(c.arpabet))
+		if err != nil {
+			t.Errorf("arpabetToIPA(%q): %v", c.arpabet, err)
+		}
+		if got != c.expected {
+			t.Errorf("arpabetToIPA(%q) = %q, expected %q",
+				c.arpabet, got, c.expected)
+		}
+	}
+
+	if _, err := arpabetToIPA([]string{"XX1"}); err == nil {
+		t.Errorf("arpabetToIPA with unknown phoneme did not fail")
+	}
+}
+
+func TestDictReaders(t *testing.T) {
+	cases := []struct {
+		format, content string
+		expected        IPADict
+	}{
+		{
+			"ipa-dict",
+			"hello\t/həˈɫoʊ/, /hɛˈɫoʊ/\nbad line\n",
+			IPADict{"hello": "həˈɫoʊ"},
+		},
+		{
+			"cmudict",
+			";;; Comment.\n" +
+				"HELLO  HH AH0 L OW1\n" +
+				"HELLO(1)  HH EH0 L OW1\n" +
+				"SHE  SH IY1 # Comment.\n" +
+				"#HASH-MARK  HH AE1 SH M AA2 R K #Comment.\n",
+			IPADict{"hello": "həˈɫoʊ", "she": "ˈʃi",
+				"#hash-mark": "ˈhæʃˌmɑɹk"},
+		},
+		{
+			"csv",
+			"word,ipa\n# Comment.\nhello,/həˈɫoʊ/\nshe, ʃi\nshe,ʃe\n",
+			IPADict{"hello": "həˈɫoʊ", "she": "ʃi"},
+		},
+	}
+	for _, c := range cases {
+		dict := IPADict{}
+		err := dictReaders[c.format].Read(strings.NewReader(c.content), dict)
+		if err != nil {
+			t.Errorf("%s: error: %v", c.format, err)
+		}
+		if diff := cmp.Diff(c.expected, dict); diff != "" {
+			t.Errorf("%s: mismatch:\n%s", c.format, diff)
+		}
+	}
+
+	// Errors.
+	for format, content := range map[string]string{
+		"cmudict": "HELLO  HH AH0 XX OW1\n",
+		"csv":     "hello\n",
+	} {
+		err := dictReaders[format].Read(strings.NewReader(content), IPADict{})
+		if err == nil {
+			t.Errorf("%s: expected error", format)
+		}
+	}
+}
+
+func TestDictFormatFor(t *testing.T) {
+	cases := map[string]string{
+		"en_US.txt":      "ipa-dict",
+		"dir/names.CSV":  "csv",
+		"cmudict-0.7b":   "cmudict",
+		"words.dict":     "cmudict",
+		"something-else": "ipa-dict",
+	}
+	for path, expected := range cases {
+		if got := dictFormatFor(path); got != expected {
+			t.Errorf("dictFormatFor(%q) = %q, expected %q",
+				path, got, expected)
+		}
+	}
+}
+
+func TestParseDictFlag(t *testing.T) {
+	cases := []struct {
+		v              string
+		lang           language.Tag
+		format, path   string
+		expectedToFail bool
+	}{
+		{"en=a.dict", language.English, "", "a.dict", false},
+		{"en-GB=csv:a.txt", language.BritishEnglish, "csv", "a.txt", false},
+		{"en=c:/a.txt", language.English, "", "c:/a.txt", false},
+		{"a.txt", language.Und, "", "", true},
+		{"en=", language.Und, "", "", true},
+		{"@@=a.txt", language.Und, "", "", true},
+	}
+	for _, c := range cases {
+		lang, format, path, err := parseDictFlag(c.v)
+		if (err != nil) != c.expectedToFail {
+			t.Errorf("parseDictFlag(%q): unexpected error %v", c.v, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if lang != c.lang || format != c.format || path != c.path {
+			t.Errorf("parseDictFlag(%q) = %v, %q, %q", c.v, lang, format, path)
+		}
+	}
+}
+
+// frIdx returns the index of the French dictionary.
+func frIdx(t *testing.T) int {
+	t.Helper()
+	_, idx, conf := langMatcher.Match(language.French)
+	if conf != language.Exact {
+		t.Fatalf("no French dictionary")
+	}
+	return idx
+}
+
+func TestRegisterDict(t *testing.T) {
+	// Save the global state, and restore it at the end.
+	origDicts := maps.Clone(IPADicts)
+	origLangs := slices.Clone(dictLangs)
+	origMatcher := langMatcher
+	origFromDict := maps.Clone(fromDict)
+	origEn := maps.Clone(IPADicts[0])
+	origFr := maps.Clone(IPADicts[frIdx(t)])
+	defer func() {
+		IPADicts, dictLangs, langMatcher = origDicts, origLangs, origMatcher
+		fromDict = origFromDict
+		IPADicts[0] = origEn
+		IPADicts[frIdx(t)] = origFr
+	}()
+
+	// French only has G2P rules, until we load a dictionary for it.
+	if hasDict(frIdx(t)) {
+		t.Errorf("fr has a dictionary before loading one")
+	}
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "en.dict"),
+		[]byte("HELLO  HH EH1 L OW0\nZZTOP  Z IY1 Z IY1 T AA1 P\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "fr.csv"),
+		[]byte("bonjour,bɔ̃ʒuʁ\n"), 0o644)
+
+	err := loadDicts([]string{
+		"en=" + filepath.Join(dir, "en.dict"),
+		"fr=" + filepath.Join(dir, "fr.csv"),
+	})
+	if err != nil {
+		t.Fatalf("loadDicts: %v", err)
+	}
+
+	// "en" was merged with the existing "en-US", and the existing words
+	// take precedence.
+	if ipa, _, _ := lookupIPA("zztop", "en"); ipa != "ˈziˈziˈtɑp" {
+		t.Errorf("zztop = %q", ipa)
+	}
+	if ipa, _, _ := lookupIPA("hello", "en"); ipa != "həˈɫoʊ" {
+		t.Errorf("hello = %q", ipa)
+	}
+
+	// "fr" was merged with the G2P one, and now it has a dictionary, even
+	// if it's smaller than the names.
+	if !hasDict(frIdx(t)) {
+		t.Errorf("fr does not have a dictionary after loading one")
+	}
+	if ipa, _, _ := lookupIPA("bonjour", "fr"); ipa != "bɔ̃ʒuʁ" {
+		t.Errorf("bonjour = %q", ipa)
+	}
+	if ipa, _, _ := lookupIPA("etheria", "fr"); ipa != namesIPA["etheria"] {
+		t.Errorf("fr dictionary does not include the names")
+	}
+
+	if err := loadDicts([]string{"en=/does/not/exist"}); err == nil {
+		t.Errorf("loadDicts with missing file did not fail")
+	}
+	if err := loadDicts([]string{"en=xx:" + dir + "/en.dict"}); err == nil {
+		t.Errorf("loadDicts with unknown format did not fail")
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// # DXF
+//
+// The "dxf" command writes the words as a DXF drawing, for laser cutters
+// and CNC tools. It uses the old (R12) ASCII format, which about every CAD
+// and CAM program can read, with the units in millimetres.
+//
+// The shapes are written as polylines and circles, in separate layers for
+// the word lines and the glyphs, and there can be a rectangular border, in
+// a layer of its own. The "dxf" output backend writes the same, so the other
+// commands (with -o) and the HTTP server can use it too.
+//
+// By default the strokes are written as their centre lines, which is what
+// engraving needs. For cutting, -outline writes the outlines of the
+// strokes instead (see outlineShapes), with their width (which can be
+// changed with -outline-width), and optionally grown by half the kerf, so
+// the cut pieces have the right size. Each layer is outlined on its own, so
+// overlaps between them are not merged.
+
+var (
+	borderFlag = flag.Float64("border", 0,
+		"in dxf, draw a border this many millimetres around the words; "+
+			"0 for none")
+	kerfFlag = flag.Float64("kerf", 0,
+		"in dxf outlines, width of the cut in millimetres; the outlines "+
+			"are grown by half of it")
+)
+
+// Limits, so the values make some sense.
+const (
+	maxBorder = 100
+	maxKerf   = 2
+)
+
+// The layers, with their colors (as AutoCAD color indexes).
+var dxfLayers = []struct {
+	name  string
+	color int
+}{
+	{"WORD-LINE", 1}, // Red.
+	{"GLYPHS", 7},    // White (or black, depending on the background).
+	{"BORDER", 3},    // Green.
+}
+
+// dxfLayer returns the layer for the shape.
+func dxfLayer(s shape) string {
+	if s.class == "word-line" {
+		return "WORD-LINE"
+	}
+	return "GLYPHS"
+}
+
+// dxfOptions are the options for the DXF output.
+type dxfOptions struct {
+	border  float64
+	outline outlineOptions
+	kerf    float64
+}
+
+// dxfCmd implements the "dxf" command: it writes the words as DXF to the
+// output file given with -o (in the format of its extension), or to w.
+func dxfCmd(w io.Writer, words []string, opts Options) error {
+	doc, err := renderDocument(words, opts, false)
+	if err != nil {
+		return err
+	}
+	return writeDocument(w, doc, "dxf")
+}
+
+// dxfBackend writes the words of the document as a DXF drawing, with the
+// border and kerf given by the flags.
+type dxfBackend struct{}
+
+func (dxfBackend) contentType() string {
+	return "image/vnd.dxf"
+}
+
+func (dxfBackend) write(w io.Writer, doc *document) error {
+	do := dxfOptions{
+		border: *borderFlag, outline: doc.opts.outline, kerf: *kerfFlag}
+	switch {
+	case do.border < 0 || do.border > maxBorder:
+		return fmt.Errorf("border must be between 0 and %d", maxBorder)
+	case do.kerf < 0 || do.kerf > maxKerf:
+		return fmt.Errorf("kerf must be between 0 and %d", maxKerf)
+	case do.kerf > 0 && !do.outline.enabled:
+		return fmt.Errorf("kerf can only be used with -outline")
+	}
+
+	sc, err := doc.wordsScene()
+	if err != nil {
+		return err
+	}
+	buf := &bytes.Buffer{}
+	writeDXF(buf, sc, doc.opts.style.resolved().width, do)
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// writeDXF writes the scene as a DXF drawing. The base stroke width is the
+// one of the style, see outlineOptions.shapes.
+func writeDXF(buf *bytes.Buffer, sc *scene, base float64, do dxfOptions) {
+	d := &dxfWriter{buf: buf, height: sc.height}
+
+	d.pair(0, "SECTION")
+	d.pair(2, "HEADER")
+	d.pair(9, "$ACADVER")
+	d.pair(1, "AC1009")
+	d.pair(9, "$INSUNITS")
+	d.pair(70, "4") // Millimetres.
+	d.pair(0, "ENDSEC")
+
+	d.pair(0, "SECTION")
+	d.pair(2, "TABLES")
+	d.pair(0, "TABLE")
+	d.pair(2, "LAYER")
+	d.pair(70, strconv.Itoa(len(dxfLayers)))
+	for _, l := range dxfLayers {
+		d.pair(0, "LAYER")
+		d.pair(2, l.name)
+		d.pair(70, "0")
+		d.pair(62, strconv.Itoa(l.color))
+		d.pair(6, "CONTINUOUS")
+	}
+	d.pair(0, "ENDTAB")
+	d.pair(0, "ENDSEC")
+
+	d.pair(0, "SECTION")
+	d.pair(2, "ENTITIES")
+	if do.outline.enabled {
+		for _, l := range dxfLayers {
+			shapes := []shape{}
+			for _, s := range sc.shapes {
+				if dxfLayer(s) == l.name {
+					shapes = append(shapes, s)
+				}
+			}
+			shapes = do.outline.shapes(shapes, base)
+			for _, sp := range outlineShapes(shapes, do.kerf/2) {
+				d.polyline(l.name, sp)
+			}
+		}
+	} else {
+		for _, s := range sc.shapes {
+			if !s.hasFill() && !s.hasStroke() {
+				continue
+			}
+			if s.circle {
+				d.circle(dxfLayer(s), s.center, s.r)
+				continue
+			}
+			for _, sp := range s.subpaths {
+				// Filled shapes are drawn by their edge.
+				if s.hasFill() {
+					sp.closed = true
+				}
+				d.polyline(dxfLayer(s), sp)
+			}
+		}
+	}
+
+	if do.border > 0 && len(sc.shapes) > 0 {
+		min, max := sceneBounds(sc)
+		m := do.border
+		if do.outline.enabled {
+			m += do.kerf / 2
+		}
+		d.polyline("BORDER", subpath{closed: true, points: []point{
+			{min.x - m, min.y - m}, {max.x + m, min.y - m},
+			{max.x + m, max.y + m}, {min.x - m, max.y + m},
+		}})
+	}
+	d.pair(0, "ENDSEC")
+	d.pair(0, "EOF")
+}
+
+// sceneBounds returns the bounding box of the shapes of the scene.
+func sceneBounds(sc *scene) (min, max point) {
+	min = point{math.Inf(1), math.Inf(1)}
+	max = point{math.Inf(-1), math.Inf(-1)}
+	for _, s := range sc.shapes {
+		smin, smax := s.bounds()
+		min = point{math.Min(min.x, smin.x), math.Min(min.y, smin.y)}
+		max = point{math.Max(max.x, smax.x), math.Max(max.y, smax.y)}
+	}
+	return min, max
+}
+
+// dxfWriter writes DXF group codes and values.
+type dxfWriter struct {
+	buf *bytes.Buffer
+
+	// Height of the drawing, to flip the Y axis: in DXF it goes up.
+	height float64
+}
+
+func (d *dxfWriter) pair(code int, value string) {
+	fmt.Fprintf(d.buf, "%d\n%s\n", code, value)
+}
+
+func (d *dxfWriter) num(code int, v float64) {
+	s := trimZeros(strconv.FormatFloat(v, 'f', 4, 64))
+	if s == "-0" {
+		s = "0"
+	}
+	d.pair(code, s)
+}
+
+// point writes the coordinates of p, with the given code for X (Y and Z
+// use the following ones).
+func (d *dxfWriter) point(code int, p point) {
+	d.num(code, p.x)
+	d.num(code+10, d.height-p.y)
+	d.num(code+20, 0)
+}
+
+func (d *dxfWriter) polyline(layer string, sp subpath) {
+	flags := "0"
+	if sp.closed {
+		flags = "1"
+	}
+	d.pair(0, "POLYLINE")
+	d.pair(8, layer)
+	d.pair(66, "1") // Vertices follow.
+	d.pair(70, flags)
+	d.point(10, point{0, d.height})
+	for _, p := range sp.points {
+		d.pair(0, "VERTEX")
+		d.pair(8, layer)
+		d.point(10, p)
+	}
+	d.pair(0, "SEQEND")
+	d.pair(8, layer)
+}
+
+func (d *dxfWriter) circle(layer string, c point, r float64) {
+	d.pair(0, "CIRCLE")
+	d.pair(8, layer)
+	d.point(10, c)
+	d.num(40, r)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"maps"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// dxfPairs parses the DXF into its (code, value) pairs.
+func dxfPairs(t *testing.T, dxf string) [][2]string {
+	t.Helper()
+	lines := strings.Split(strings.TrimSuffix(dxf, "\n"), "\n")
+	if len(lines)%2 != 0 {
+		t.Fatalf("odd number of lines: %d", len(lines))
+	}
+	pairs := [][2]string{}
+	for i := 0; i < len(lines); i += 2 {
+		if _, err := strconv.Atoi(lines[i]); err != nil {
+			t.Fatalf("line %d: invalid group code %q", i+1, lines[i])
+		}
+		pairs = append(pairs, [2]string{lines[i], lines[i+1]})
+	}
+	return pairs
+}
+
+func TestWriteDXF(t *testing.T) {
+	opts := Options{syllables: "manual", angle: defaultAngle,
+		sentences: "none"}
+	wsvg, w, h, err := wordsToSVG([]string{"hi"}, opts)
+	if err != nil {
+		t.Fatalf("wordsToSVG error: %v", err)
+	}
+	sc, err := wordsScene(wsvg, w, h)
+	if err != nil {
+		t.Fatalf("wordsScene error: %v", err)
+	}
+
+	cases := []struct {
+		do dxfOptions
+
+		// Number of entities in each layer.
+		entities map[string]int
+
+		// Whether all the polylines are closed.
+		closed bool
+	}{
+		// The word line has a line and two dots, and "hi" is one syllable
+		// with a connector and 2 glyphs.
+		{dxfOptions{},
+			map[string]int{"WORD-LINE": 3, "GLYPHS": 3}, false},
+		{dxfOptions{border: 5},
+			map[string]int{"WORD-LINE": 3, "GLYPHS": 3, "BORDER": 1}, false},
+		// The word line and the glyphs are one piece each.
+		{dxfOptions{outline: outlineOptions{enabled: true}, kerf: 0.2},
+			map[string]int{"WORD-LINE": 1, "GLYPHS": 1}, true},
+	}
+	for _, c := range cases {
+		buf := &bytes.Buffer{}
+		writeDXF(buf, sc, themes["default"].width, c.do)
+		pairs := dxfPairs(t, buf.String())
+		if last := pairs[len(pairs)-1]; last != [2]string{"0", "EOF"} {
+			t.Errorf("%+v: last pair is %v", c.do, last)
+		}
+
+		entities := map[string]int{}
+		closed := true
+		for i, p := range pairs {
+			if p[0] != "0" || (p[1] != "POLYLINE" && p[1] != "CIRCLE") {
+				continue
+			}
+			entities[pairs[i+1][1]]++
+			if p[1] == "POLYLINE" && pairs[i+3] != [2]string{"70", "1"} {
+				closed = false
+			}
+		}
+		if !maps.Equal(entities, c.entities) || closed != c.closed {
+			t.Errorf("%+v: got entities %v (closed %v), expected %v (%v)",
+				c.do, entities, closed, c.entities, c.closed)
+		}
+	}
+}
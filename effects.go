@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"maps"
+	"slices"
+)
+
+// # Effects
+//
+// Effects make the words look like they glow, or are carved or embossed,
+// using SVG filters and gradients. They are part of the style (see
+// style.effect), and are opt-in.
+//
+//   - glow: an outer glow, of the colors of the glyphs.
+//   - carved: the glyphs look cut into the surface, with a shadow on the
+//     top-left edges and a highlight on the bottom-right ones.
+//   - metal: embossed metal, with a gradient on the strokes and a specular
+//     light.
+//
+// The filters have fixed parameters (and no noise), so the output is
+// deterministic.
+//
+// Only the SVG output shows the effects. Other backends use the scene (see
+// parseScene), which ignores the filters, and paints the gradients with the
+// average of their colors. So in those, the effects degrade to the plain
+// style.
+
+type effect struct {
+	// The filter definition. It will be given the id "effect-<name>".
+	filter string
+
+	// Whether the glyph strokes are painted with a gradient, made from the
+	// stroke color (see gradientDefs).
+	gradient bool
+}
+
+var effects = map[string]effect{
+	"glow": {
+		filter: `<filter id="effect-glow" x="-50%" y="-50%"` +
+			` width="200%" height="200%">
+  <feGaussianBlur in="SourceGraphic" stdDeviation="0.8" result="blur" />
+  <feMerge>
+    <feMergeNode in="blur" />
+    <feMergeNode in="blur" />
+    <feMergeNode in="SourceGraphic" />
+  </feMerge>
+</filter>`,
+	},
+	"carved": {
+		filter: `<filter id="effect-carved" x="-10%" y="-10%"` +
+			` width="120%" height="120%">
+  <feOffset in="SourceAlpha" dx="-0.2" dy="-0.2" result="up" />
+  <feFlood flood-color="black" flood-opacity="0.6" />
+  <feComposite in2="up" operator="in" result="shadow" />
+  <feOffset in="SourceAlpha" dx="0.2" dy="0.2" result="down" />
+  <feFlood flood-color="white" flood-opacity="0.4" />
+  <feComposite in2="down" operator="in" result="highlight" />
+  <feMerge>
+    <feMergeNode in="highlight" />
+    <feMergeNode in="shadow" />
+    <feMergeNode in="SourceGraphic" />
+  </feMerge>
+</filter>`,
+	},
+	"metal": {
+		filter: `<filter id="effect-metal" x="-10%" y="-10%"` +
+			` width="120%" height="120%">
+  <feGaussianBlur in="SourceAlpha" stdDeviation="0.3" result="blur" />
+  <feSpecularLighting in="blur" surfaceScale="3" specularConstant="0.9"` +
+			` specularExponent="20" lighting-color="white" result="spec">
+    <feDistantLight azimuth="225" elevation="45" />
+  </feSpecularLighting>
+  <feComposite in="spec" in2="SourceAlpha" operator="in" result="light" />
+  <feComposite in="SourceGraphic" in2="light" operator="arithmetic"` +
+			` k1="0" k2="1" k3="1" k4="0" />
+</filter>`,
+		gradient: true,
+	},
+}
+
+// effectNames returns the names of the effects, sorted.
+func effectNames() []string {
+	return slices.Sorted(maps.Keys(effects))
+}
+
+// The id of the gradient for the strokes, see effect.gradient.
+const effectGradientID = "effect-gradient"
+
+// effectDefs returns the definitions needed by the effect of the style, if
+// any.
+func (s style) effectDefs() SVG {
+	e, ok := effects[s.effect]
+	if !ok {
+		return ""
+	}
+	defs := SVG("<defs> <!-- Effect: " + s.effect + " -->\n")
+	defs += SVG(e.filter + "\n")
+	if e.gradient {
+		defs += gradientDefs(s.stroke)
+	}
+	defs += SVG("</defs>\n")
+	return defs
+}
+
+// gradientDefs returns a gradient based on the given color, going from a
+// lighter to a darker version of it. It's in the coordinates of each glyph,
+// and repeats every few millimetres, like brushed metal.
+func gradientDefs(base string) SVG {
+	c, _ := parseColor(base, color.NRGBA{})
+	light := mixColor(c, color.NRGBA{255, 255, 255, 255}, 0.6)
+	dark := mixColor(c, color.NRGBA{0, 0, 0, 255}, 0.4)
+	return SVGfn(`<linearGradient id="%s" gradientUnits="userSpaceOnUse"`+
+		` x1="0" y1="0" x2="1" y2="3" spreadMethod="reflect">
+  <stop offset="0" stop-color="%s" />
+  <stop offset="0.5" stop-color="%s" />
+  <stop offset="1" stop-color="%s" />
+</linearGradient>`, effectGradientID, light, hexColor(c), dark)
+}
+
+// mixColor returns the color a mixed with b, in the proportion t (0 is a,
+// 1 is b), as a hex string.
+func mixColor(a, b color.NRGBA, t float64) string {
+	mix := func(x, y uint8) uint8 {
+		return uint8(float64(x)*(1-t) + float64(y)*t + 0.5)
+	}
+	return hexColor(color.NRGBA{
+		mix(a.R, b.R), mix(a.G, b.G), mix(a.B, b.B), 255})
+}
+
+func hexColor(c color.NRGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// glyphStroke returns the paint for the glyph strokes: the stroke color,
+// or the gradient if the effect uses one.
+func (s style) glyphStroke() string {
+	if e, ok := effects[s.effect]; ok && e.gradient {
+		return "url(#" + effectGradientID + ")"
+	}
+	return s.stroke
+}
+
+// withEffect wraps the SVG with the filter of the effect, if there is one.
+func (s style) withEffect(svg SVG) SVG {
+	if _, ok := effects[s.effect]; !ok {
+		return svg
+	}
+	return SVGfn(`<g filter="url(#effect-%s)">`, s.effect) +
+		indent(svg, 2) + SVG("</g>\n")
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"image/color"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestEffects(t *testing.T) {
+	for _, name := range append(effectNames(), "none") {
+		opts := Options{syllables: "manual", angle: defaultAngle,
+			sentences: "none"}
+		err := opts.style.applyValues(url.Values{"effect": {name}})
+		if err != nil {
+			t.Fatalf("%s: error: %v", name, err)
+		}
+
+		// Generate twice, to check the output is deterministic.
+		svg, err := genSVG([]string{"hello"}, opts, false)
+		if err != nil {
+			t.Fatalf("%s: genSVG error: %v", name, err)
+		}
+		if again, _ := genSVG([]string{"hello"}, opts, false); again != svg {
+			t.Errorf("%s: output is not deterministic", name)
+		}
+
+		wrapped := strings.Contains(svg,
+			`<g filter="url(#effect-`+name+`)">`)
+		defined := strings.Contains(svg, `<filter id="effect-`+name+`"`)
+		if expected := name != "none"; wrapped != expected ||
+			defined != expected {
+			t.Errorf("%s: filter used %v, defined %v", name, wrapped,
+				defined)
+		}
+
+		// Other backends ignore the filters, but draw the glyphs.
+		doc := &document{svg: svg}
+		sc, err := doc.scene()
+		if err != nil {
+			t.Fatalf("%s: scene error: %v", name, err)
+		}
+		for _, s := range sc.shapes {
+			if s.glyph != "" && !s.hasStroke() {
+				t.Errorf("%s: glyph %s has no stroke", name, s.glyph)
+				break
+			}
+		}
+	}
+
+	st := style{}
+	if err := st.applyValues(url.Values{"effect": {"sparkle"}}); err == nil {
+		t.Errorf("unknown effect did not fail")
+	}
+}
+
+func TestGradientPaint(t *testing.T) {
+	st := style{stroke: "#804020", effect: "metal"}.resolved()
+	if st.glyphStroke() != "url(#effect-gradient)" {
+		t.Errorf("unexpected glyph stroke %q", st.glyphStroke())
+	}
+
+	svg := `<svg viewBox="0 0 10 10">` + string(st.effectDefs()) +
+		`<line x1="0" y1="0" x2="1" y2="1" stroke="url(#effect-gradient)" />` +
+		`<line x1="0" y1="0" x2="1" y2="1" stroke="url(#unknown)" />` +
+		`</svg>`
+	sc, err := parseScene(svg)
+	if err != nil {
+		t.Fatalf("parseScene error: %v", err)
+	}
+
+	// The average of the light, base and dark colors.
+	light, dark := mixColor(color.NRGBA{0x80, 0x40, 0x20, 255},
+		color.NRGBA{255, 255, 255, 255}, 0.6),
+		mixColor(color.NRGBA{0x80, 0x40, 0x20, 255},
+			color.NRGBA{0, 0, 0, 255}, 0.4)
+	if light != "#ccb3a6" || dark != "#4d2613" {
+		t.Errorf("unexpected light %s, dark %s", light, dark)
+	}
+	expected := color.NRGBA{
+		(0xcc + 0x80 + 0x4d) / 3, (0xb3 + 0x40 + 0x26) / 3,
+		(0xa6 + 0x20 + 0x13) / 3, 255}
+	if sc.shapes[0].stroke != expected {
+		t.Errorf("gradient painted as %v, expected %v",
+			sc.shapes[0].stroke, expected)
+	}
+	if sc.shapes[1].hasStroke() {
+		t.Errorf("unknown reference painted as %v", sc.shapes[1].stroke)
+	}
+}
@@ -0,0 +1,621 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"slices"
+	"strings"
+)
+
+// # Embroidery
+//
+// The "embroidery" command writes the words as a stitch file for embroidery
+// machines: Tajima DST, which about every machine can read, or Brother PES
+// (see writePES), depending on the extension of the output file. They're
+// the dst and pes output backends, which the HTTP server serves too.
+//
+// The strokes of the glyphs are sewn with running stitches, of up to the
+// length given with -stitch-length, and with a stitch at each corner. The
+// word line is sewn with a satin stitch (a tight zigzag, over a running
+// stitch so it lies flat) of the width given with -satin-width, with its
+// dots grown to match. Filled shapes (which are small dots, mostly) are
+// covered with satin stitches across them, which works for convex shapes.
+//
+// The shapes are sewn one color at a time, in reading order. Between them
+// the machine jumps, and for jumps longer than -trim it cuts the thread,
+// with a few small stitches before and after so it doesn't come loose.
+//
+// The design is centred on the origin, which is where the machines start,
+// and the smallest common hoop that fits it is given as a note. It can be
+// resized with -scale.
+
+var (
+	stitchLengthFlag = flag.Float64("stitch-length", 2.5,
+		"in embroidery, maximum length of the running stitches, in "+
+			"millimetres")
+	satinWidthFlag = flag.Float64("satin-width", 1.5,
+		"in embroidery, width of the satin stitch of the word line, in "+
+			"millimetres")
+	trimFlag = flag.Float64("trim", 3,
+		"in embroidery, cut the thread for jumps longer than this many "+
+			"millimetres")
+	scaleFlag = flag.Float64("scale", 1,
+		"in embroidery, scale the design by this factor")
+)
+
+// Limits, so the values make some sense. DST can't move more than 12.1 mm
+// in a single stitch.
+const (
+	minStitchLength = 1
+	maxStitchLength = 12
+	minSatinWidth   = 0.5
+	maxSatinWidth   = 10
+	maxTrim         = 50
+	minScale        = 0.1
+	maxScale        = 10
+)
+
+// Distance between the stitches of satins, in millimetres.
+const satinSpacing = 0.4
+
+// Minimum number of stitches around circles.
+const minCircleStitches = 16
+
+// Length of the stitches that tie the thread, in millimetres.
+const tieLength = 0.5
+
+// Common hoops, by the size of their sewing field in millimetres.
+var hoops = []struct{ w, h float64 }{
+	{100, 100},
+	{130, 180},
+	{160, 260},
+	{200, 300},
+	{200, 360},
+}
+
+// embroideryOptions are the options for the embroidery output.
+type embroideryOptions struct {
+	stitchLength float64
+	satinWidth   float64
+	trim         float64
+	scale        float64
+}
+
+type stitchKind int
+
+const (
+	stitchSew stitchKind = iota
+	stitchJump
+
+	// Cut the thread, and stop to change it. They don't move.
+	stitchTrim
+	stitchColor
+)
+
+// A stitch (or some other command), at an absolute position in units of
+// 0.1 mm, with Y going down.
+type stitch struct {
+	kind stitchKind
+	x, y int
+}
+
+// A stitch pattern: the stitches, starting from the origin, and the color
+// of the thread of each of the blocks between color changes.
+type stitchPattern struct {
+	stitches []stitch
+	colors   []color.NRGBA
+}
+
+// embroideryCmd implements the "embroidery" command: it writes the words
+// as a stitch file to the output file given with -o (in the format of its
+// extension), or as DST to w.
+func embroideryCmd(w io.Writer, words []string, opts Options) error {
+	doc, err := renderDocument(words, opts, false)
+	if err != nil {
+		return err
+	}
+	return writeDocument(w, doc, "dst")
+}
+
+// embroideryBackend writes the words of the document as a stitch file,
+// with the given encoder, and the options given by the flags.
+type embroideryBackend struct {
+	encode func(*bytes.Buffer, *stitchPattern, string)
+}
+
+func (embroideryBackend) contentType() string {
+	return "application/octet-stream"
+}
+
+func (b embroideryBackend) write(w io.Writer, doc *document) error {
+	eo := embroideryOptions{
+		stitchLength: *stitchLengthFlag, satinWidth: *satinWidthFlag,
+		trim: *trimFlag, scale: *scaleFlag,
+	}
+	switch {
+	case eo.stitchLength < minStitchLength ||
+		eo.stitchLength > maxStitchLength:
+		return fmt.Errorf("stitch length must be between %d and %d",
+			minStitchLength, maxStitchLength)
+	case eo.satinWidth < minSatinWidth || eo.satinWidth > maxSatinWidth:
+		return fmt.Errorf("satin width must be between %v and %d",
+			minSatinWidth, maxSatinWidth)
+	case eo.trim < 0 || eo.trim > maxTrim:
+		return fmt.Errorf("trim must be between 0 and %d", maxTrim)
+	case eo.scale < minScale || eo.scale > maxScale:
+		return fmt.Errorf("scale must be between %v and %d", minScale,
+			maxScale)
+	}
+
+	sc, err := doc.wordsScene()
+	if err != nil {
+		return err
+	}
+	pat := stitchScene(sc, eo)
+	if len(pat.colors) == 0 {
+		return fmt.Errorf("there is nothing to embroider")
+	}
+
+	lo, hi := pat.bounds()
+	dw, dh := float64(hi.X-lo.X)/10, float64(hi.Y-lo.Y)/10
+	hoop, ok := chooseHoop(dw, dh)
+	if !ok {
+		return fmt.Errorf("the design is %.0fx%.0f mm, too big for any "+
+			"hoop; use a smaller -scale", dw, dh)
+	}
+	doc.opts.note("the design is %.0fx%.0f mm, for a %s mm hoop", dw, dh,
+		hoop)
+
+	buf := &bytes.Buffer{}
+	b.encode(buf, pat, embroideryLabel(doc.words))
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// chooseHoop returns the smallest hoop that fits a design of the given
+// size (in millimetres), maybe rotated.
+func chooseHoop(w, h float64) (string, bool) {
+	for _, hp := range hoops {
+		if (w <= hp.w && h <= hp.h) || (w <= hp.h && h <= hp.w) {
+			return fmt.Sprintf("%.0fx%.0f", hp.w, hp.h), true
+		}
+	}
+	return "", false
+}
+
+// embroideryLabel returns the label of the design, which the machines
+// show: the words, in ASCII, and up to 16 characters.
+func embroideryLabel(words []string) string {
+	label := []byte{}
+	for _, r := range strings.Join(words, " ") {
+		if len(label) == 16 {
+			break
+		}
+		if r < ' ' || r > '~' {
+			r = '_'
+		}
+		label = append(label, byte(r))
+	}
+	return string(label)
+}
+
+// stitchScene returns the stitch pattern for the scene, centred on the
+// origin.
+func stitchScene(sc *scene, eo embroideryOptions) *stitchPattern {
+	// Group the shapes by color, keeping their order.
+	colors := []color.NRGBA{}
+	byColor := map[color.NRGBA][]shape{}
+	for _, s := range sc.shapes {
+		c := s.stroke
+		if s.hasFill() {
+			c = s.fill
+		} else if !s.hasStroke() {
+			continue
+		}
+		c.A = 255
+		if _, ok := byColor[c]; !ok {
+			colors = append(colors, c)
+		}
+		byColor[c] = append(byColor[c], s)
+	}
+
+	st := &stitcher{eo: eo, pat: &stitchPattern{}}
+	for _, c := range colors {
+		st.changeColor(c)
+		for _, s := range byColor[c] {
+			for _, path := range stitchShape(s, eo) {
+				st.sewPath(path)
+			}
+		}
+	}
+	st.cut()
+
+	// Move the design so the origin is in its centre.
+	pat := st.pat
+	lo, hi := pat.bounds()
+	cx, cy := (lo.X+hi.X)/2, (lo.Y+hi.Y)/2
+	for i := range pat.stitches {
+		pat.stitches[i].x -= cx
+		pat.stitches[i].y -= cy
+	}
+	return pat
+}
+
+// stitchShape returns the paths to sew for the shape, in millimetres.
+func stitchShape(s shape, eo embroideryOptions) [][]point {
+	k := eo.scale
+	if s.class == "word-line" && s.circle && s.strokeWidth > 0 {
+		// The dots of the word line grow with the satin, with the same
+		// proportion to its width as in the image.
+		s.r *= eo.satinWidth / (s.strokeWidth * k)
+	}
+	// Circles need shorter stitches to look round.
+	length := eo.stitchLength
+	if s.circle {
+		length = min(length, 2*math.Pi*s.r*k/minCircleStitches)
+	}
+
+	paths := [][]point{}
+	for _, sp := range s.outline() {
+		pts := make([]point, len(sp.points))
+		for i, p := range sp.points {
+			pts[i] = point{p.x * k, p.y * k}
+		}
+		switch {
+		case s.hasFill():
+			paths = append(paths, satinFill(pts))
+		case s.class == "word-line" && !s.circle:
+			paths = append(paths,
+				satinStitch(pts, eo.satinWidth, eo.stitchLength))
+		default:
+			paths = append(paths, runningStitch(pts, sp.closed, length))
+		}
+	}
+	return paths
+}
+
+// stitcher builds stitch patterns.
+type stitcher struct {
+	eo  embroideryOptions
+	pat *stitchPattern
+
+	// Where the needle is, and the previous place where it went down (to
+	// know where to tie the thread). In millimetres.
+	pos, prev point
+
+	// Whether the thread is attached, and there's something sewn since
+	// the last cut.
+	sewing bool
+}
+
+func (st *stitcher) add(kind stitchKind, p point) {
+	st.pat.stitches = append(st.pat.stitches, stitch{kind: kind,
+		x: int(math.Round(p.x * 10)), y: int(math.Round(p.y * 10))})
+	if kind == stitchSew && p != st.pos {
+		st.prev = st.pos
+	}
+	st.pos = p
+}
+
+// tie sews small stitches from a towards b and back, so the thread doesn't
+// come loose.
+func (st *stitcher) tie(a, b point) {
+	d := dist(a, b)
+	if d == 0 {
+		return
+	}
+	l := math.Min(tieLength, d/2) / d
+	st.add(stitchSew, point{a.x + (b.x-a.x)*l, a.y + (b.y-a.y)*l})
+	st.add(stitchSew, a)
+}
+
+// cut ties the thread, and cuts it.
+func (st *stitcher) cut() {
+	if !st.sewing {
+		return
+	}
+	st.tie(st.pos, st.prev)
+	st.add(stitchTrim, st.pos)
+	st.sewing = false
+}
+
+func (st *stitcher) changeColor(c color.NRGBA) {
+	if len(st.pat.colors) > 0 {
+		st.cut()
+		st.add(stitchColor, st.pos)
+	}
+	st.pat.colors = append(st.pat.colors, c)
+}
+
+// sewPath sews the stitches of the path, jumping to its start.
+func (st *stitcher) sewPath(path []point) {
+	if len(path) == 0 {
+		return
+	}
+	start := path[0]
+	if dist(st.pos, start) > st.eo.trim {
+		st.cut()
+	}
+	if !st.sewing {
+		st.add(stitchJump, start)
+		st.add(stitchSew, start)
+		if len(path) > 1 {
+			st.tie(start, path[1])
+		}
+		st.sewing = true
+	} else if start != st.pos {
+		st.add(stitchJump, start)
+		st.add(stitchSew, start)
+	}
+	for _, p := range path[1:] {
+		st.add(stitchSew, p)
+	}
+}
+
+// bounds returns the bounding box of the stitches.
+func (pat *stitchPattern) bounds() (lo, hi image.Point) {
+	if len(pat.stitches) == 0 {
+		return
+	}
+	lo = image.Point{math.MaxInt, math.MaxInt}
+	hi = image.Point{math.MinInt, math.MinInt}
+	for _, s := range pat.stitches {
+		lo = image.Point{X: min(lo.X, s.x), Y: min(lo.Y, s.y)}
+		hi = image.Point{X: max(hi.X, s.x), Y: max(hi.Y, s.y)}
+	}
+	return lo, hi
+}
+
+// Angle (in radians) from which a turn of a line is a corner, that needs a
+// stitch on it.
+const cornerAngle = math.Pi / 6
+
+// runningStitch returns the points of a running stitch along the line,
+// with stitches of up to the given length. The corners always have a
+// stitch, and in between the stitches are all the same length.
+func runningStitch(pts []point, closed bool, length float64) []point {
+	if len(pts) == 0 {
+		return nil
+	}
+	if closed {
+		pts = append(slices.Clone(pts), pts[0])
+	}
+	out := []point{pts[0]}
+	start := 0
+	for i := 1; i < len(pts); i++ {
+		if i < len(pts)-1 && turn(pts[i-1], pts[i], pts[i+1]) < cornerAngle {
+			continue
+		}
+		piece := pts[start : i+1]
+		l := lineLength(piece)
+		n := max(int(math.Ceil(l/length-1e-9)), 1)
+		for k := 1; k <= n; k++ {
+			out = append(out, pointAlong(piece, l*float64(k)/float64(n)))
+		}
+		start = i
+	}
+	return out
+}
+
+// turn returns the angle that the line turns at b, in radians.
+func turn(a, b, c point) float64 {
+	a1 := math.Atan2(b.y-a.y, b.x-a.x)
+	a2 := math.Atan2(c.y-b.y, c.x-b.x)
+	d := math.Abs(a2 - a1)
+	if d > math.Pi {
+		d = 2*math.Pi - d
+	}
+	return d
+}
+
+func lineLength(pts []point) float64 {
+	l := 0.0
+	for i := 1; i < len(pts); i++ {
+		l += dist(pts[i-1], pts[i])
+	}
+	return l
+}
+
+// pointAlong returns the point at the given distance along the line.
+func pointAlong(pts []point, d float64) point {
+	for i := 1; i < len(pts); i++ {
+		l := dist(pts[i-1], pts[i])
+		if d <= l && l > 0 {
+			f := d / l
+			return point{pts[i-1].x + (pts[i].x-pts[i-1].x)*f,
+				pts[i-1].y + (pts[i].y-pts[i-1].y)*f}
+		}
+		d -= l
+	}
+	return pts[len(pts)-1]
+}
+
+// satinStitch returns a satin stitch of the given width along the line: a
+// running stitch (with the given length) to its end, and then a zigzag
+// back to its start.
+func satinStitch(pts []point, width, length float64) []point {
+	out := runningStitch(pts, false, length)
+	back := slices.Clone(pts)
+	slices.Reverse(back)
+	l := lineLength(back)
+	n := max(int(math.Ceil(2*l/satinSpacing)), 1)
+	for k := 0; k <= n; k++ {
+		d := l * float64(k) / float64(n)
+		p := pointAlong(back, d)
+
+		// The direction of the line at that point.
+		a := pointAlong(back, math.Max(d-0.01, 0))
+		b := pointAlong(back, math.Min(d+0.01, l))
+		dl := dist(a, b)
+		if dl == 0 {
+			continue
+		}
+		side := width / 2 / dl
+		if k%2 == 1 {
+			side = -side
+		}
+		out = append(out, point{p.x - (b.y-a.y)*side, p.y + (b.x-a.x)*side})
+	}
+	return out
+}
+
+// satinFill returns satin stitches that cover the area inside the ring,
+// going up and down, from left to right. The ring should be convex: only
+// the top and bottom of the area are used.
+func satinFill(ring []point) []point {
+	min, max := ringsBounds([]subpath{{points: ring}})
+	out := []point{}
+	for x := min.x + satinSpacing/4; x < max.x; x += satinSpacing / 2 {
+		top, bottom := math.Inf(1), math.Inf(-1)
+		for i, a := range ring {
+			b := ring[(i+1)%len(ring)]
+			if (a.x <= x) == (b.x <= x) {
+				continue
+			}
+			y := a.y + (x-a.x)*(b.y-a.y)/(b.x-a.x)
+			top, bottom = math.Min(top, y), math.Max(bottom, y)
+		}
+		if top > bottom {
+			continue
+		}
+		if len(out)%2 == 0 {
+			out = append(out, point{x, top})
+		} else {
+			out = append(out, point{x, bottom})
+		}
+	}
+	return out
+}
+
+// splitMove splits a move of dx, dy into moves of up to limit in each axis.
+func splitMove(dx, dy, limit int) [][2]int {
+	n := (max(dx, -dx, dy, -dy) + limit - 1) / limit
+	if n <= 1 {
+		return [][2]int{{dx, dy}}
+	}
+	moves := [][2]int{}
+	px, py := 0, 0
+	for k := 1; k <= n; k++ {
+		x, y := dx*k/n, dy*k/n
+		moves = append(moves, [2]int{x - px, y - py})
+		px, py = x, y
+	}
+	return moves
+}
+
+// DST flags, in the third byte of the records.
+const (
+	dstJump  = 0x80
+	dstColor = 0xc0
+)
+
+// Maximum move of DST records, in each axis.
+const dstMaxMove = 121
+
+// writeDST writes the pattern as a Tajima DST file: a 512 byte text
+// header, and 3 byte records for each stitch.
+func writeDST(buf *bytes.Buffer, pat *stitchPattern, label string) {
+	records := &bytes.Buffer{}
+	n, colors := 0, 0
+	record := func(dx, dy int, flags byte) {
+		b := dstRecord(dx, dy, flags)
+		records.Write(b[:])
+		n++
+	}
+
+	x, y := 0, 0
+	lo, hi := image.Point{}, image.Point{}
+	for _, s := range pat.stitches {
+		switch s.kind {
+		case stitchSew, stitchJump:
+			flags := byte(0)
+			if s.kind == stitchJump {
+				flags = dstJump
+			}
+			// Y goes up in DST.
+			for _, m := range splitMove(s.x-x, y-s.y, dstMaxMove) {
+				record(m[0], m[1], flags)
+			}
+			x, y = s.x, s.y
+		case stitchTrim:
+			// There is no trim command, but the machines trim after some
+			// jumps in a row.
+			record(2, 2, dstJump)
+			record(-4, -4, dstJump)
+			record(2, 2, dstJump)
+		case stitchColor:
+			record(0, 0, dstColor)
+			colors++
+		}
+		lo = image.Point{X: min(lo.X, x), Y: min(lo.Y, y)}
+		hi = image.Point{X: max(hi.X, x), Y: max(hi.Y, y)}
+	}
+	records.Write([]byte{0, 0, 0xf3}) // End.
+	n++
+
+	sign := func(v int) string {
+		if v < 0 {
+			return fmt.Sprintf("-%5d", -v)
+		}
+		return fmt.Sprintf("+%5d", v)
+	}
+	header := &bytes.Buffer{}
+	fmt.Fprintf(header, "LA:%-16s\r", label)
+	fmt.Fprintf(header, "ST:%7d\r", n)
+	fmt.Fprintf(header, "CO:%3d\r", colors)
+	fmt.Fprintf(header, "+X:%5d\r-X:%5d\r", hi.X, -lo.X)
+	fmt.Fprintf(header, "+Y:%5d\r-Y:%5d\r", -lo.Y, hi.Y)
+	fmt.Fprintf(header, "AX:%s\rAY:%s\r", sign(x), sign(-y))
+	fmt.Fprintf(header, "MX:+    0\rMY:+    0\rPD:******\r\x1a")
+	for header.Len() < 512 {
+		header.WriteByte(' ')
+	}
+	buf.Write(header.Bytes())
+	buf.Write(records.Bytes())
+}
+
+// Bits of the DST records for each unit of movement, from the largest:
+// the byte, and the bits for +X, -X, +Y, -Y.
+var dstBits = []struct {
+	unit                   int
+	byte                   int
+	xPos, xNeg, yPos, yNeg byte
+}{
+	{81, 2, 1 << 2, 1 << 3, 1 << 5, 1 << 4},
+	{27, 1, 1 << 2, 1 << 3, 1 << 5, 1 << 4},
+	{9, 0, 1 << 2, 1 << 3, 1 << 5, 1 << 4},
+	{3, 1, 1 << 0, 1 << 1, 1 << 7, 1 << 6},
+	{1, 0, 1 << 0, 1 << 1, 1 << 7, 1 << 6},
+}
+
+// dstRecord returns the DST record for a move of dx, dy (each up to
+// dstMaxMove), with the flags. The moves are written in balanced ternary:
+// each unit can be added or subtracted.
+func dstRecord(dx, dy int, flags byte) [3]byte {
+	var b [3]byte
+	b[2] = flags | 0x03
+	for _, d := range dstBits {
+		switch {
+		case dx > d.unit/2:
+			b[d.byte] |= d.xPos
+			dx -= d.unit
+		case dx < -d.unit/2:
+			b[d.byte] |= d.xNeg
+			dx += d.unit
+		}
+		switch {
+		case dy > d.unit/2:
+			b[d.byte] |= d.yPos
+			dy -= d.unit
+		case dy < -d.unit/2:
+			b[d.byte] |= d.yNeg
+			dy += d.unit
+		}
+	}
+	return b
+}
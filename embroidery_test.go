@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDSTRecord(t *testing.T) {
+	cases := []struct {
+		dx, dy   int
+		flags    byte
+		expected [3]byte
+	}{
+		{0, 0, 0, [3]byte{0, 0, 0x03}},
+		{1, 0, 0, [3]byte{0x01, 0, 0x03}},
+		{0, -1, 0, [3]byte{0x40, 0, 0x03}},
+		{-2, 0, 0, [3]byte{0x01, 0x02, 0x03}}, // -3 + 1.
+		{121, 121, 0, [3]byte{0xa5, 0xa5, 0x27}},
+		{-121, -121, dstJump, [3]byte{0x5a, 0x5a, 0x9b}},
+		{0, 0, dstColor, [3]byte{0, 0, 0xc3}},
+	}
+	for _, c := range cases {
+		got := dstRecord(c.dx, c.dy, c.flags)
+		if got != c.expected {
+			t.Errorf("dstRecord(%d, %d, %#x) = %#v, expected %#v", c.dx,
+				c.dy, c.flags, got, c.expected)
+		}
+	}
+
+	// All the moves can be written, and read back.
+	for dx := -dstMaxMove; dx <= dstMaxMove; dx++ {
+		for dy := -dstMaxMove; dy <= dstMaxMove; dy++ {
+			gx, gy, _ := dstDecode(dstRecord(dx, dy, 0))
+			if gx != dx || gy != dy {
+				t.Fatalf("%d, %d read back as %d, %d", dx, dy, gx, gy)
+			}
+		}
+	}
+}
+
+// dstDecode returns the move and flags of the DST record.
+func dstDecode(b [3]byte) (dx, dy int, flags byte) {
+	for _, d := range dstBits {
+		v := b[d.byte]
+		if v&d.xPos != 0 {
+			dx += d.unit
+		}
+		if v&d.xNeg != 0 {
+			dx -= d.unit
+		}
+		if v&d.yPos != 0 {
+			dy += d.unit
+		}
+		if v&d.yNeg != 0 {
+			dy -= d.unit
+		}
+	}
+	return dx, dy, b[2] & 0xc0
+}
+
+func TestRunningStitch(t *testing.T) {
+	// A square, with sides of 10 and a point in the middle of one of them
+	// (which is not a corner), in stitches of up to 3.
+	square := []point{{0, 0}, {5, 0}, {10, 0}, {10, 10}, {0, 10}}
+	got := runningStitch(square, true, 3)
+	expected := []point{{0, 0}, {2.5, 0}, {5, 0}, {7.5, 0}, {10, 0}}
+	if len(got) != 17 {
+		t.Fatalf("got %d points, expected 17: %v", len(got), got)
+	}
+	if diff := cmp.Diff(expected, got[:5],
+		cmp.AllowUnexported(point{})); diff != "" {
+		t.Errorf("first side differs (-expected +got):\n%s", diff)
+	}
+	for _, c := range []point{{10, 10}, {0, 10}, {0, 0}} {
+		found := false
+		for _, p := range got {
+			found = found || dist(p, c) < 1e-9
+		}
+		if !found {
+			t.Errorf("corner %v has no stitch", c)
+		}
+	}
+
+	// The satin goes to the end, and back in a zigzag.
+	got = satinStitch([]point{{0, 0}, {4, 0}}, 2, 3)
+	n := 2*4/satinSpacing + 1
+	if len(got) != 3+int(n) {
+		t.Fatalf("satin has %d points, expected %d", len(got), 3+int(n))
+	}
+	for i, p := range got[3:] {
+		if math.Abs(math.Abs(p.y)-1) > 1e-9 || (p.y < 0) != (i%2 == 0) {
+			t.Errorf("satin point %d is %v", i, p)
+		}
+	}
+}
+
+// testPattern returns the stitch pattern for "hi".
+func testPattern(t *testing.T) *stitchPattern {
+	t.Helper()
+	opts := Options{syllables: "manual", angle: defaultAngle,
+		sentences: "none"}
+	wsvg, w, h, err := wordsToSVG([]string{"hi"}, opts)
+	if err != nil {
+		t.Fatalf("wordsToSVG error: %v", err)
+	}
+	sc, err := wordsScene(wsvg, w, h)
+	if err != nil {
+		t.Fatalf("wordsScene error: %v", err)
+	}
+	return stitchScene(sc, embroideryOptions{
+		stitchLength: 2.5, satinWidth: 1.5, trim: 3, scale: 1})
+}
+
+// sewn returns the positions of the stitches that are sewn.
+func sewn(pat *stitchPattern) [][2]int {
+	pos := [][2]int{}
+	for _, s := range pat.stitches {
+		if s.kind == stitchSew {
+			pos = append(pos, [2]int{s.x, s.y})
+		}
+	}
+	return pos
+}
+
+func TestWriteDST(t *testing.T) {
+	pat := testPattern(t)
+	pat.colors = append(pat.colors, pat.colors[0])
+	pat.stitches = append(pat.stitches, stitch{kind: stitchColor},
+		stitch{kind: stitchJump, x: 600, y: -500},
+		stitch{kind: stitchSew, x: 600, y: -500})
+
+	buf := &bytes.Buffer{}
+	writeDST(buf, pat, "hi")
+	b := buf.Bytes()
+	if len(b) < 512 || (len(b)-512)%3 != 0 {
+		t.Fatalf("unexpected length %d", len(b))
+	}
+
+	fields := map[string]string{}
+	for _, f := range strings.Split(string(b[:512]), "\r") {
+		if k, v, ok := strings.Cut(f, ":"); ok {
+			fields[k] = strings.TrimSpace(v)
+		}
+	}
+	expected := map[string]string{
+		"LA": "hi", "ST": strconv.Itoa((len(b) - 512) / 3), "CO": "1",
+		"+X": "600", "+Y": "500", "AX": "+  600", "AY": "+  500",
+		"MX": "+    0", "MY": "+    0", "PD": "******",
+	}
+	for k, v := range expected {
+		if fields[k] != v {
+			t.Errorf("header %s is %q, expected %q", k, fields[k], v)
+		}
+	}
+
+	// Follow the records, to check that the stitches end up where they
+	// should.
+	x, y := 0, 0
+	pos := [][2]int{}
+	jumps := 0
+	for i := 512; i < len(b)-3; i += 3 {
+		dx, dy, flags := dstDecode([3]byte(b[i : i+3]))
+		x, y = x+dx, y-dy
+		switch flags {
+		case 0:
+			pos = append(pos, [2]int{x, y})
+		case dstJump:
+			jumps++
+		}
+	}
+	if diff := cmp.Diff(sewn(pat), pos); diff != "" {
+		t.Errorf("stitches differ (-expected +got):\n%s", diff)
+	}
+	// Long jumps are split, and the trims are 3 jumps each.
+	expJumps, px, py := 0, 0, 0
+	for _, s := range pat.stitches {
+		switch s.kind {
+		case stitchJump:
+			expJumps += len(splitMove(s.x-px, s.y-py, dstMaxMove))
+		case stitchTrim:
+			expJumps += 3
+		}
+		if s.kind == stitchSew || s.kind == stitchJump {
+			px, py = s.x, s.y
+		}
+	}
+	if jumps != expJumps {
+		t.Errorf("got %d jumps, expected %d", jumps, expJumps)
+	}
+	if end := b[len(b)-3:]; !bytes.Equal(end, []byte{0, 0, 0xf3}) {
+		t.Errorf("last record is %x, expected the end", end)
+	}
+}
+
+func TestWritePES(t *testing.T) {
+	pat := testPattern(t)
+	buf := &bytes.Buffer{}
+	writePES(buf, pat, "hi")
+	b := buf.Bytes()
+
+	if !bytes.HasPrefix(b, []byte("#PES0001")) {
+		t.Fatalf("unexpected start %q", b[:8])
+	}
+	pec := int(binary.LittleEndian.Uint32(b[8:]))
+	if !bytes.HasPrefix(b[pec:], []byte("LA:hi              \r")) {
+		t.Fatalf("unexpected PEC start %q", b[pec:pec+20])
+	}
+	if got := int(b[pec+48]) + 1; got != len(pat.colors) {
+		t.Errorf("got %d threads, expected %d", got, len(pat.colors))
+	}
+	if got, exp := b[pec+49], pecThread(pat.colors[0]); got != exp {
+		t.Errorf("got thread %d, expected %d", got, exp)
+	}
+
+	// The stitch block, and the thumbnails after it.
+	block := pec + 512
+	n := int(b[block+2]) | int(b[block+3])<<8 | int(b[block+4])<<16
+	icons := len(b) - (block + n)
+	if icons != (1+len(pat.colors))*6*38 {
+		t.Errorf("got %d bytes of thumbnails", icons)
+	}
+
+	// Read the stitches, like the machines would.
+	long := func(v int) int {
+		v &= 0xfff
+		if v >= 0x800 {
+			v -= 0x1000
+		}
+		return v
+	}
+	short := func(v byte) int {
+		if v >= 0x40 {
+			return int(v) - 0x80
+		}
+		return int(v)
+	}
+	x, y := 0, 0
+	pos := [][2]int{}
+	i := block + 8 + 12
+	for b[i] != 0xff {
+		if b[i] == 0xfe && b[i+1] == 0xb0 {
+			i += 3
+			continue
+		}
+		var dx, dy int
+		jump := false
+		if b[i]&0x80 != 0 {
+			jump = b[i]&0x30 != 0
+			dx = long(int(b[i])<<8 | int(b[i+1]))
+			i += 2
+		} else {
+			dx = short(b[i])
+			i++
+		}
+		if b[i]&0x80 != 0 {
+			dy = long(int(b[i])<<8 | int(b[i+1]))
+			i += 2
+		} else {
+			dy = short(b[i])
+			i++
+		}
+		x, y = x+dx, y+dy
+		if !jump {
+			pos = append(pos, [2]int{x, y})
+		}
+	}
+	if diff := cmp.Diff(sewn(pat), pos); diff != "" {
+		t.Errorf("stitches differ (-expected +got):\n%s", diff)
+	}
+}
+
+func TestPECThread(t *testing.T) {
+	cases := []struct {
+		c        color.NRGBA
+		expected byte
+	}{
+		{color.NRGBA{0, 0, 0, 255}, 20},         // Black.
+		{color.NRGBA{255, 255, 255, 255}, 29},   // White.
+		{color.NRGBA{255, 165, 0, 255}, 62},     // Orange.
+		{color.NRGBA{0x19, 0x19, 0x70, 255}, 1}, // Prussian blue.
+	}
+	for _, c := range cases {
+		if got := pecThread(c.c); got != c.expected {
+			t.Errorf("pecThread(%v) = %d, expected %d", c.c, got, c.expected)
+		}
+	}
+}
+
+func TestChooseHoop(t *testing.T) {
+	cases := []struct {
+		w, h     float64
+		expected string
+	}{
+		{50, 50, "100x100"},
+		{100, 100, "100x100"},
+		{170, 120, "130x180"},
+		{250, 150, "160x260"},
+		{400, 100, ""},
+	}
+	for _, c := range cases {
+		got, _ := chooseHoop(c.w, c.h)
+		if got != c.expected {
+			t.Errorf("chooseHoop(%v, %v) = %q, expected %q", c.w, c.h, got,
+				c.expected)
+		}
+	}
+}
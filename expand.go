@@ -0,0 +1,545 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// # Text expansion
+//
+// Numbers, dates, times and abbreviations don't have a pronunciation in the
+// dictionaries, so before converting the words to glyphs, we spell them out
+// in the language of the word (given by its prefix, or detected for the
+// phrase):
+//
+//   - Numbers: "42", "-3", "1,000", "3.14", "50%".
+//   - Ordinals: "1st", "21st" (English), "1º", "2ª" (Spanish).
+//   - Years: "1985" is "nineteen eighty-five" in English. In Spanish, years
+//     are read like any other number ("mil novecientos ochenta y cinco").
+//   - Times: "10:30".
+//   - Dates, in ISO 8601 format: "2025-10-18".
+//   - Common abbreviations: "Dr", "Mr", "Sra.", etc.
+//
+// Only English and Spanish are supported for now. The spelled out words
+// replace the original one, keeping its language prefix, if any. Numbers in
+// a phrase detected as some other language are spelled out in the first
+// preferred language that is supported, or in English, and prefixed with
+// it. Words that only look like numbers (e.g. "3D") are left as they are.
+
+// textExpander knows how to spell out things for a single language.
+type textExpander struct {
+	cardinal func(n int64) string
+	ordinal  func(n int64, feminine bool) string
+	year     func(n int64) string
+	time     func(h, m int64) string
+	date     func(y, m, d int64) string
+
+	// Ordinal suffixes (e.g. "st" in "1st"), and whether they are feminine.
+	ordinalSuffixes map[string]bool
+
+	// Separators for numbers.
+	decimalSep, thousandsSep string
+
+	// Words for the parts of numbers.
+	point, minus, percent string
+
+	// Abbreviations, lowercase and without the trailing ".".
+	abbrevs map[string]string
+}
+
+// The text expanders, by base language.
+var textExpanders = map[string]*textExpander{
+	"en": enExpander,
+	"es": esExpander,
+}
+
+var (
+	timeRE   = regexp.MustCompile(`^(\d{1,2}):(\d{2})$`)
+	dateRE   = regexp.MustCompile(`^(\d{4})-(\d{2})-(\d{2})$`)
+	ordRE    = regexp.MustCompile(`^(\d+)\.?([^\d.,%]+)$`)
+	numberRE = regexp.MustCompile(`^(-?)(\d[\d.,]*)(%?)$`)
+	yearRE   = regexp.MustCompile(`^[12]\d{3}$`)
+)
+
+// needsExpansion returns true if the word (without prefix) has something
+// that we might need to spell out.
+func needsExpansion(word string) bool {
+	if strings.ContainsAny(word, "0123456789.") {
+		return true
+	}
+	lower := strings.ToLower(word)
+	for _, ex := range textExpanders {
+		if _, ok := ex.abbrevs[lower]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// splitPrefix splits the language prefix from the word. Times are not
+// mistaken for a prefix.
+func splitPrefix(word string) (lang, w string, ok bool) {
+	lang, w, ok = strings.Cut(word, ":")
+	if !ok || timeRE.MatchString(word) {
+		return "", word, false
+	}
+	return lang, w, true
+}
+
+// expandWords spells out the words that need it, using the language of their
+// prefix, or the detected one.
+func expandWords(words []string, det langDetection, opts Options) ([]string, error) {
+	expanded := []string{}
+	for _, word := range words {
+		lang, w, hasPrefix := splitPrefix(word)
+		if !hasPrefix {
+			lang = det.lang.String()
+			if textExpanderFor(lang) == nil &&
+				strings.ContainsAny(w, "0123456789") {
+				lang = fallbackExpanderLang(opts.langs)
+				hasPrefix = true
+			}
+		}
+
+		if !needsExpansion(w) || lang == "" || lang == "firstones" {
+			expanded = append(expanded, word)
+			continue
+		}
+
+		spelled, err := expandWord(w, lang)
+		if err != nil {
+			return nil, fmt.Errorf("error expanding %q: %v", word, err)
+		}
+		if spelled == "" {
+			expanded = append(expanded, word)
+			continue
+		}
+
+		opts.note("%s: spelled out as %q", word, spelled)
+		// Hyphenated numbers (like "forty-two") stay a single word.
+		for _, sw := range strings.Fields(spelled) {
+			if hasPrefix {
+				sw = lang + ":" + sw
+			}
+			expanded = append(expanded, sw)
+		}
+	}
+	return expanded, nil
+}
+
+// fallbackExpanderLang returns the language to spell out numbers in, when
+// the one of the phrase can't: the first of the preferred languages that
+// can, or English.
+func fallbackExpanderLang(prefs []language.Tag) string {
+	for _, tag := range prefs {
+		base, _ := tag.Base()
+		if textExpanders[base.String()] != nil {
+			return base.String()
+		}
+	}
+	return "en"
+}
+
+// expandWord spells out the word in the given language. It returns "" if
+// there is nothing to expand, or if the word is not something it knows how
+// to spell out (like "3D"), so it's looked up as is.
+func expandWord(word, lang string) (string, error) {
+	ex := textExpanderFor(lang)
+	hasDigits := strings.ContainsAny(word, "0123456789")
+	if ex == nil {
+		if hasDigits {
+			return "", fmt.Errorf(
+				"can't spell out numbers in %q (sorry!)", lang)
+		}
+		return "", nil
+	}
+
+	if a, ok := ex.abbrevs[strings.ToLower(strings.TrimSuffix(word, "."))]; ok {
+		return a, nil
+	}
+	if !hasDigits {
+		return "", nil
+	}
+
+	if m := timeRE.FindStringSubmatch(word); m != nil {
+		h, _ := strconv.ParseInt(m[1], 10, 64)
+		mins, _ := strconv.ParseInt(m[2], 10, 64)
+		if h > 23 || mins > 59 {
+			return "", fmt.Errorf("invalid time")
+		}
+		return ex.time(h, mins), nil
+	}
+
+	if m := dateRE.FindStringSubmatch(word); m != nil {
+		y, _ := strconv.ParseInt(m[1], 10, 64)
+		mon, _ := strconv.ParseInt(m[2], 10, 64)
+		d, _ := strconv.ParseInt(m[3], 10, 64)
+		if mon < 1 || mon > 12 || d < 1 || d > 31 {
+			return "", fmt.Errorf("invalid date")
+		}
+		return ex.date(y, mon, d), nil
+	}
+
+	if m := ordRE.FindStringSubmatch(word); m != nil {
+		feminine, ok := ex.ordinalSuffixes[strings.ToLower(m[2])]
+		if !ok {
+			return "", nil
+		}
+		n, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil {
+			return "", err
+		}
+		return ex.ordinal(n, feminine), nil
+	}
+
+	if yearRE.MatchString(word) {
+		n, _ := strconv.ParseInt(word, 10, 64)
+		return ex.year(n), nil
+	}
+
+	if m := numberRE.FindStringSubmatch(word); m != nil {
+		return ex.number(m[1] != "", m[2], m[3] != "")
+	}
+
+	return "", nil
+}
+
+func textExpanderFor(lang string) *textExpander {
+	base, _, _ := strings.Cut(lang, "-")
+	return textExpanders[strings.ToLower(base)]
+}
+
+// number spells out a number, which can have thousands separators and
+// decimals (read digit by digit).
+func (ex *textExpander) number(negative bool, num string, percent bool) (string, error) {
+	intPart, decPart, hasDec := strings.Cut(num, ex.decimalSep)
+	if hasDec && (decPart == "" || strings.ContainsAny(decPart, ".,")) {
+		return "", fmt.Errorf("invalid number")
+	}
+
+	// Thousands separators must separate groups of 3 digits.
+	groups := strings.Split(intPart, ex.thousandsSep)
+	for i, g := range groups {
+		if g == "" || len(g) > 3 && len(groups) > 1 ||
+			i > 0 && len(g) != 3 || strings.ContainsAny(g, ".,") {
+			return "", fmt.Errorf("invalid number")
+		}
+	}
+	n, err := strconv.ParseInt(strings.Join(groups, ""), 10, 64)
+	if err != nil {
+		return "", err
+	}
+
+	s := ex.cardinal(n)
+	if negative {
+		s = ex.minus + " " + s
+	}
+	if hasDec {
+		s += " " + ex.point
+		for _, d := range decPart {
+			s += " " + ex.cardinal(int64(d-'0'))
+		}
+	}
+	if percent {
+		s += " " + ex.percent
+	}
+	return s, nil
+}
+
+//
+// English.
+//
+
+var enOnes = []string{
+	"zero", "one", "two", "three", "four", "five", "six", "seven", "eight",
+	"nine", "ten", "eleven", "twelve", "thirteen", "fourteen", "fifteen",
+	"sixteen", "seventeen", "eighteen", "nineteen",
+}
+
+var enTens = []string{
+	"", "", "twenty", "thirty", "forty", "fifty", "sixty", "seventy",
+	"eighty", "ninety",
+}
+
+var enScales = []struct {
+	n    int64
+	name string
+}{
+	{1_000_000_000_000, "trillion"},
+	{1_000_000_000, "billion"},
+	{1_000_000, "million"},
+	{1_000, "thousand"},
+	{100, "hundred"},
+}
+
+func enCardinal(n int64) string {
+	switch {
+	case n < 20:
+		return enOnes[n]
+	case n < 100:
+		s := enTens[n/10]
+		if n%10 != 0 {
+			s += "-" + enOnes[n%10]
+		}
+		return s
+	}
+
+	for _, scale := range enScales {
+		if n >= scale.n {
+			s := enCardinal(n/scale.n) + " " + scale.name
+			if n%scale.n != 0 {
+				s += " " + enCardinal(n%scale.n)
+			}
+			return s
+		}
+	}
+	panic("unreachable")
+}
+
+// Irregular ordinals; the rest add "th" (or replace "y" by "ieth").
+var enOrdinals = map[string]string{
+	"one": "first", "two": "second", "three": "third", "five": "fifth",
+	"eight": "eighth", "nine": "ninth", "twelve": "twelfth",
+}
+
+func enOrdinal(n int64, _ bool) string {
+	s := enCardinal(n)
+	i := strings.LastIndexAny(s, " -") + 1
+	last := s[i:]
+	if o, ok := enOrdinals[last]; ok {
+		last = o
+	} else if strings.HasSuffix(last, "y") {
+		last = strings.TrimSuffix(last, "y") + "ieth"
+	} else {
+		last += "th"
+	}
+	return s[:i] + last
+}
+
+func enYear(n int64) string {
+	century, rest := n/100, n%100
+	switch {
+	case n%1000 < 10 && n/1000 == 2, n < 1100:
+		// "two thousand five".
+		return enCardinal(n)
+	case rest == 0:
+		// "nineteen hundred".
+		return enCardinal(century) + " hundred"
+	case rest < 10:
+		// "nineteen oh five".
+		return enCardinal(century) + " oh " + enCardinal(rest)
+	}
+	// "nineteen eighty-five".
+	return enCardinal(century) + " " + enCardinal(rest)
+}
+
+func enTime(h, m int64) string {
+	switch {
+	case m == 0:
+		return enCardinal(h) + " o'clock"
+	case m < 10:
+		return enCardinal(h) + " oh " + enCardinal(m)
+	}
+	return enCardinal(h) + " " + enCardinal(m)
+}
+
+var enMonths = []string{
+	"", "january", "february", "march", "april", "may", "june", "july",
+	"august", "september", "october", "november", "december",
+}
+
+func enDate(y, m, d int64) string {
+	return enMonths[m] + " " + enOrdinal(d, false) + " " + enYear(y)
+}
+
+var enExpander = &textExpander{
+	cardinal: enCardinal,
+	ordinal:  enOrdinal,
+	year:     enYear,
+	time:     enTime,
+	date:     enDate,
+
+	ordinalSuffixes: map[string]bool{
+		"st": false, "nd": false, "rd": false, "th": false,
+	},
+
+	decimalSep:   ".",
+	thousandsSep: ",",
+
+	point:   "point",
+	minus:   "minus",
+	percent: "percent",
+
+	abbrevs: map[string]string{
+		"dr":   "doctor",
+		"mr":   "mister",
+		"mrs":  "missus",
+		"ms":   "miss", // Not quite, but "miz" is not in the dictionary.
+		"st":   "saint",
+		"jr":   "junior",
+		"sr":   "senior",
+		"vs":   "versus",
+		"etc":  "etcetera",
+		"mt":   "mount",
+		"prof": "professor",
+		"capt": "captain",
+		"gen":  "general",
+		"lt":   "lieutenant",
+		"sgt":  "sergeant",
+	},
+}
+
+//
+// Spanish.
+//
+
+var esOnes = []string{
+	"cero", "uno", "dos", "tres", "cuatro", "cinco", "seis", "siete", "ocho",
+	"nueve", "diez", "once", "doce", "trece", "catorce", "quince",
+	"dieciséis", "diecisiete", "dieciocho", "diecinueve", "veinte",
+	"veintiuno", "veintidós", "veintitrés", "veinticuatro", "veinticinco",
+	"veintiséis", "veintisiete", "veintiocho", "veintinueve",
+}
+
+var esTens = []string{
+	"", "", "", "treinta", "cuarenta", "cincuenta", "sesenta", "setenta",
+	"ochenta", "noventa",
+}
+
+var esHundreds = []string{
+	"", "ciento", "doscientos", "trescientos", "cuatrocientos", "quinientos",
+	"seiscientos", "setecientos", "ochocientos", "novecientos",
+}
+
+func esCardinal(n int64) string {
+	switch {
+	case n < 30:
+		return esOnes[n]
+	case n < 100:
+		s := esTens[n/10]
+		if n%10 != 0 {
+			s += " y " + esOnes[n%10]
+		}
+		return s
+	case n == 100:
+		return "cien"
+	case n < 1000:
+		s := esHundreds[n/100]
+		if n%100 != 0 {
+			s += " " + esCardinal(n%100)
+		}
+		return s
+	case n < 1_000_000:
+		s := "mil"
+		if n/1000 > 1 {
+			s = esApocope(esCardinal(n/1000)) + " mil"
+		}
+		if n%1000 != 0 {
+			s += " " + esCardinal(n%1000)
+		}
+		return s
+	}
+
+	s := "un millón"
+	if n/1_000_000 > 1 {
+		s = esApocope(esCardinal(n/1_000_000)) + " millones"
+	}
+	if n%1_000_000 != 0 {
+		s += " " + esCardinal(n%1_000_000)
+	}
+	return s
+}
+
+// esApocope shortens "uno" before "mil" and "millones" (e.g. "veintiún mil",
+// "treinta y un mil").
+func esApocope(s string) string {
+	switch {
+	case s == "veintiuno":
+		return "veintiún"
+	case strings.HasSuffix(s, "uno"):
+		return strings.TrimSuffix(s, "o")
+	}
+	return s
+}
+
+var esOrdinals = []string{
+	"", "primero", "segundo", "tercero", "cuarto", "quinto", "sexto",
+	"séptimo", "octavo", "noveno", "décimo",
+}
+
+// esOrdinal spells out the ordinal. Only the first ten are supported; the
+// rest use the cardinal, which is common in speech ("el piso quince").
+func esOrdinal(n int64, feminine bool) string {
+	if n < 1 || n >= int64(len(esOrdinals)) {
+		return esCardinal(n)
+	}
+	s := esOrdinals[n]
+	if feminine {
+		s = strings.TrimSuffix(s, "o") + "a"
+	}
+	return s
+}
+
+func esTime(h, m int64) string {
+	switch m {
+	case 0:
+		return esCardinal(h) + " en punto"
+	case 15:
+		return esCardinal(h) + " y cuarto"
+	case 30:
+		return esCardinal(h) + " y media"
+	}
+	return esCardinal(h) + " y " + esCardinal(m)
+}
+
+var esMonths = []string{
+	"", "enero", "febrero", "marzo", "abril", "mayo", "junio", "julio",
+	"agosto", "septiembre", "octubre", "noviembre", "diciembre",
+}
+
+func esDate(y, m, d int64) string {
+	day := esCardinal(d)
+	if d == 1 {
+		day = "primero"
+	}
+	return day + " de " + esMonths[m] + " de " + esCardinal(y)
+}
+
+var esExpander = &textExpander{
+	cardinal: esCardinal,
+	ordinal:  esOrdinal,
+	year:     esCardinal,
+	time:     esTime,
+	date:     esDate,
+
+	ordinalSuffixes: map[string]bool{
+		"º": false, "°": false, "o": false, "er": false, "ro": false,
+		"do": false, "to": false, "mo": false, "vo": false, "no": false,
+		"ª": true, "a": true, "ra": true, "da": true,
+	},
+
+	decimalSep:   ",",
+	thousandsSep: ".",
+
+	point:   "coma",
+	minus:   "menos",
+	percent: "por ciento",
+
+	abbrevs: map[string]string{
+		"sr":   "señor",
+		"sra":  "señora",
+		"srta": "señorita",
+		"dr":   "doctor",
+		"dra":  "doctora",
+		"ud":   "usted",
+		"uds":  "ustedes",
+		"etc":  "etcétera",
+		"av":   "avenida",
+		"prof": "profesor",
+		"gral": "general",
+	},
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/text/language"
+)
+
+func TestExpandWord(t *testing.T) {
+	cases := []struct {
+		word, lang, expected string
+	}{
+		// Nothing to expand.
+		{"hello", "en", ""},
+		{"Bright", "en", ""},
+
+		// English.
+		{"0", "en", "zero"},
+		{"42", "en-US", "forty-two"},
+		{"115", "en", "one hundred fifteen"},
+		{"1,234,567", "en", "one million two hundred thirty-four thousand " +
+			"five hundred sixty-seven"},
+		{"-3", "en", "minus three"},
+		{"3.14", "en", "three point one four"},
+		{"50%", "en", "fifty percent"},
+		{"1st", "en", "first"},
+		{"22nd", "en", "twenty-second"},
+		{"40th", "en", "fortieth"},
+		{"1985", "en", "nineteen eighty-five"},
+		{"1900", "en", "nineteen hundred"},
+		{"1905", "en", "nineteen oh five"},
+		{"2000", "en", "two thousand"},
+		{"2025", "en", "twenty twenty-five"},
+		{"10:00", "en", "ten o'clock"},
+		{"9:05", "en", "nine oh five"},
+		{"23:45", "en", "twenty-three forty-five"},
+		{"2025-10-18", "en", "october eighteenth twenty twenty-five"},
+		{"Dr", "en", "doctor"},
+		{"mrs.", "en", "missus"},
+
+		// Spanish.
+		{"16", "es", "dieciséis"},
+		{"31", "es", "treinta y uno"},
+		{"100", "es", "cien"},
+		{"101", "es", "ciento uno"},
+		{"1985", "es", "mil novecientos ochenta y cinco"},
+		{"2025", "es", "dos mil veinticinco"},
+		{"21000", "es", "veintiún mil"},
+		{"2.000.000", "es", "dos millones"},
+		{"3,14", "es", "tres coma uno cuatro"},
+		{"1º", "es", "primero"},
+		{"3ª", "es", "tercera"},
+		{"10:30", "es", "diez y media"},
+		{"2025-10-01", "es", "primero de octubre de dos mil veinticinco"},
+		{"Sra.", "es", "señora"},
+
+		// Things that only look like numbers.
+		{"3D", "en", ""},
+		{"3xx", "en", ""},
+		{"R2D2", "en", ""},
+
+		// Languages without an expander.
+		{"Dr", "fr", ""},
+	}
+	for _, c := range cases {
+		got, err := expandWord(c.word, c.lang)
+		if err != nil {
+			t.Errorf("expandWord(%q, %q): error %v", c.word, c.lang, err)
+			continue
+		}
+		if got != c.expected {
+			t.Errorf("expandWord(%q, %q) = %q, expected %q",
+				c.word, c.lang, got, c.expected)
+		}
+	}
+}
+
+func TestExpandWordErrors(t *testing.T) {
+	cases := []struct{ word, lang string }{
+		{"12", "fr"},
+		{"1,00", "en"},
+		{"25:00", "en"},
+		{"2025-13-01", "en"},
+		{"99999999999999999999", "en"},
+	}
+	for _, c := range cases {
+		if got, err := expandWord(c.word, c.lang); err == nil {
+			t.Errorf("expandWord(%q, %q) = %q, expected error",
+				c.word, c.lang, got)
+		}
+	}
+}
+
+func TestExpandWords(t *testing.T) {
+	det := detectLanguage([]string{"the"}, nil)
+	got, err := expandWords(
+		[]string{"the", "1st", "es:2", "10:30", "SH-fEEt"}, det, Options{})
+	if err != nil {
+		t.Fatalf("expandWords: %v", err)
+	}
+	expected := []string{"the", "first", "es:dos", "ten", "thirty", "SH-fEEt"}
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Errorf("expandWords mismatch:\n%s", diff)
+	}
+
+	// Hyphenated numbers are a single word, and lowercase abbreviations
+	// are expanded too.
+	got, err = expandWords([]string{"85", "dr", "3D"}, det, Options{})
+	if err != nil {
+		t.Fatalf("expandWords: %v", err)
+	}
+	expected = []string{"eighty-five", "doctor", "3D"}
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Errorf("expandWords mismatch:\n%s", diff)
+	}
+}
+
+// Numbers in languages without an expander are spelled out in the first
+// preferred language that has one, or in English.
+func TestExpandWordsFallback(t *testing.T) {
+	det := detectLanguage([]string{"ich", "möchte", "schlafen"}, nil)
+	if det.lang.String() != "de" {
+		t.Fatalf("unexpected detection: %v", det)
+	}
+
+	cases := []struct {
+		prefs    []language.Tag
+		expected []string
+	}{
+		{nil, []string{"ich", "en:three"}},
+		{[]language.Tag{language.French, language.Make("es-AR")},
+			[]string{"ich", "es:tres"}},
+	}
+	for _, c := range cases {
+		got, err := expandWords([]string{"ich", "3"}, det,
+			Options{langs: c.prefs})
+		if err != nil {
+			t.Errorf("%v: expandWords error: %v", c.prefs, err)
+			continue
+		}
+		if diff := cmp.Diff(c.expected, got); diff != "" {
+			t.Errorf("%v: expandWords mismatch:\n%s", c.prefs, diff)
+		}
+	}
+}
@@ -14,11 +14,45 @@ const usage = `# firstones - convert words to She-Ra First Ones language
 Usage:
 
   firstones [flags] svg [words...]
-    Generate an SVG image with the given words, printed to stdout.
+    Generate an SVG image with the given words, printed to stdout (or to
+    the file given with -o, which can also be PNG or PDF).
+  firstones [flags] gif [words...]
+    Generate an animated GIF drawing the words stroke by stroke, to stdout
+    (or to the file given with -o, which can also be an animated PNG).
+  firstones [flags] show [words...]
+    Draw the words in the terminal, with graphics if the terminal supports
+    them (see -terminal), or with Unicode braille characters.
+  firstones [flags] dxf [words...]
+    Generate a DXF drawing of the words, for laser cutting and CNC, to
+    stdout (or to the file given with -o).
+  firstones [flags] stl [words...]
+    Generate a 3D model of the words on a plate, as STL for 3D printing, to
+    stdout (or to the file given with -o).
+  firstones [flags] embroidery [words...]
+    Generate a stitch file of the words for embroidery machines, as DST to
+    stdout (or to the file given with -o, which can also be PES).
+  firstones [flags] tikz [words...]
+    Generate a TikZ picture of the words, for LaTeX documents, to stdout
+    (or to the file given with -o). See latex/firstones.sty to use it from
+    LaTeX directly.
+  firstones [flags] json [words...]
+    Print the glyphs for the given words as JSON, to stdout.
+  firstones [flags] detect [words...]
+    Show the detected language of the words, and how confident we are.
+  firstones [flags] batch <outdir> [input]
+    Generate an SVG image in outdir for each line of the input file (or
+    stdin), which can be text, CSV or JSON lines (see -batch-format).
+  firstones [flags] repl
+    Interactive mode: type words to see their glyphs (":help" for more).
+    In a terminal, the lines can be edited, and the arrows recall the
+    previous ones.
   firstones [flags] http <address>
     Start a web server at the given address.
   firstones [flags] dump-glyphs
     Generate an SVG image with all the glyphs, for debugging.
+  firstones [flags] ipa-coverage [dictionaries...]
+    Report which IPA symbols of the dictionaries can be converted to glyphs.
+    If no dictionaries are given, the embedded ones are used.
   firstones [flags] version
     Print software version information.
 
@@ -65,24 +99,85 @@ func main() {
 	flag.Usage = Usage
 	flag.Parse()
 
+	if *ipaRulesFlag != "" {
+		if err := loadIPARules(*ipaRulesFlag); err != nil {
+			fatalf("error loading IPA rules: %v", err)
+		}
+	}
+	if err := loadDicts(dictFlags); err != nil {
+		fatalf("error loading dictionaries: %v", err)
+	}
+	if *userDictFlag != "" {
+		d, err := loadUserDict(*userDictFlag)
+		if err != nil {
+			fatalf("error loading user dictionary: %v", err)
+		}
+		userDict.Store(d)
+	}
+
 	switch flag.Arg(0) {
 	case "version":
 		fmt.Println(Version())
 		os.Exit(0)
 	case "dump-glyphs":
 		dumpGlyphs(os.Stdout)
+	case "ipa-coverage":
+		if err := ipaCoverageCmd(os.Stdout, flag.Args()[1:]); err != nil {
+			fatalf("error: %v", err)
+		}
 	case "svg":
-		words := []string{}
-		args := flag.Args()
-		for i := 1; i < len(args); i++ {
-			for _, w := range strings.Fields(args[i]) {
-				words = append(words, w)
-			}
+		printSVG(wordsFromArgs(), mustOptionsFromFlags())
+	case "gif":
+		err := gifCmd(os.Stdout, wordsFromArgs(), mustOptionsFromFlags())
+		if err != nil {
+			fatalf("error: %v", err)
+		}
+	case "show":
+		err := showCmd(os.Stdout, wordsFromArgs(), mustOptionsFromFlags(),
+			isTerminal(os.Stdout))
+		if err != nil {
+			fatalf("error: %v", err)
+		}
+	case "dxf":
+		err := dxfCmd(os.Stdout, wordsFromArgs(), mustOptionsFromFlags())
+		if err != nil {
+			fatalf("error: %v", err)
+		}
+	case "stl":
+		err := stlCmd(os.Stdout, wordsFromArgs(), mustOptionsFromFlags())
+		if err != nil {
+			fatalf("error: %v", err)
+		}
+	case "embroidery":
+		err := embroideryCmd(os.Stdout, wordsFromArgs(),
+			mustOptionsFromFlags())
+		if err != nil {
+			fatalf("error: %v", err)
+		}
+	case "tikz":
+		err := tikzCmd(os.Stdout, wordsFromArgs(), mustOptionsFromFlags())
+		if err != nil {
+			fatalf("error: %v", err)
+		}
+	case "json":
+		printJSON(wordsFromArgs(), mustOptionsFromFlags())
+	case "detect":
+		detectCmd(os.Stdout, wordsFromArgs(), mustOptionsFromFlags())
+	case "batch":
+		if len(flag.Args()) < 2 || len(flag.Args()) > 3 {
+			fmt.Fprintln(os.Stderr,
+				"Usage: firstones batch <outdir> [input]")
+			os.Exit(1)
 		}
-		if len(words) == 0 {
-			words = []string{"SH-fEEt-R-All"}
+		err := batchCmd(os.Stderr, flag.Arg(1), flag.Arg(2),
+			mustOptionsFromFlags())
+		if err != nil {
+			fatalf("error: %v", err)
+		}
+	case "repl":
+		if err := replCmd(os.Stdin, os.Stdout, mustOptionsFromFlags()); err != nil {
+			fatalf("error: %v", err)
 		}
-		printSVG(words)
 	case "http":
 		if len(flag.Args()) < 2 {
 			fmt.Fprintln(os.Stderr, "Usage: firstones http <address>")
@@ -96,19 +191,45 @@ func main() {
 	}
 }
 
-func printSVG(words []string) {
-	wsvg, width, height, err := wordsToSVG(words)
+// wordsFromArgs returns the words given in the command line arguments, after
+// the command name.
+func wordsFromArgs() []string {
+	words := tokenize(strings.Join(flag.Args()[1:], " "))
+	if len(words) == 0 {
+		words = []string{"SH-fEEt-R-All"}
+	}
+	return words
+}
+
+func mustOptionsFromFlags() Options {
+	opts, err := optionsFromFlags()
 	if err != nil {
-		fatalf("error converting words to SVG: %v", err)
+		fatalf("error in options: %v", err)
 	}
+	return opts
+}
 
-	fmt.Print(string(svgHeader(width, height)))
-	writeDefs(os.Stdout)
+// printSVG renders the words, and writes them to stdout as SVG, or to the
+// output file given with -o, in the format of its extension.
+func printSVG(words []string, opts Options) {
+	doc, err := renderDocument(words, opts, *showGrid)
+	if err != nil {
+		fatalf("error converting words to SVG: %v", err)
+	}
 
-	if *showGrid {
-		fmt.Print(string(svgGrid(width, height)))
+	if *outputFlag != "" {
+		if err := writeOutput(*outputFlag, doc, *overwriteFlag); err != nil {
+			fatalf("error writing output: %v", err)
+		}
+		return
 	}
+	fmt.Print(doc.svg)
+}
 
-	fmt.Print(string(wsvg))
-	fmt.Println("</svg>")
+func printJSON(words []string, opts Options) {
+	j, err := wordsToJSON(words, opts)
+	if err != nil {
+		fatalf("error converting words to JSON: %v", err)
+	}
+	fmt.Println(string(j))
 }
@@ -0,0 +1,453 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/language"
+)
+
+// # Rule-based grapheme to phoneme conversion
+//
+// For languages where we don't have a dictionary, we convert the spelling to
+// IPA using a small set of rules. These languages have a fairly regular
+// spelling, so the rules get us close enough for our purposes (we end up
+// approximating the sounds with the glyphs anyway).
+//
+// The rules are not meant to be complete. In particular, they don't handle
+// stress, loanwords, or most exceptions. Words can always be added (or
+// corrected) with a dictionary or the user dictionary, which take
+// precedence.
+
+// A G2P rule: if the letters match, and the context matches, then we produce
+// the given IPA and move past the letters.
+type g2pRule struct {
+	letters string
+	ipa     string
+
+	// Optional context: the rule only applies if the previous (or next)
+	// letter is one of these. "#" means the beginning (or end) of the word.
+	prev, next string
+}
+
+type g2pRules []g2pRule
+
+// convert the word to IPA.
+// At each position, the rule with the longest match wins; for rules of the
+// same length, the first one wins. Letters that don't match any rule are
+// skipped, and returned so they can be reported.
+func (rules g2pRules) convert(word string) (ipa string, skipped []string) {
+	w := []rune(strings.ToLower(word))
+	for i := 0; i < len(w); {
+		best := -1
+		for ri, rule := range rules {
+			if !rule.matches(w, i) {
+				continue
+			}
+			if best < 0 || utf8.RuneCountInString(rule.letters) >
+				utf8.RuneCountInString(rules[best].letters) {
+				best = ri
+			}
+		}
+		if best < 0 {
+			skipped = append(skipped, string(w[i]))
+			i++
+			continue
+		}
+		ipa += rules[best].ipa
+		i += len([]rune(rules[best].letters))
+	}
+	return ipa, skipped
+}
+
+func (rule g2pRule) matches(w []rune, i int) bool {
+	letters := []rune(rule.letters)
+	if i+len(letters) > len(w) || !slices.Equal(w[i:i+len(letters)], letters) {
+		return false
+	}
+
+	if rule.prev != "" {
+		prev := '#'
+		if i > 0 {
+			prev = w[i-1]
+		}
+		if !strings.ContainsRune(rule.prev, prev) {
+			return false
+		}
+	}
+
+	if rule.next != "" {
+		next := '#'
+		if end := i + len(letters); end < len(w) {
+			next = w[end]
+		}
+		if !strings.ContainsRune(rule.next, next) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// The languages we have G2P rules for.
+var g2pLangs = map[language.Tag]g2pRules{
+	language.French:     frRules,
+	language.German:     deRules,
+	language.Portuguese: ptRules,
+	language.Italian:    itRules,
+}
+
+// G2P rules, by dictionary index (like IPADicts).
+var dictG2P = map[int]g2pRules{}
+
+// registerG2PLangs registers the languages we have G2P rules for, so the
+// langMatcher knows about them. This must be called after the dictionaries
+// are registered, so if there's a dictionary for the language, it is used
+// first.
+func registerG2PLangs() {
+	// Go through them in order, so the dictionary indices are stable.
+	tags := []language.Tag{}
+	for tag := range g2pLangs {
+		tags = append(tags, tag)
+	}
+	slices.SortFunc(tags, func(a, b language.Tag) int {
+		return strings.Compare(a.String(), b.String())
+	})
+
+	for _, tag := range tags {
+		dictG2P[addDict(tag, IPADict{})] = g2pLangs[tag]
+	}
+}
+
+//
+// French.
+//
+
+const (
+	frVowels = "aeiouyàâäéèêëîïôöûüùœæ"
+	frFront  = "eiyéèêëîï"
+
+	// Consonants that close a nasal vowel (so not "n" or "m").
+	frNasalEnd = "bcdfgjklpqrstvwxzç#"
+)
+
+var frRules = g2pRules{
+	// Vowel combinations.
+	{letters: "eau", ipa: "o"},
+	{letters: "au", ipa: "o"},
+	{letters: "ai", ipa: "ɛ"},
+	{letters: "ei", ipa: "ɛ"},
+	{letters: "oi", ipa: "wa"},
+	{letters: "ou", ipa: "u"},
+	{letters: "où", ipa: "u"},
+	{letters: "eu", ipa: "ø"},
+	{letters: "œu", ipa: "ø"},
+	{letters: "ill", ipa: "ij"},
+	{letters: "aill", ipa: "aj"},
+
+	// Nasal vowels.
+	{letters: "an", ipa: "ɑ̃", next: frNasalEnd},
+	{letters: "am", ipa: "ɑ̃", next: frNasalEnd},
+	{letters: "en", ipa: "ɑ̃", next: frNasalEnd},
+	{letters: "em", ipa: "ɑ̃", next: frNasalEnd},
+	{letters: "in", ipa: "ɛ̃", next: frNasalEnd},
+	{letters: "im", ipa: "ɛ̃", next: frNasalEnd},
+	{letters: "ain", ipa: "ɛ̃", next: frNasalEnd},
+	{letters: "ein", ipa: "ɛ̃", next: frNasalEnd},
+	{letters: "ien", ipa: "jɛ̃", next: frNasalEnd},
+	{letters: "oin", ipa: "wɛ̃", next: frNasalEnd},
+	{letters: "on", ipa: "ɔ̃", next: frNasalEnd},
+	{letters: "om", ipa: "ɔ̃", next: frNasalEnd},
+	{letters: "un", ipa: "œ̃", next: frNasalEnd},
+	{letters: "um", ipa: "œ̃", next: frNasalEnd},
+
+	// Word endings.
+	{letters: "er", ipa: "e", next: "#"},
+	{letters: "ez", ipa: "e", next: "#"},
+	{letters: "et", ipa: "ɛ", next: "#"},
+	{letters: "es", ipa: "", next: "#", prev: "bcdfghjklmnpqrstvwxz"},
+	{letters: "e", ipa: "", next: "#", prev: "bcdfghjklmnpqrstvwxziéuy"},
+	{letters: "s", ipa: "", next: "#"},
+	{letters: "x", ipa: "", next: "#"},
+	{letters: "t", ipa: "", next: "#"},
+	{letters: "d", ipa: "", next: "#"},
+	{letters: "p", ipa: "", next: "#"},
+
+	// Consonants.
+	{letters: "ch", ipa: "ʃ"},
+	{letters: "gn", ipa: "ɲ"},
+	{letters: "ph", ipa: "f"},
+	{letters: "th", ipa: "t"},
+	{letters: "qu", ipa: "k"},
+	{letters: "gu", ipa: "ɡ", next: frFront},
+	{letters: "cc", ipa: "ks", next: frFront},
+	{letters: "c", ipa: "s", next: frFront},
+	{letters: "c", ipa: "k"},
+	{letters: "ç", ipa: "s"},
+	{letters: "g", ipa: "ʒ", next: frFront},
+	{letters: "g", ipa: "ɡ"},
+	{letters: "j", ipa: "ʒ"},
+	{letters: "ss", ipa: "s"},
+	{letters: "s", ipa: "z", prev: frVowels, next: frVowels},
+	{letters: "s", ipa: "s"},
+	{letters: "x", ipa: "ks"},
+	{letters: "h", ipa: ""},
+	{letters: "r", ipa: "ʁ"},
+	{letters: "b", ipa: "b"}, {letters: "d", ipa: "d"},
+	{letters: "f", ipa: "f"}, {letters: "k", ipa: "k"},
+	{letters: "l", ipa: "l"}, {letters: "m", ipa: "m"},
+	{letters: "n", ipa: "n"}, {letters: "p", ipa: "p"},
+	{letters: "q", ipa: "k"}, {letters: "t", ipa: "t"},
+	{letters: "v", ipa: "v"}, {letters: "w", ipa: "w"},
+	{letters: "z", ipa: "z"},
+
+	// Double consonants sound like single ones.
+	{letters: "bb", ipa: "b"}, {letters: "cc", ipa: "k"},
+	{letters: "dd", ipa: "d"}, {letters: "ff", ipa: "f"},
+	{letters: "gg", ipa: "ɡ"}, {letters: "ll", ipa: "l"},
+	{letters: "mm", ipa: "m"}, {letters: "nn", ipa: "n"},
+	{letters: "pp", ipa: "p"}, {letters: "rr", ipa: "ʁ"},
+	{letters: "tt", ipa: "t"},
+
+	// Vowels.
+	{letters: "a", ipa: "a"}, {letters: "à", ipa: "a"},
+	{letters: "â", ipa: "ɑ"}, {letters: "e", ipa: "ə"},
+	{letters: "é", ipa: "e"}, {letters: "è", ipa: "ɛ"},
+	{letters: "ê", ipa: "ɛ"}, {letters: "ë", ipa: "ɛ"},
+	{letters: "i", ipa: "i"}, {letters: "î", ipa: "i"},
+	{letters: "ï", ipa: "i"}, {letters: "y", ipa: "i"},
+	{letters: "o", ipa: "o"}, {letters: "ô", ipa: "o"},
+	{letters: "u", ipa: "y"}, {letters: "û", ipa: "y"},
+	{letters: "ù", ipa: "y"}, {letters: "œ", ipa: "œ"},
+}
+
+//
+// German.
+//
+
+const (
+	deVowels = "aeiouäöüy"
+	deBack   = "aou"
+)
+
+var deRules = g2pRules{
+	// Vowel combinations.
+	{letters: "ei", ipa: "aɪ"},
+	{letters: "ai", ipa: "aɪ"},
+	{letters: "eu", ipa: "ɔɪ"},
+	{letters: "äu", ipa: "ɔɪ"},
+	{letters: "au", ipa: "aʊ"},
+	{letters: "ie", ipa: "i"},
+	{letters: "ieh", ipa: "i"},
+	{letters: "aa", ipa: "a"},
+	{letters: "ee", ipa: "e"},
+	{letters: "oo", ipa: "o"},
+	{letters: "ah", ipa: "a", next: "bcdfgklmnprstvwz#"},
+	{letters: "eh", ipa: "e", next: "bcdfgklmnprstvwz#"},
+	{letters: "ih", ipa: "i", next: "bcdfgklmnprstvwz#"},
+	{letters: "oh", ipa: "o", next: "bcdfgklmnprstvwz#"},
+	{letters: "uh", ipa: "u", next: "bcdfgklmnprstvwz#"},
+	{letters: "äh", ipa: "ɛ", next: "bcdfgklmnprstvwz#"},
+	{letters: "öh", ipa: "ø", next: "bcdfgklmnprstvwz#"},
+	{letters: "üh", ipa: "y", next: "bcdfgklmnprstvwz#"},
+
+	// Word endings.
+	{letters: "er", ipa: "ɐ", next: "#"},
+	{letters: "e", ipa: "ə", next: "#"},
+	{letters: "en", ipa: "ən", next: "#"},
+	{letters: "ig", ipa: "ɪç", next: "#"},
+	{letters: "b", ipa: "p", next: "#"},
+	{letters: "d", ipa: "t", next: "#"},
+	{letters: "g", ipa: "k", next: "#"},
+
+	// Consonants.
+	{letters: "tsch", ipa: "tʃ"},
+	{letters: "sch", ipa: "ʃ"},
+	{letters: "chs", ipa: "ks"},
+	{letters: "ch", ipa: "x", prev: deBack},
+	{letters: "ch", ipa: "ç"},
+	{letters: "ck", ipa: "k"},
+	{letters: "ph", ipa: "f"},
+	{letters: "th", ipa: "t"},
+	{letters: "qu", ipa: "kv"},
+	{letters: "sp", ipa: "ʃp", prev: "#"},
+	{letters: "st", ipa: "ʃt", prev: "#"},
+	{letters: "ss", ipa: "s"},
+	{letters: "ß", ipa: "s"},
+	{letters: "s", ipa: "z", next: deVowels},
+	{letters: "s", ipa: "s"},
+	{letters: "tz", ipa: "ts"},
+	{letters: "z", ipa: "ts"},
+	{letters: "v", ipa: "f"},
+	{letters: "w", ipa: "v"},
+	{letters: "j", ipa: "j"},
+	{letters: "ng", ipa: "ŋ"},
+	{letters: "nk", ipa: "ŋk"},
+	{letters: "x", ipa: "ks"},
+	{letters: "c", ipa: "k"},
+	{letters: "h", ipa: "h"},
+	{letters: "r", ipa: "ʁ"},
+	{letters: "b", ipa: "b"}, {letters: "d", ipa: "d"},
+	{letters: "f", ipa: "f"}, {letters: "g", ipa: "ɡ"},
+	{letters: "k", ipa: "k"}, {letters: "l", ipa: "l"},
+	{letters: "m", ipa: "m"}, {letters: "n", ipa: "n"},
+	{letters: "p", ipa: "p"}, {letters: "t", ipa: "t"},
+
+	// Double consonants sound like single ones.
+	{letters: "bb", ipa: "b"}, {letters: "dd", ipa: "d"},
+	{letters: "ff", ipa: "f"}, {letters: "gg", ipa: "ɡ"},
+	{letters: "ll", ipa: "l"}, {letters: "mm", ipa: "m"},
+	{letters: "nn", ipa: "n"}, {letters: "pp", ipa: "p"},
+	{letters: "rr", ipa: "ʁ"}, {letters: "tt", ipa: "t"},
+
+	// Vowels.
+	{letters: "a", ipa: "a"}, {letters: "e", ipa: "ɛ"},
+	{letters: "i", ipa: "ɪ"}, {letters: "o", ipa: "ɔ"},
+	{letters: "u", ipa: "ʊ"}, {letters: "ä", ipa: "ɛ"},
+	{letters: "ö", ipa: "ø"}, {letters: "ü", ipa: "y"},
+	{letters: "y", ipa: "y"},
+}
+
+//
+// Portuguese (Brazilian).
+//
+
+const (
+	ptVowels = "aeiouáéíóúâêôãõàü"
+	ptFront  = "eiéíê"
+
+	// Consonants that close a nasal vowel (so not "n" or "m").
+	ptNasalEnd = "bcdfgjklpqrstvxzç#"
+)
+
+var ptRules = g2pRules{
+	// Nasal vowels and diphthongs.
+	{letters: "ão", ipa: "ɐ̃w"},
+	{letters: "ãe", ipa: "ɐ̃j"},
+	{letters: "õe", ipa: "õj"},
+	{letters: "am", ipa: "ɐ̃w", next: "#"},
+	{letters: "em", ipa: "ẽj", next: "#"},
+	{letters: "an", ipa: "ɐ̃", next: ptNasalEnd},
+	{letters: "am", ipa: "ɐ̃", next: ptNasalEnd},
+	{letters: "en", ipa: "ẽ", next: ptNasalEnd},
+	{letters: "em", ipa: "ẽ", next: ptNasalEnd},
+	{letters: "in", ipa: "ĩ", next: ptNasalEnd},
+	{letters: "im", ipa: "ĩ", next: ptNasalEnd},
+	{letters: "on", ipa: "õ", next: ptNasalEnd},
+	{letters: "om", ipa: "õ", next: ptNasalEnd},
+	{letters: "un", ipa: "ũ", next: ptNasalEnd},
+	{letters: "um", ipa: "ũ", next: ptNasalEnd},
+	{letters: "ã", ipa: "ɐ̃"},
+	{letters: "õ", ipa: "õ"},
+
+	// Word endings.
+	{letters: "e", ipa: "i", next: "#"},
+	{letters: "es", ipa: "is", next: "#"},
+	{letters: "o", ipa: "u", next: "#"},
+	{letters: "os", ipa: "us", next: "#"},
+	{letters: "l", ipa: "w", next: "#"},
+	{letters: "z", ipa: "s", next: "#"},
+
+	// Consonants.
+	{letters: "nh", ipa: "ɲ"},
+	{letters: "lh", ipa: "ʎ"},
+	{letters: "ch", ipa: "ʃ"},
+	{letters: "ç", ipa: "s"},
+	{letters: "c", ipa: "s", next: ptFront},
+	{letters: "c", ipa: "k"},
+	{letters: "qu", ipa: "k", next: ptFront},
+	{letters: "qu", ipa: "kw"},
+	{letters: "gu", ipa: "ɡ", next: ptFront},
+	{letters: "g", ipa: "ʒ", next: ptFront},
+	{letters: "g", ipa: "ɡ"},
+	{letters: "j", ipa: "ʒ"},
+	{letters: "rr", ipa: "ʁ"},
+	{letters: "r", ipa: "ʁ", prev: "#"},
+	{letters: "r", ipa: "ɾ"},
+	{letters: "ss", ipa: "s"},
+	{letters: "s", ipa: "z", prev: ptVowels, next: ptVowels},
+	{letters: "s", ipa: "s"},
+	{letters: "x", ipa: "ʃ"},
+	{letters: "z", ipa: "z"},
+	{letters: "h", ipa: ""},
+	{letters: "b", ipa: "b"}, {letters: "d", ipa: "d"},
+	{letters: "f", ipa: "f"}, {letters: "k", ipa: "k"},
+	{letters: "l", ipa: "l"}, {letters: "m", ipa: "m"},
+	{letters: "n", ipa: "n"}, {letters: "p", ipa: "p"},
+	{letters: "t", ipa: "t"}, {letters: "v", ipa: "v"},
+	{letters: "w", ipa: "w"}, {letters: "y", ipa: "j"},
+
+	// Vowels.
+	{letters: "a", ipa: "a"}, {letters: "á", ipa: "a"},
+	{letters: "à", ipa: "a"}, {letters: "â", ipa: "ɐ"},
+	{letters: "e", ipa: "e"}, {letters: "é", ipa: "ɛ"},
+	{letters: "ê", ipa: "e"}, {letters: "i", ipa: "i"},
+	{letters: "í", ipa: "i"}, {letters: "o", ipa: "o"},
+	{letters: "ó", ipa: "ɔ"}, {letters: "ô", ipa: "o"},
+	{letters: "u", ipa: "u"}, {letters: "ú", ipa: "u"},
+	{letters: "ü", ipa: "u"},
+}
+
+//
+// Italian.
+//
+
+const (
+	itVowels = "aeiouàèéìòóù"
+	itFront  = "eièéì"
+	itBack   = "aouàòóù"
+)
+
+var itRules = g2pRules{
+	// Consonants.
+	{letters: "gli", ipa: "ʎ", next: itVowels},
+	{letters: "gli", ipa: "ʎi"},
+	{letters: "gn", ipa: "ɲ"},
+	{letters: "sci", ipa: "ʃ", next: itBack},
+	{letters: "sc", ipa: "ʃ", next: itFront},
+	{letters: "ch", ipa: "k"},
+	{letters: "gh", ipa: "ɡ"},
+	{letters: "cci", ipa: "tʃ", next: itBack},
+	{letters: "cc", ipa: "tʃ", next: itFront},
+	{letters: "ci", ipa: "tʃ", next: itBack},
+	{letters: "c", ipa: "tʃ", next: itFront},
+	{letters: "c", ipa: "k"},
+	{letters: "ggi", ipa: "dʒ", next: itBack},
+	{letters: "gg", ipa: "dʒ", next: itFront},
+	{letters: "gi", ipa: "dʒ", next: itBack},
+	{letters: "g", ipa: "dʒ", next: itFront},
+	{letters: "g", ipa: "ɡ"},
+	{letters: "qu", ipa: "kw"},
+	{letters: "zz", ipa: "ts"},
+	{letters: "z", ipa: "ts"},
+	{letters: "s", ipa: "z", prev: itVowels, next: itVowels},
+	{letters: "s", ipa: "s"},
+	{letters: "h", ipa: ""},
+	{letters: "j", ipa: "j"},
+	{letters: "r", ipa: "r"},
+	{letters: "b", ipa: "b"}, {letters: "d", ipa: "d"},
+	{letters: "f", ipa: "f"}, {letters: "k", ipa: "k"},
+	{letters: "l", ipa: "l"}, {letters: "m", ipa: "m"},
+	{letters: "n", ipa: "n"}, {letters: "p", ipa: "p"},
+	{letters: "t", ipa: "t"}, {letters: "v", ipa: "v"},
+	{letters: "w", ipa: "w"}, {letters: "x", ipa: "ks"},
+	{letters: "y", ipa: "i"},
+
+	// Double consonants sound like single ones (for our purposes).
+	{letters: "bb", ipa: "b"}, {letters: "cc", ipa: "k"},
+	{letters: "dd", ipa: "d"}, {letters: "ff", ipa: "f"},
+	{letters: "gg", ipa: "ɡ"}, {letters: "ll", ipa: "l"},
+	{letters: "mm", ipa: "m"}, {letters: "nn", ipa: "n"},
+	{letters: "pp", ipa: "p"}, {letters: "rr", ipa: "r"},
+	{letters: "ss", ipa: "s"}, {letters: "tt", ipa: "t"},
+	{letters: "vv", ipa: "v"},
+
+	// Vowels.
+	{letters: "a", ipa: "a"}, {letters: "à", ipa: "a"},
+	{letters: "e", ipa: "e"}, {letters: "é", ipa: "e"},
+	{letters: "è", ipa: "ɛ"}, {letters: "i", ipa: "i"},
+	{letters: "ì", ipa: "i"}, {letters: "o", ipa: "o"},
+	{letters: "ó", ipa: "o"}, {letters: "ò", ipa: "ɔ"},
+	{letters: "u", ipa: "u"}, {letters: "ù", ipa: "u"},
+}
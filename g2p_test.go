@@ -0,0 +1,71 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestG2P(t *testing.T) {
+	cases := []struct {
+		rules    g2pRules
+		word     string
+		expected string
+	}{
+		// French.
+		{frRules, "bonjour", "bɔ̃ʒuʁ"},
+		{frRules, "maison", "mɛzɔ̃"},
+		{frRules, "chat", "ʃa"},
+		{frRules, "garçon", "ɡaʁsɔ̃"},
+		{frRules, "Année", "ane"},
+
+		// German.
+		{deRules, "ich", "ɪç"},
+		{deRules, "Buch", "bʊx"},
+		{deRules, "Straße", "ʃtʁasə"},
+		{deRules, "schön", "ʃøn"},
+
+		// Portuguese.
+		{ptRules, "coração", "koɾasɐ̃w"},
+		{ptRules, "obrigado", "obɾiɡadu"},
+		{ptRules, "filho", "fiʎu"},
+
+		// Italian.
+		{itRules, "ciao", "tʃao"},
+		{itRules, "gnocchi", "ɲoki"},
+		{itRules, "pizza", "pitsa"},
+		{itRules, "famiglia", "famiʎa"},
+	}
+	for _, c := range cases {
+		got, _ := c.rules.convert(c.word)
+		if got != c.expected {
+			t.Errorf("convert(%q) = %q, expected %q", c.word, got, c.expected)
+		}
+	}
+
+	// Letters without rules are skipped, and returned.
+	ipa, skipped := itRules.convert("ñandú")
+	if ipa != "and" || !slices.Equal(skipped, []string{"ñ", "ú"}) {
+		t.Errorf(`convert("ñandú") = %q, %q; expected "and", ["ñ" "ú"]`,
+			ipa, skipped)
+	}
+}
+
+func TestG2PLookup(t *testing.T) {
+	// Words not in any dictionary use the rules.
+	ipa, _, err := lookupIPA("bonjour", "fr")
+	if err != nil || ipa != "bɔ̃ʒuʁ" {
+		t.Errorf(`lookupIPA("bonjour", "fr") = %q, %v`, ipa, err)
+	}
+
+	// But names are still looked up in the dictionary.
+	ipa, _, err = lookupIPA("catra", "de")
+	if err != nil || ipa != namesIPA["catra"] {
+		t.Errorf(`lookupIPA("catra", "de") = %q, %v`, ipa, err)
+	}
+
+	// Unsupported languages.
+	_, _, err = lookupIPA("hola", "xx")
+	if err == nil {
+		t.Errorf(`lookupIPA("hola", "xx") did not fail`)
+	}
+}
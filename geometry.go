@@ -0,0 +1,901 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// # Geometry
+//
+// The SVG we generate is the source of truth for the layout, but the other
+// output formats (PNG, PDF, ...) need the actual shapes, with their absolute
+// coordinates.
+//
+// So we parse the SVG back into a scene: a flat list of shapes (paths and
+// circles), with the transformations already applied, and the paint
+// (fill, stroke and stroke width) resolved.
+//
+// This only supports the subset of SVG that we generate: groups with
+// translate/rotate/scale transforms, <use> references to the glyph
+// definitions, and the basic shapes. Paths only have straight lines.
+//
+// The shapes are in document order, which is also the reading order: words
+// right to left, syllables along the word line, glyphs top to bottom.
+
+type point struct {
+	x, y float64
+}
+
+// A connected sequence of points.
+type subpath struct {
+	points []point
+	closed bool
+}
+
+// A shape in the scene, in absolute coordinates (SVG user units, which are
+// millimetres).
+type shape struct {
+	// The outline, for paths (including lines, polygons and rectangles).
+	subpaths []subpath
+
+	// The center and radius, for circles (in which case there are no
+	// subpaths).
+	circle bool
+	center point
+	r      float64
+
+	// Paint. A zero alpha means the fill (or stroke) is not painted.
+	fill        color.NRGBA
+	stroke      color.NRGBA
+	strokeWidth float64
+
+	// Line caps (butt, round, square) and joins (miter, round, bevel).
+	linecap  string
+	linejoin string
+
+	// Name of the glyph this shape is part of, if any.
+	glyph string
+
+	// Class of the innermost element with one that contains the shape, if
+	// any. It tells which part of the drawing the shape is (for example,
+	// "word-line").
+	class string
+
+	// Number of the <use> element the shape comes from (counting from 1,
+	// in document order), or 0 if it doesn't come from one. This tells
+	// apart the shapes of different instances of the same glyph.
+	use int
+}
+
+// hasFill returns true if the shape's fill is painted.
+func (s shape) hasFill() bool {
+	return s.fill.A > 0
+}
+
+// hasStroke returns true if the shape's stroke is painted.
+func (s shape) hasStroke() bool {
+	return s.stroke.A > 0 && s.strokeWidth > 0
+}
+
+// bounds returns the bounding box of the shape, including the stroke.
+func (s shape) bounds() (min, max point) {
+	hw := 0.0
+	if s.hasStroke() {
+		hw = s.strokeWidth / 2
+		switch {
+		case s.linejoin == "miter":
+			hw *= miterLimit
+		case s.linecap == "square":
+			hw *= math.Sqrt2
+		}
+	}
+	if s.circle {
+		d := s.r + hw
+		return point{s.center.x - d, s.center.y - d},
+			point{s.center.x + d, s.center.y + d}
+	}
+
+	min = point{math.Inf(1), math.Inf(1)}
+	max = point{math.Inf(-1), math.Inf(-1)}
+	for _, sp := range s.subpaths {
+		for _, p := range sp.points {
+			min = point{math.Min(min.x, p.x-hw), math.Min(min.y, p.y-hw)}
+			max = point{math.Max(max.x, p.x+hw), math.Max(max.y, p.y+hw)}
+		}
+	}
+	return min, max
+}
+
+// Number of points used to approximate a circle, when it needs to be a path.
+const circlePoints = 64
+
+// outline returns the subpaths of the shape, approximating circles with a
+// polygon (starting on the right, and going clockwise).
+func (s shape) outline() []subpath {
+	if !s.circle {
+		return s.subpaths
+	}
+	pts := make([]point, circlePoints)
+	for i := range pts {
+		a := 2 * math.Pi * float64(i) / circlePoints
+		pts[i] = point{
+			s.center.x + s.r*math.Cos(a), s.center.y + s.r*math.Sin(a)}
+	}
+	return []subpath{{points: pts, closed: true}}
+}
+
+// partial returns the shapes to draw the fraction f (between 0 and 1) of
+// the shape, like a pen would: the stroke is cut at that fraction of its
+// length, following the subpaths in order, and the fill fades in.
+func (s shape) partial(f float64) []shape {
+	if f >= 1 {
+		return []shape{s}
+	}
+	shapes := []shape{}
+	if f <= 0 {
+		return shapes
+	}
+	if s.hasFill() {
+		fill := s
+		fill.stroke = color.NRGBA{}
+		fill.fill.A = uint8(float64(s.fill.A)*f + 0.5)
+		shapes = append(shapes, fill)
+	}
+	if !s.hasStroke() {
+		return shapes
+	}
+
+	sps := s.outline()
+	length := 0.0
+	for _, sp := range sps {
+		segments(sp, func(a, b point) {
+			length += math.Hypot(b.x-a.x, b.y-a.y)
+		})
+	}
+
+	remaining := length * f
+	cut := []subpath{}
+	for _, sp := range sps {
+		if remaining <= 0 {
+			break
+		}
+		pts := []point{sp.points[0]}
+		segments(sp, func(a, b point) {
+			if remaining <= 0 {
+				return
+			}
+			l := math.Hypot(b.x-a.x, b.y-a.y)
+			if l <= remaining {
+				pts = append(pts, b)
+				remaining -= l
+				return
+			}
+			t := remaining / l
+			pts = append(pts, point{a.x + (b.x-a.x)*t, a.y + (b.y-a.y)*t})
+			remaining = 0
+		})
+		cut = append(cut, subpath{points: pts})
+	}
+
+	stroke := s
+	stroke.circle = false
+	stroke.subpaths = cut
+	stroke.fill = color.NRGBA{}
+	return append(shapes, stroke)
+}
+
+// Limit of the ratio between the length of a miter join and the stroke
+// width, beyond which it becomes a bevel join. Like SVG's default.
+const miterLimit = 4
+
+// A disk, used for round caps and joins.
+type disk struct {
+	center point
+	r      float64
+}
+
+// strokeParts returns the area covered by the stroke of the shape (which
+// must not be a circle), as polygons and disks that overlap each other:
+// a rectangle for each segment, and the caps and joins.
+func (s shape) strokeParts() ([]subpath, []disk) {
+	hw := s.strokeWidth / 2
+	polys := []subpath{}
+	disks := []disk{}
+
+	// box returns the rectangle around a-b, extended by ea and eb
+	// at each end.
+	box := func(a, b point, ea, eb float64) subpath {
+		l := math.Hypot(b.x-a.x, b.y-a.y)
+		d := point{(b.x - a.x) / l, (b.y - a.y) / l}
+		n := point{-d.y * hw, d.x * hw}
+		a = point{a.x - d.x*ea, a.y - d.y*ea}
+		b = point{b.x + d.x*eb, b.y + d.y*eb}
+		return subpath{closed: true, points: []point{
+			{a.x + n.x, a.y + n.y}, {b.x + n.x, b.y + n.y},
+			{b.x - n.x, b.y - n.y}, {a.x - n.x, a.y - n.y},
+		}}
+	}
+
+	for _, sp := range s.subpaths {
+		// Remove repeated points, they don't contribute to the stroke.
+		pts := []point{}
+		for _, p := range sp.points {
+			if len(pts) == 0 || p != pts[len(pts)-1] {
+				pts = append(pts, p)
+			}
+		}
+		closed := sp.closed && len(pts) > 2
+		if closed && pts[0] == pts[len(pts)-1] {
+			pts = pts[:len(pts)-1]
+		}
+
+		// A single point is only drawn with round or square caps.
+		if len(pts) == 1 {
+			switch s.linecap {
+			case "round":
+				disks = append(disks, disk{pts[0], hw})
+			case "square":
+				p := pts[0]
+				polys = append(polys, box(
+					point{p.x - hw, p.y}, point{p.x + hw, p.y}, 0, 0))
+			}
+			continue
+		}
+
+		n := len(pts)
+		nsegs := n - 1
+		if closed {
+			nsegs = n
+		}
+		for i := range nsegs {
+			a, b := pts[i], pts[(i+1)%n]
+			ea, eb := 0.0, 0.0
+			if !closed && s.linecap == "square" {
+				if i == 0 {
+					ea = hw
+				}
+				if i == nsegs-1 {
+					eb = hw
+				}
+			}
+			polys = append(polys, box(a, b, ea, eb))
+		}
+
+		if !closed && s.linecap == "round" {
+			disks = append(disks, disk{pts[0], hw}, disk{pts[n-1], hw})
+		}
+
+		// Joins, at each point between two segments.
+		for i := range n {
+			if !closed && (i == 0 || i == n-1) {
+				continue
+			}
+			prev, v, next := pts[(i+n-1)%n], pts[i], pts[(i+1)%n]
+			if s.linejoin == "round" {
+				disks = append(disks, disk{v, hw})
+				continue
+			}
+			if join, ok := joinPolygon(prev, v, next, hw,
+				s.linejoin == "miter"); ok {
+				polys = append(polys, join)
+			}
+		}
+	}
+	return polys, disks
+}
+
+// joinPolygon returns the polygon that fills the outer side of the corner
+// at v, between the segments prev-v and v-next: a bevel, or a miter if
+// requested and within miterLimit. It returns false if the segments are
+// parallel, in which case no join is needed.
+func joinPolygon(prev, v, next point, hw float64, miter bool) (subpath, bool) {
+	unit := func(a, b point) point {
+		l := math.Hypot(b.x-a.x, b.y-a.y)
+		return point{(b.x - a.x) / l, (b.y - a.y) / l}
+	}
+	d1, d2 := unit(prev, v), unit(v, next)
+	cross := d1.x*d2.y - d1.y*d2.x
+	if math.Abs(cross) < 1e-9 {
+		return subpath{}, false
+	}
+
+	// Normals on the outer side of the turn.
+	sign := 1.0
+	if cross > 0 {
+		sign = -1
+	}
+	n1 := point{-d1.y * sign, d1.x * sign}
+	n2 := point{-d2.y * sign, d2.x * sign}
+	o1 := point{v.x + n1.x*hw, v.y + n1.y*hw}
+	o2 := point{v.x + n2.x*hw, v.y + n2.y*hw}
+
+	// The miter tip is along the bisector of the normals, at a distance of
+	// hw / cos(φ/2), where φ is the angle between them.
+	dot := d1.x*d2.x + d1.y*d2.y
+	if miter && 1+dot > 0 && 1/math.Sqrt((1+dot)/2) <= miterLimit {
+		k := hw / (1 + dot)
+		tip := point{v.x + (n1.x+n2.x)*k, v.y + (n1.y+n2.y)*k}
+		return subpath{closed: true, points: []point{v, o1, tip, o2}}, true
+	}
+	return subpath{closed: true, points: []point{v, o1, o2}}, true
+}
+
+// The scene: the shapes to draw, and the size of the canvas.
+type scene struct {
+	width, height float64
+	shapes        []shape
+}
+
+// An affine transformation matrix, like SVG's matrix(a b c d e f).
+type transform [6]float64
+
+var identity = transform{1, 0, 0, 1, 0, 0}
+
+// mul returns the transformation that applies o first, and then t.
+func (t transform) mul(o transform) transform {
+	return transform{
+		t[0]*o[0] + t[2]*o[1],
+		t[1]*o[0] + t[3]*o[1],
+		t[0]*o[2] + t[2]*o[3],
+		t[1]*o[2] + t[3]*o[3],
+		t[0]*o[4] + t[2]*o[5] + t[4],
+		t[1]*o[4] + t[3]*o[5] + t[5],
+	}
+}
+
+func (t transform) apply(p point) point {
+	return point{
+		t[0]*p.x + t[2]*p.y + t[4],
+		t[1]*p.x + t[3]*p.y + t[5],
+	}
+}
+
+// scale returns how much the transformation scales lengths (assuming it's
+// uniform, which is the case for our transforms).
+func (t transform) scale() float64 {
+	return math.Sqrt(math.Abs(t[0]*t[3] - t[1]*t[2]))
+}
+
+// parseTransform parses the value of a transform attribute.
+func parseTransform(s string) (transform, error) {
+	t := identity
+	for s = strings.TrimSpace(s); s != ""; s = strings.TrimSpace(s) {
+		name, rest, ok := strings.Cut(s, "(")
+		if !ok {
+			return t, fmt.Errorf("invalid transform %q", s)
+		}
+		argsS, rest, ok := strings.Cut(rest, ")")
+		if !ok {
+			return t, fmt.Errorf("invalid transform %q", s)
+		}
+		s = strings.TrimPrefix(strings.TrimSpace(rest), ",")
+
+		args, err := parseNumbers(argsS)
+		if err != nil {
+			return t, err
+		}
+		arg := func(i int, def float64) float64 {
+			if i < len(args) {
+				return args[i]
+			}
+			return def
+		}
+
+		var o transform
+		switch strings.TrimSpace(name) {
+		case "translate":
+			o = transform{1, 0, 0, 1, arg(0, 0), arg(1, 0)}
+		case "scale":
+			o = transform{arg(0, 1), 0, 0, arg(1, arg(0, 1)), 0, 0}
+		case "rotate":
+			rad := arg(0, 0) * math.Pi / 180
+			sin, cos := math.Sincos(rad)
+			cx, cy := arg(1, 0), arg(2, 0)
+			o = transform{1, 0, 0, 1, cx, cy}.
+				mul(transform{cos, sin, -sin, cos, 0, 0}).
+				mul(transform{1, 0, 0, 1, -cx, -cy})
+		case "matrix":
+			if len(args) != 6 {
+				return t, fmt.Errorf("invalid matrix %q", argsS)
+			}
+			copy(o[:], args)
+		default:
+			return t, fmt.Errorf("unsupported transform %q", name)
+		}
+		t = t.mul(o)
+	}
+	return t, nil
+}
+
+// parseNumbers parses a list of numbers separated by spaces and/or commas.
+func parseNumbers(s string) ([]float64, error) {
+	ns := []float64{}
+	for _, f := range strings.FieldsFunc(s, isNumberSep) {
+		n, err := strconv.ParseFloat(f, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", f)
+		}
+		ns = append(ns, n)
+	}
+	return ns, nil
+}
+
+func isNumberSep(r rune) bool {
+	return r == ',' || r == ' ' || r == '\n' || r == '\t' || r == '\r'
+}
+
+// An element of the SVG document.
+type svgNode struct {
+	name     string
+	attrs    map[string]string
+	children []*svgNode
+}
+
+// parseSVGTree parses the SVG document into a tree of nodes.
+// Comments, text and the <title> elements are ignored.
+func parseSVGTree(r io.Reader) (*svgNode, error) {
+	dec := xml.NewDecoder(r)
+	root := &svgNode{}
+	stack := []*svgNode{root}
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			n := &svgNode{name: t.Name.Local, attrs: map[string]string{}}
+			for _, a := range t.Attr {
+				n.attrs[a.Name.Local] = a.Value
+			}
+			parent := stack[len(stack)-1]
+			parent.children = append(parent.children, n)
+			stack = append(stack, n)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		}
+	}
+	if len(root.children) != 1 || root.children[0].name != "svg" {
+		return nil, fmt.Errorf("not an SVG document")
+	}
+	return root.children[0], nil
+}
+
+// The inherited state while walking the SVG tree.
+type paintState struct {
+	t           transform
+	fill        string
+	stroke      string
+	strokeWidth float64
+	linecap     string
+	linejoin    string
+	color       color.NRGBA
+	glyph       string
+	class       string
+	use         int
+}
+
+// parseScene parses the SVG document we generated into a scene.
+func parseScene(svg string) (*scene, error) {
+	root, err := parseSVGTree(strings.NewReader(svg))
+	if err != nil {
+		return nil, err
+	}
+
+	sc := &scene{}
+	vb, err := parseNumbers(root.attrs["viewBox"])
+	if err != nil || len(vb) != 4 {
+		return nil, fmt.Errorf("invalid viewBox %q", root.attrs["viewBox"])
+	}
+	sc.width, sc.height = vb[2], vb[3]
+
+	// Definitions, by id.
+	defs := map[string]*svgNode{}
+	var collect func(n *svgNode)
+	collect = func(n *svgNode) {
+		if id, ok := n.attrs["id"]; ok {
+			defs[id] = n
+		}
+		for _, c := range n.children {
+			collect(c)
+		}
+	}
+	collect(root)
+
+	p := &sceneParser{sc: sc, defs: defs}
+	st := paintState{
+		t:           transform{1, 0, 0, 1, -vb[0], -vb[1]},
+		fill:        "black",
+		stroke:      "none",
+		strokeWidth: 1,
+		linecap:     "butt",
+		linejoin:    "miter",
+		color:       color.NRGBA{0, 0, 0, 255},
+	}
+	if err := p.walk(root, st); err != nil {
+		return nil, err
+	}
+	return sc, nil
+}
+
+type sceneParser struct {
+	sc   *scene
+	defs map[string]*svgNode
+
+	// How many <use> elements we've seen so far.
+	uses int
+}
+
+// walk the node and its children, adding the shapes to the scene.
+func (p *sceneParser) walk(n *svgNode, st paintState) error {
+	switch n.name {
+	case "defs", "title", "desc", "metadata", "style", "filter",
+		"linearGradient", "radialGradient", "animate", "set":
+		return nil
+	}
+
+	if s, ok := n.attrs["transform"]; ok {
+		t, err := parseTransform(s)
+		if err != nil {
+			return err
+		}
+		st.t = st.t.mul(t)
+	}
+	if s, ok := n.attrs["color"]; ok {
+		if c, ok := parseColor(s, st.color); ok {
+			st.color = c
+		}
+	}
+	if s, ok := n.attrs["fill"]; ok {
+		st.fill = s
+	}
+	if s, ok := n.attrs["stroke"]; ok {
+		st.stroke = s
+	}
+	if s, ok := n.attrs["stroke-width"]; ok {
+		w, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("invalid stroke-width %q", s)
+		}
+		st.strokeWidth = w
+	}
+	if s, ok := n.attrs["stroke-linecap"]; ok {
+		st.linecap = s
+	}
+	if s, ok := n.attrs["stroke-linejoin"]; ok {
+		st.linejoin = s
+	}
+	if s, ok := n.attrs["class"]; ok {
+		st.class = s
+	}
+
+	if n.name == "use" {
+		href := n.attrs["href"]
+		if href == "" {
+			href = n.attrs["xlink:href"]
+		}
+		def, ok := p.defs[strings.TrimPrefix(href, "#")]
+		if !ok {
+			return fmt.Errorf("unknown reference %q", href)
+		}
+		if x, y := p.num(n, "x", 0), p.num(n, "y", 0); x != 0 || y != 0 {
+			st.t = st.t.mul(transform{1, 0, 0, 1, x, y})
+		}
+		if name, ok := strings.CutPrefix(def.attrs["id"], "glyph:"); ok {
+			st.glyph = name
+		}
+		p.uses++
+		st.use = p.uses
+		return p.walk(def, st)
+	}
+
+	s, err := p.shapeFor(n, st)
+	if err != nil {
+		return err
+	}
+	if s != nil {
+		s.fill = p.paint(st.fill, st.color)
+		s.stroke = p.paint(st.stroke, st.color)
+		if n.name == "line" {
+			// Lines are never filled.
+			s.fill = color.NRGBA{}
+		}
+		s.strokeWidth = st.strokeWidth * st.t.scale()
+		s.linecap, s.linejoin = st.linecap, st.linejoin
+		s.glyph, s.class, s.use = st.glyph, st.class, st.use
+		p.sc.shapes = append(p.sc.shapes, *s)
+	}
+
+	for _, c := range n.children {
+		if err := p.walk(c, st); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// paint returns the color for a fill or stroke value. References to
+// gradients are painted with the average color of their stops. Invisible or
+// unknown paints return a transparent color.
+func (p *sceneParser) paint(s string, current color.NRGBA) color.NRGBA {
+	id, ok := strings.CutPrefix(strings.TrimSpace(s), "url(#")
+	if !ok {
+		c, _ := parseColor(s, current)
+		return c
+	}
+
+	def := p.defs[strings.TrimSuffix(id, ")")]
+	if def == nil {
+		return color.NRGBA{}
+	}
+	var r, g, b, a, n int
+	for _, stop := range def.children {
+		if stop.name != "stop" {
+			continue
+		}
+		c, ok := parseColor(stop.attrs["stop-color"], current)
+		if !ok {
+			continue
+		}
+		r, g, b, a = r+int(c.R), g+int(c.G), b+int(c.B), a+int(c.A)
+		n++
+	}
+	if n == 0 {
+		return color.NRGBA{}
+	}
+	return color.NRGBA{
+		uint8(r / n), uint8(g / n), uint8(b / n), uint8(a / n)}
+}
+
+// num returns the numeric value of the attribute, or def if it's missing.
+// Percentages are relative to the scene size (width for the attributes
+// beginning with "x", height otherwise).
+func (p *sceneParser) num(n *svgNode, attr string, def float64) float64 {
+	s, ok := n.attrs[attr]
+	if !ok {
+		return def
+	}
+	if pct, ok := strings.CutSuffix(s, "%"); ok {
+		v, _ := strconv.ParseFloat(pct, 64)
+		size := p.sc.height
+		if strings.HasPrefix(attr, "x") || attr == "width" {
+			size = p.sc.width
+		}
+		return v / 100 * size
+	}
+	v, _ := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	return v
+}
+
+// shapeFor returns the shape for the element, or nil if it's not a shape.
+func (p *sceneParser) shapeFor(n *svgNode, st paintState) (*shape, error) {
+	var sps []subpath
+	switch n.name {
+	case "circle":
+		return &shape{
+			circle: true,
+			center: st.t.apply(point{p.num(n, "cx", 0), p.num(n, "cy", 0)}),
+			r:      p.num(n, "r", 0) * st.t.scale(),
+		}, nil
+	case "line":
+		sps = []subpath{{points: []point{
+			{p.num(n, "x1", 0), p.num(n, "y1", 0)},
+			{p.num(n, "x2", 0), p.num(n, "y2", 0)},
+		}}}
+	case "rect":
+		x, y := p.num(n, "x", 0), p.num(n, "y", 0)
+		w, h := p.num(n, "width", 0), p.num(n, "height", 0)
+		sps = []subpath{{points: []point{
+			{x, y}, {x + w, y}, {x + w, y + h}, {x, y + h},
+		}, closed: true}}
+	case "polyline", "polygon":
+		ns, err := parseNumbers(n.attrs["points"])
+		if err != nil || len(ns)%2 != 0 {
+			return nil, fmt.Errorf("invalid points %q", n.attrs["points"])
+		}
+		sp := subpath{closed: n.name == "polygon"}
+		for i := 0; i < len(ns); i += 2 {
+			sp.points = append(sp.points, point{ns[i], ns[i+1]})
+		}
+		sps = []subpath{sp}
+	case "path":
+		var err error
+		sps, err = parsePathData(n.attrs["d"])
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, nil
+	}
+
+	for i := range sps {
+		for j, pt := range sps[i].points {
+			sps[i].points[j] = st.t.apply(pt)
+		}
+	}
+	return &shape{subpaths: sps}, nil
+}
+
+// parsePathData parses the "d" attribute of a path. Only straight lines are
+// supported (M, L, H, V, Z, and their relative variants).
+func parsePathData(d string) ([]subpath, error) {
+	// Split the commands from the numbers.
+	tokens := []string{}
+	cur := strings.Builder{}
+	flush := func() {
+		if cur.Len() > 0 {
+			tokens = append(tokens, cur.String())
+			cur.Reset()
+		}
+	}
+	for _, r := range d {
+		switch {
+		case strings.ContainsRune("MmLlHhVvZz", r):
+			flush()
+			tokens = append(tokens, string(r))
+		case isNumberSep(r):
+			flush()
+		case r == '-' && cur.Len() > 0:
+			flush()
+			cur.WriteRune(r)
+		case strings.ContainsRune("0123456789.-+eE", r):
+			cur.WriteRune(r)
+		default:
+			return nil, fmt.Errorf("unsupported path data %q", string(r))
+		}
+	}
+	flush()
+
+	sps := []subpath{}
+	pos, start := point{}, point{}
+	cmd := ""
+	for i := 0; i < len(tokens); {
+		if _, err := strconv.ParseFloat(tokens[i], 64); err != nil {
+			cmd = tokens[i]
+			i++
+		}
+		if cmd == "" {
+			return nil, fmt.Errorf("path data must begin with a command")
+		}
+
+		next := func() (float64, error) {
+			if i >= len(tokens) {
+				return 0, fmt.Errorf("missing path data for %q", cmd)
+			}
+			v, err := strconv.ParseFloat(tokens[i], 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid path data %q", tokens[i])
+			}
+			i++
+			return v, nil
+		}
+		rel := strings.ToLower(cmd) == cmd
+		base := point{}
+		if rel {
+			base = pos
+		}
+
+		switch strings.ToUpper(cmd) {
+		case "Z":
+			if len(sps) > 0 {
+				sps[len(sps)-1].closed = true
+			}
+			pos = start
+			cmd = ""
+			continue
+		case "M", "L":
+			x, err := next()
+			if err != nil {
+				return nil, err
+			}
+			y, err := next()
+			if err != nil {
+				return nil, err
+			}
+			pos = point{base.x + x, base.y + y}
+		case "H":
+			x, err := next()
+			if err != nil {
+				return nil, err
+			}
+			pos = point{base.x + x, pos.y}
+		case "V":
+			y, err := next()
+			if err != nil {
+				return nil, err
+			}
+			pos = point{pos.x, base.y + y}
+		}
+
+		if strings.ToUpper(cmd) == "M" {
+			sps = append(sps, subpath{points: []point{pos}})
+			start = pos
+			// Subsequent pairs are implicit line-tos.
+			if rel {
+				cmd = "l"
+			} else {
+				cmd = "L"
+			}
+			continue
+		}
+		if len(sps) == 0 {
+			return nil, fmt.Errorf("path data must begin with a move")
+		}
+		sp := &sps[len(sps)-1]
+		sp.points = append(sp.points, pos)
+	}
+	return sps, nil
+}
+
+// parseColor parses a CSS color, as used in the SVG attributes. It returns
+// the color, and true if it's visible (so "none" and "transparent" return
+// false). "currentcolor" is replaced with current.
+func parseColor(s string, current color.NRGBA) (color.NRGBA, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch s {
+	case "", "none", "transparent":
+		return color.NRGBA{}, false
+	case "currentcolor":
+		return current, current.A > 0
+	}
+
+	if c, ok := namedColors[s]; ok {
+		return c, true
+	}
+
+	if hex, ok := strings.CutPrefix(s, "#"); ok {
+		if len(hex) == 3 || len(hex) == 4 {
+			// Short form, each digit is repeated.
+			long := ""
+			for _, r := range hex {
+				long += string(r) + string(r)
+			}
+			hex = long
+		}
+		if len(hex) == 6 {
+			hex += "ff"
+		}
+		v, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil || len(hex) != 8 {
+			return color.NRGBA{}, false
+		}
+		c := color.NRGBA{
+			uint8(v >> 24), uint8(v >> 16), uint8(v >> 8), uint8(v)}
+		return c, c.A > 0
+	}
+
+	return color.NRGBA{}, false
+}
+
+// Named CSS colors that are most likely to be used.
+var namedColors = map[string]color.NRGBA{
+	"black":     {0x00, 0x00, 0x00, 0xff},
+	"white":     {0xff, 0xff, 0xff, 0xff},
+	"gray":      {0x80, 0x80, 0x80, 0xff},
+	"grey":      {0x80, 0x80, 0x80, 0xff},
+	"silver":    {0xc0, 0xc0, 0xc0, 0xff},
+	"red":       {0xff, 0x00, 0x00, 0xff},
+	"green":     {0x00, 0x80, 0x00, 0xff},
+	"blue":      {0x00, 0x00, 0xff, 0xff},
+	"yellow":    {0xff, 0xff, 0x00, 0xff},
+	"orange":    {0xff, 0xa5, 0x00, 0xff},
+	"gold":      {0xff, 0xd7, 0x00, 0xff},
+	"purple":    {0x80, 0x00, 0x80, 0xff},
+	"magenta":   {0xff, 0x00, 0xff, 0xff},
+	"cyan":      {0x00, 0xff, 0xff, 0xff},
+	"navy":      {0x00, 0x00, 0x80, 0xff},
+	"teal":      {0x00, 0x80, 0x80, 0xff},
+	"maroon":    {0x80, 0x00, 0x00, 0xff},
+	"brown":     {0xa5, 0x2a, 0x2a, 0xff},
+	"pink":      {0xff, 0xc0, 0xcb, 0xff},
+	"ivory":     {0xff, 0xff, 0xf0, 0xff},
+	"goldenrod": {0xda, 0xa5, 0x20, 0xff},
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"io"
+	"maps"
+	"math"
+	"slices"
+	"time"
+)
+
+// # Animated images
+//
+// The "gif" command renders the animation of the words (see animateSVG) as
+// an animated GIF, or an animated PNG (APNG), for places that don't animate
+// SVG. Both are output backends (see outputBackends), so they can also be
+// written with -o from the other commands, and from the HTTP server.
+//
+// The frames are drawn with the rasterizer, following the same steps as
+// the SVG animation: at each frame, the glyphs drawn so far are complete,
+// and the current one is partially drawn (see shape.partial). Only the
+// area that changed is stored for each frame, and frames without changes
+// are merged with the previous one.
+//
+// GIF has a 256 color palette, and no partial transparency: the palette is
+// made from the colors of the final image, and with a transparent
+// background, pixels are either transparent or opaque. APNG has neither
+// limitation.
+
+var (
+	fpsFlag = flag.Int("fps", 15,
+		"in animated images, frames per second")
+	widthFlag = flag.Int("width", 480,
+		"in animated images and terminal graphics, width in pixels")
+	holdFlag = flag.Duration("hold", 2*time.Second,
+		"in animated images, how long to show the final frame")
+)
+
+// Limits, to keep the images of a reasonable size.
+const (
+	maxFPS       = 50
+	maxGIFWidth  = 2000
+	maxGIFFrames = 3000
+	maxHold      = time.Minute
+)
+
+// An animated image, as a sequence of frames.
+type animatedImage struct {
+	// The first frame covers the whole image, the others only the area
+	// that changed since the previous one.
+	frames []animFrame
+
+	// The final image, complete.
+	final *image.RGBA
+
+	// When the animation ends (including the hold of the final frame).
+	end time.Duration
+
+	// Repeat forever, or play only once.
+	loop bool
+}
+
+type animFrame struct {
+	img *image.RGBA
+
+	// When the frame is shown.
+	start time.Duration
+}
+
+// gifCmd implements the "gif" command: it renders the animation of the
+// words, and writes it to the output file given with -o (in the format of
+// its extension, so .png gives an APNG), or as GIF to w.
+func gifCmd(w io.Writer, words []string, opts Options) error {
+	opts.anim.enabled = true
+	doc, err := renderDocument(words, opts, false)
+	if err != nil {
+		return err
+	}
+	return writeDocument(w, doc, "gif")
+}
+
+// gifBackend writes the animation of the document as an animated GIF.
+type gifBackend struct{}
+
+func (gifBackend) contentType() string {
+	return "image/gif"
+}
+
+func (gifBackend) write(w io.Writer, doc *document) error {
+	anim, err := animateDocument(doc)
+	if err != nil {
+		return err
+	}
+	return writeGIF(w, anim)
+}
+
+// apngBackend writes the animation of the document as an animated PNG. The
+// png backend uses it too, for animated documents.
+type apngBackend struct{}
+
+func (apngBackend) contentType() string {
+	return "image/apng"
+}
+
+func (apngBackend) write(w io.Writer, doc *document) error {
+	anim, err := animateDocument(doc)
+	if err != nil {
+		return err
+	}
+	return writeAPNG(w, anim)
+}
+
+// animateDocument draws the frames of the animation of the words of the
+// document, with the frame rate, width and hold given by the flags.
+func animateDocument(doc *document) (*animatedImage, error) {
+	fps, width, hold := *fpsFlag, *widthFlag, *holdFlag
+	switch {
+	case fps < 1 || fps > maxFPS:
+		return nil, fmt.Errorf("fps must be between 1 and %d", maxFPS)
+	case width < 16 || width > maxGIFWidth:
+		return nil, fmt.Errorf("width must be between 16 and %d",
+			maxGIFWidth)
+	case hold < 0 || hold > maxHold:
+		return nil, fmt.Errorf("hold must be up to %v", maxHold)
+	}
+
+	sc, err := doc.wordsScene()
+	if err != nil {
+		return nil, err
+	}
+	bg, _ := parseColor(doc.opts.style.resolved().background,
+		color.NRGBA{})
+	return animateScene(sc, bg, doc.opts.anim, fps, width, hold)
+}
+
+// animateScene draws the frames of the animation of the scene, of the given
+// width in pixels.
+func animateScene(sc *scene, bg color.NRGBA, a animation, fps, width int,
+	hold time.Duration) (*animatedImage, error) {
+	steps := animationSteps(sc)
+	step := a.stepTime(max(len(steps), 1))
+	total := step * time.Duration(len(steps))
+	frameTime := time.Second / time.Duration(fps)
+	if n := int(total / frameTime); n > maxGIFFrames {
+		return nil, fmt.Errorf(
+			"too many frames (%d, maximum is %d), use a lower -fps or "+
+				"a shorter animation", n, maxGIFFrames)
+	}
+
+	scale := float64(width) / sc.width
+	bounds := image.Rect(0, 0, width, int(math.Ceil(sc.height*scale)))
+	base := image.NewRGBA(bounds)
+	draw.Draw(base, bounds, image.NewUniform(bg), image.Point{}, draw.Src)
+
+	anim := &animatedImage{
+		frames: []animFrame{{img: cloneRGBA(base, bounds)}},
+		loop:   a.loop,
+	}
+	prev := cloneRGBA(base, bounds)
+	done := 0
+	for k := 1; ; k++ {
+		t := min(time.Duration(k)*frameTime, total)
+
+		// Draw the steps that finished on the base image, and the one in
+		// progress on top of it.
+		for done < len(steps) && step*time.Duration(done+1) <= t {
+			for _, s := range steps[done] {
+				drawShape(base, s, scale)
+			}
+			done++
+		}
+		cur := base
+		if done < len(steps) && t > step*time.Duration(done) {
+			f := float64(t-step*time.Duration(done)) / float64(step)
+			cur = cloneRGBA(base, bounds)
+			for _, s := range steps[done] {
+				for _, p := range s.partial(f) {
+					drawShape(cur, p, scale)
+				}
+			}
+		}
+
+		if r := changedRect(prev, cur); !r.Empty() {
+			anim.frames = append(anim.frames,
+				animFrame{img: cloneRGBA(cur, r), start: t})
+			prev = cloneRGBA(cur, bounds)
+		}
+		if t >= total {
+			break
+		}
+	}
+
+	anim.final = base
+	anim.end = total + max(hold, frameTime)
+	return anim, nil
+}
+
+// cloneRGBA returns a copy of the area r of the image.
+func cloneRGBA(img *image.RGBA, r image.Rectangle) *image.RGBA {
+	c := image.NewRGBA(r)
+	draw.Draw(c, r, img, r.Min, draw.Src)
+	return c
+}
+
+// changedRect returns the smallest rectangle that contains all the pixels
+// that are different between a and b (which have the same bounds).
+func changedRect(a, b *image.RGBA) image.Rectangle {
+	r := image.Rectangle{}
+	bounds := a.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		ra := a.Pix[a.PixOffset(bounds.Min.X, y):a.PixOffset(bounds.Max.X, y)]
+		rb := b.Pix[b.PixOffset(bounds.Min.X, y):b.PixOffset(bounds.Max.X, y)]
+		if bytes.Equal(ra, rb) {
+			continue
+		}
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			i := (x - bounds.Min.X) * 4
+			if !bytes.Equal(ra[i:i+4], rb[i:i+4]) {
+				r = r.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	return r
+}
+
+// writeGIF writes the animation as an animated GIF.
+func writeGIF(w io.Writer, a *animatedImage) error {
+	pal, transparent := gifPalette(a.final)
+	conv := paletteConverter{pal: pal, transparent: transparent,
+		cache: map[color.RGBA]uint8{}}
+
+	bounds := a.final.Bounds()
+	g := &gif.GIF{
+		Config: image.Config{
+			ColorModel: pal, Width: bounds.Dx(), Height: bounds.Dy()},
+		LoopCount: -1,
+	}
+	if a.loop {
+		g.LoopCount = 0
+	}
+
+	// GIF delays are in hundredths of a second; we round the start of each
+	// frame so the errors don't accumulate.
+	cs := func(d time.Duration) int {
+		return int(d.Round(10*time.Millisecond) / (10 * time.Millisecond))
+	}
+	for i, f := range a.frames {
+		next := a.end
+		if i+1 < len(a.frames) {
+			next = a.frames[i+1].start
+		}
+		g.Image = append(g.Image, conv.convert(f.img))
+		g.Delay = append(g.Delay, cs(next)-cs(f.start))
+		g.Disposal = append(g.Disposal, gif.DisposalNone)
+	}
+	// Transparent pixels keep the previous frame, so clear the image before
+	// starting again.
+	if transparent && a.loop {
+		g.Disposal[len(g.Disposal)-1] = gif.DisposalBackground
+	}
+	return gif.EncodeAll(w, g)
+}
+
+// gifPalette returns the palette for the image: its most common colors.
+// If the image has transparent pixels, the first color of the palette is
+// transparent, and it returns true.
+func gifPalette(img *image.RGBA) (color.Palette, bool) {
+	counts := map[color.RGBA]int{}
+	transparent := false
+	for i := 0; i < len(img.Pix); i += 4 {
+		c, ok := opaqueColor(img.Pix[i : i+4])
+		if !ok {
+			transparent = true
+			continue
+		}
+		counts[c]++
+	}
+
+	colors := slices.SortedFunc(maps.Keys(counts), func(a, b color.RGBA) int {
+		if counts[a] != counts[b] {
+			return counts[b] - counts[a]
+		}
+		return int(rgbaKey(a)) - int(rgbaKey(b))
+	})
+
+	pal := color.Palette{}
+	if transparent {
+		pal = append(pal, color.RGBA{})
+	}
+	for _, c := range colors {
+		if len(pal) == 256 {
+			break
+		}
+		pal = append(pal, c)
+	}
+	if len(pal) == 0 {
+		pal = append(pal, color.RGBA{})
+	}
+	return pal, transparent
+}
+
+// opaqueColor returns the color of the (premultiplied) RGBA pixel, made
+// opaque, or false if the pixel is mostly transparent.
+func opaqueColor(p []uint8) (color.RGBA, bool) {
+	if p[3] < 128 {
+		return color.RGBA{}, false
+	}
+	c := unpremultiply(p)
+	return color.RGBA{c[0], c[1], c[2], 255}, true
+}
+
+func rgbaKey(c color.RGBA) uint32 {
+	return uint32(c.R)<<16 | uint32(c.G)<<8 | uint32(c.B)
+}
+
+// paletteConverter converts images to a palette, caching the closest color
+// for each one it sees.
+type paletteConverter struct {
+	pal         color.Palette
+	transparent bool
+	cache       map[color.RGBA]uint8
+}
+
+func (pc paletteConverter) convert(img *image.RGBA) *image.Paletted {
+	r := img.Bounds()
+	out := image.NewPaletted(r, pc.pal)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			i := img.PixOffset(x, y)
+			c, ok := opaqueColor(img.Pix[i : i+4])
+			if !ok && pc.transparent {
+				out.SetColorIndex(x, y, 0)
+				continue
+			}
+			idx, cached := pc.cache[c]
+			if !cached {
+				idx = uint8(pc.pal.Index(c))
+				pc.cache[c] = idx
+			}
+			out.SetColorIndex(x, y, idx)
+		}
+	}
+	return out
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestShapePartial(t *testing.T) {
+	orange := color.NRGBA{255, 165, 0, 255}
+	line := shape{
+		subpaths: []subpath{
+			{points: []point{{0, 0}, {4, 0}}},
+			{points: []point{{0, 1}, {0, 7}}},
+		},
+		stroke: orange, strokeWidth: 1,
+	}
+	cases := []struct {
+		f        float64
+		expected [][]point
+	}{
+		{0, nil},
+		{0.2, [][]point{{{0, 0}, {2, 0}}}},
+		{0.7, [][]point{{{0, 0}, {4, 0}}, {{0, 1}, {0, 4}}}},
+		{1, [][]point{{{0, 0}, {4, 0}}, {{0, 1}, {0, 7}}}},
+	}
+	for _, c := range cases {
+		var got [][]point
+		for _, s := range line.partial(c.f) {
+			for _, sp := range s.subpaths {
+				got = append(got, sp.points)
+			}
+		}
+		diff := cmp.Diff(c.expected, got, cmp.AllowUnexported(point{}))
+		if diff != "" {
+			t.Errorf("partial(%v) diff (-expected +got):\n%s", c.f, diff)
+		}
+	}
+
+	// Fills fade in.
+	square := shape{
+		subpaths: []subpath{
+			{points: []point{{0, 0}, {1, 0}, {1, 1}, {0, 1}}, closed: true}},
+		fill: orange,
+	}
+	parts := square.partial(0.5)
+	if len(parts) != 1 || parts[0].fill.A != 128 {
+		t.Errorf("partial fill: %+v", parts)
+	}
+
+	// Circles are drawn as polygons, starting on the right, clockwise.
+	circle := shape{circle: true, center: point{5, 5}, r: 2,
+		stroke: orange, strokeWidth: 1}
+	parts = circle.partial(0.5)
+	if len(parts) != 1 || len(parts[0].subpaths) != 1 {
+		t.Fatalf("partial circle: %+v", parts)
+	}
+	pts := parts[0].subpaths[0].points
+	first, last := pts[0], pts[len(pts)-1]
+	if math.Hypot(first.x-7, first.y-5) > 1e-9 ||
+		math.Hypot(last.x-3, last.y-5) > 1e-9 || pts[len(pts)/2].y < 5 {
+		t.Errorf("partial circle goes from %v to %v", first, last)
+	}
+}
+
+func TestWordLinePartial(t *testing.T) {
+	opts := Options{syllables: "manual", angle: defaultAngle,
+		sentences: "none"}
+	wsvg, w, h, err := wordsToSVG([]string{"hi"}, opts)
+	if err != nil {
+		t.Fatalf("wordsToSVG error: %v", err)
+	}
+	sc, err := wordsScene(wsvg, w, h)
+	if err != nil {
+		t.Fatalf("wordsScene error: %v", err)
+	}
+
+	// The frames draw the word line from its start, at the right, like
+	// the SVG animation (see animationSteps).
+	for _, step := range animationSteps(sc) {
+		for _, s := range step {
+			if s.class != "word-line" || s.circle {
+				continue
+			}
+			lo, hi := s.bounds()
+			mid := (lo.x + hi.x) / 2
+			for _, p := range s.partial(0.4) {
+				for _, sp := range p.subpaths {
+					for _, pt := range sp.points {
+						if pt.x < mid {
+							t.Errorf("40%% of the word line reaches x=%v, "+
+								"left of the middle (%v)", pt.x, mid)
+						}
+					}
+				}
+			}
+			return
+		}
+	}
+	t.Errorf("word line not found")
+}
+
+func testAnimatedImage(t *testing.T, loop bool) *animatedImage {
+	t.Helper()
+	opts := Options{syllables: "manual", angle: defaultAngle,
+		sentences: "none"}
+	wsvg, w, h, err := wordsToSVG([]string{"hi"}, opts)
+	if err != nil {
+		t.Fatalf("wordsToSVG error: %v", err)
+	}
+	sc, err := wordsScene(wsvg, w, h)
+	if err != nil {
+		t.Fatalf("wordsScene error: %v", err)
+	}
+	a := animation{glyphTime: 200 * time.Millisecond, loop: loop}
+	anim, err := animateScene(sc, color.NRGBA{}, a, 10, 100, time.Second)
+	if err != nil {
+		t.Fatalf("animateScene error: %v", err)
+	}
+	return anim
+}
+
+func TestWriteGIF(t *testing.T) {
+	anim := testAnimatedImage(t, true)
+	buf := &bytes.Buffer{}
+	if err := writeGIF(buf, anim); err != nil {
+		t.Fatalf("writeGIF error: %v", err)
+	}
+	g, err := gif.DecodeAll(buf)
+	if err != nil {
+		t.Fatalf("error decoding the GIF: %v", err)
+	}
+
+	if g.LoopCount != 0 {
+		t.Errorf("loop count %d, expected 0 (forever)", g.LoopCount)
+	}
+	if len(g.Image) != len(anim.frames) || len(g.Image) < 2 {
+		t.Errorf("got %d frames, expected %d", len(g.Image),
+			len(anim.frames))
+	}
+	// 6 steps of 200ms, and the 1s hold.
+	total := 0
+	for _, d := range g.Delay {
+		total += d
+	}
+	if total != 220 {
+		t.Errorf("total delay %d, expected 220", total)
+	}
+	if g.Image[0].Bounds() != anim.final.Bounds() {
+		t.Errorf("first frame %v, expected the whole image %v",
+			g.Image[0].Bounds(), anim.final.Bounds())
+	}
+}
+
+func TestWriteAPNG(t *testing.T) {
+	anim := testAnimatedImage(t, false)
+	buf := &bytes.Buffer{}
+	if err := writeAPNG(buf, anim); err != nil {
+		t.Fatalf("writeAPNG error: %v", err)
+	}
+	data := buf.Bytes()
+
+	// Viewers without APNG support see the final image.
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("error decoding the PNG: %v", err)
+	}
+	if img.Bounds() != anim.final.Bounds() {
+		t.Errorf("PNG bounds %v, expected %v", img.Bounds(),
+			anim.final.Bounds())
+	}
+
+	// Check the chunks, and decode each frame as a PNG of its own.
+	var ihdr []byte
+	frames, delay, seq := 0, time.Duration(0), uint32(0)
+	var frame image.Rectangle
+	data = data[len(pngSignature):]
+	for len(data) > 0 {
+		n := binary.BigEndian.Uint32(data)
+		typ, body := string(data[4:8]), data[8:8+n]
+		crc := binary.BigEndian.Uint32(data[8+n:])
+		if crc != crc32.ChecksumIEEE(data[4:8+n]) {
+			t.Errorf("chunk %s: bad CRC", typ)
+		}
+		data = data[12+n:]
+
+		switch typ {
+		case "IHDR":
+			ihdr = body
+		case "acTL":
+			if n := binary.BigEndian.Uint32(body); n !=
+				uint32(len(anim.frames)) {
+				t.Errorf("acTL has %d frames, expected %d", n,
+					len(anim.frames))
+			}
+			if plays := binary.BigEndian.Uint32(body[4:]); plays != 1 {
+				t.Errorf("acTL plays %d, expected 1", plays)
+			}
+		case "fcTL", "fdAT":
+			if s := binary.BigEndian.Uint32(body); s != seq {
+				t.Errorf("%s sequence %d, expected %d", typ, s, seq)
+			}
+			seq++
+			if typ == "fcTL" {
+				frames++
+				w, h := binary.BigEndian.Uint32(body[4:]),
+					binary.BigEndian.Uint32(body[8:])
+				x, y := binary.BigEndian.Uint32(body[12:]),
+					binary.BigEndian.Uint32(body[16:])
+				frame = image.Rect(int(x), int(y), int(x+w), int(y+h))
+				num, den := binary.BigEndian.Uint16(body[20:]),
+					binary.BigEndian.Uint16(body[22:])
+				delay += time.Duration(num) * time.Second / time.Duration(den)
+				continue
+			}
+			single := &bytes.Buffer{}
+			single.Write(pngSignature)
+			h := append([]byte{}, ihdr...)
+			binary.BigEndian.PutUint32(h, uint32(frame.Dx()))
+			binary.BigEndian.PutUint32(h[4:], uint32(frame.Dy()))
+			writeChunk(single, "IHDR", h)
+			writeChunk(single, "IDAT", body[4:])
+			writeChunk(single, "IEND", nil)
+			if _, err := png.Decode(single); err != nil {
+				t.Errorf("frame %d: error decoding: %v", frames, err)
+			}
+		}
+	}
+
+	if frames != len(anim.frames) {
+		t.Errorf("got %d frames, expected %d", frames, len(anim.frames))
+	}
+	if delay != 2200*time.Millisecond {
+		t.Errorf("total delay %v, expected 2.2s", delay)
+	}
+}
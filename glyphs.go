@@ -40,10 +40,29 @@ type Glyph struct {
 	svg       SVG    // The SVG referencing this glyph.
 	height    int
 	connector bool
+
+	// Stress of the syllable the glyph comes from, if known.
+	stress Stress
 }
 
 type Syllable []Glyph
 
+// stress returns the stress of the syllable, which is the one of its
+// glyphs. If they have different ones, the syllable covers several of the
+// pronunciation (e.g. a whole word, with -syllables manual), so it's
+// unstressed as a whole, and only its glyphs are.
+func (s Syllable) stress() Stress {
+	if len(s) == 0 {
+		return unstressed
+	}
+	for _, g := range s[1:] {
+		if g.stress != s[0].stress {
+			return unstressed
+		}
+	}
+	return s[0].stress
+}
+
 func (s Syllable) String() string {
 	names := make([]string, 0, len(s))
 	for _, g := range s {
@@ -62,6 +81,12 @@ func (word Word) String() string {
 	return strings.Join(syS, "/")
 }
 
+// isSentenceEnd returns true if the word marks the end of a sentence, see
+// wordsToGlyphs.
+func (word Word) isSentenceEnd() bool {
+	return len(word) == 0
+}
+
 func (g Glyph) String() string {
 	return "[" + g.name + "]"
 }
@@ -241,7 +266,7 @@ func dumpGlyphs(w io.Writer) {
 		s += SVGfn(`</text>`)
 
 		// The glyph.
-		s += color("orange", g.svg)
+		s += colored("orange", g.svg)
 		s = move(x, y, s)
 		buf.WriteString(string(s) + "\n")
 
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"cmp"
 	"embed"
 	"fmt"
 	"html/template"
@@ -11,6 +12,7 @@ import (
 	"os/signal"
 	"strings"
 	"syscall"
+	"time"
 )
 
 //go:embed http/*
@@ -23,6 +25,10 @@ func serveHTTP(addr string) {
 
 	go signalHandler()
 
+	if userDict.Load() != nil {
+		go userDictReloader(2 * time.Second)
+	}
+
 	tmplFuncs := template.FuncMap{
 		"join": func(sep string, a []string) string {
 			return strings.Join(a, sep)
@@ -34,10 +40,16 @@ func serveHTTP(addr string) {
 			ParseFS(httpFS, "http/index.tmpl.html"))
 
 	http.HandleFunc("GET /{$}", handleRoot)
-	http.HandleFunc("GET /svg", handleSVG)
-	http.HandleFunc("PUT /svg", handleSVG)
+	for _, name := range outputFormats() {
+		http.HandleFunc("GET /"+name, handleOutput(name))
+	}
+	http.HandleFunc("PUT /svg", handleOutput("svg"))
+	http.HandleFunc("GET /json", handleJSON)
 
 	log.Printf("firstones %s", Version())
+	for i, lang := range dictLangs {
+		log.Printf("Dictionary %s: %d words", lang, len(IPADicts[i]))
+	}
 	log.Printf("Starting HTTP server on %q", addr)
 	log.Fatal(http.ListenAndServe(addr, nil))
 }
@@ -55,35 +67,54 @@ func wordsFromRequest(r *http.Request) []string {
 	wordsF := r.Form["words"]
 
 	// For extra convenience, the words can be provided as a single
-	// space-separated string.
-	words := []string{}
-	for _, wordF := range wordsF {
-		words = append(words, strings.Fields(wordF)...)
-	}
+	// string, with whole sentences (see tokenize).
+	words := tokenize(strings.Join(wordsF, " "))
 
 	// Max number of words supported is 10.
-	if len(words) > 10 {
-		words = words[:10]
-	}
-
-	return words
+	return limitWords(words, 10)
 }
 
 func handleRoot(w http.ResponseWriter, r *http.Request) {
 	words := wordsFromRequest(r)
 
 	svg := ""
-	var svgErr error
-	if len(words) > 0 {
-		svg, svgErr = genSVG(words, r.FormValue("grid") == "1")
+	notes := []string{}
+	opts, svgErr := optionsFromRequest(r)
+	opts.notef = func(format string, args ...interface{}) {
+		notes = append(notes, fmt.Sprintf(format, args...))
+	}
+	if len(words) > 0 && svgErr == nil {
+		svg, svgErr = genSVG(words, opts, r.FormValue("grid") == "1")
 	}
 
 	data := map[string]interface{}{
-		"Words": words,
+		"Words":     words,
+		"Text":      strings.Join(r.Form["words"], " "),
+		"Sentences": opts.sentences,
+		"Parts":     opts.parts,
+		"Dialect":   r.FormValue("dialect"),
+		"Syllables": opts.syllables,
+		"Stress":    opts.stress,
+		"Theme":     cmp.Or(r.FormValue("theme"), "default"),
+		"Themes":    themeNames(),
+		"Effect":    r.FormValue("effect"),
+		"Effects":   effectNames(),
+		"Animate":   opts.anim.enabled,
+
+		// Query for the links to the image, so it has the same options.
+		// It's encoded, so it's safe to use in the URL.
+		"Query": template.URL(r.Form.Encode()),
 
 		// The generated HTML should be already safe for embedding.
 		"SVG":   template.HTML(svg),
 		"Error": svgErr,
+		"Notes": notes,
+
+		"Languages": strings.Join(supportedLangNames(), ", "),
+		"Formats":   outputFormats(),
+	}
+	if det := detectLanguage(words, opts.langs); det.words > 0 {
+		data["Detected"] = det.String()
 	}
 	err := rootTemplate.ExecuteTemplate(w, "index.tmpl.html", data)
 	if err != nil {
@@ -93,41 +124,72 @@ func handleRoot(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func genSVG(words []string, grid bool) (string, error) {
-	wsvg, width, height, err := wordsToSVG(words)
+func genSVG(words []string, opts Options, grid bool) (string, error) {
+	doc, err := renderDocument(words, opts, grid)
 	if err != nil {
 		return "", err
 	}
+	return doc.svg, nil
+}
 
-	buf := &bytes.Buffer{}
-	buf.WriteString(string(svgHeader(width, height)))
-
-	writeDefs(buf)
-
-	if grid {
-		buf.WriteString(string(svgGrid(width, height)))
+// handleOutput returns a handler that renders the words using the given
+// output backend (see outputBackends).
+func handleOutput(name string) http.HandlerFunc {
+	backend := outputBackends[name]
+	return func(w http.ResponseWriter, r *http.Request) {
+		words := wordsFromRequest(r)
+		if len(words) == 0 {
+			http.Error(w, "No words provided", http.StatusBadRequest)
+			return
+		}
+
+		opts, err := optionsFromRequest(r)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error in options: %v", err),
+				http.StatusBadRequest)
+			return
+		}
+
+		doc, err := renderDocument(words, opts, r.FormValue("grid") == "1")
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error generating SVG: %v", err),
+				http.StatusBadRequest)
+			return
+		}
+
+		buf := &bytes.Buffer{}
+		if err := backend.write(buf, doc); err != nil {
+			http.Error(w, fmt.Sprintf("Error generating %s: %v", name, err),
+				http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", backend.contentType())
+		w.Write(buf.Bytes())
 	}
-
-	buf.WriteString(string(wsvg))
-	buf.WriteString("</svg>\n")
-
-	return buf.String(), nil
 }
 
-func handleSVG(w http.ResponseWriter, r *http.Request) {
+func handleJSON(w http.ResponseWriter, r *http.Request) {
 	words := wordsFromRequest(r)
 	if len(words) == 0 {
 		http.Error(w, "No words provided", http.StatusBadRequest)
 		return
 	}
 
-	svg, err := genSVG(words, r.FormValue("grid") == "1")
+	opts, err := optionsFromRequest(r)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error in options: %v", err),
+			http.StatusBadRequest)
+		return
+	}
+
+	j, err := wordsToJSON(words, opts)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error generating SVG: %v", err),
+		http.Error(w, fmt.Sprintf("Error generating JSON: %v", err),
 			http.StatusBadRequest)
 		return
 	}
 
-	w.Header().Set("Content-Type", "image/svg+xml")
-	w.Write([]byte(svg))
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(j)
 }
@@ -4,6 +4,8 @@ import (
 	"bufio"
 	"embed"
 	"fmt"
+	"io"
+	"slices"
 	"strings"
 
 	"golang.org/x/text/language"
@@ -19,7 +21,7 @@ var ipaFS embed.FS
 // Map of word -> pronunciation (as IPA symbols).
 type IPADict map[string]string
 
-// The known dictionaries.
+// The known dictionaries, see registerDict.
 var IPADicts = map[int]IPADict{}
 
 // Language matcher, to find the correct IPA dictionary.
@@ -64,56 +66,58 @@ func init() {
 	if err != nil {
 		panic(err)
 	}
-	langs := []language.Tag{}
-	for i, de := range des {
+	for _, de := range des {
 		langS := strings.TrimSuffix(de.Name(), ".txt")
 		lang := language.MustParse(langS)
 		dict := IPADict{}
-		langs = append(langs, lang)
 
-		// The order in which we add it to langs identifies this dictionary.
-		// The matcher will return this index when doing a match.
-		IPADicts[i] = dict
-
-		// Scan line by line. Format is:
-		//   word<TAB>/pronunciation1/, /pronunciation2/, ...
 		f, err := ipaFS.Open("ipa/" + de.Name())
 		if err != nil {
 			panic(err)
 		}
-		scanner := bufio.NewScanner(f)
-		for scanner.Scan() {
-			line := scanner.Text()
-			word, prons, ok := strings.Cut(line, "\t")
-			if !ok || word == "" || prons == "" {
-				continue
-			}
+		if err := parseIPADict(f, dict); err != nil {
+			panicf("%s: %v", de.Name(), err)
+		}
+		f.Close()
 
-			// We keep the first pronunciation, as for our use case we only
-			// need one.
-			slspl := strings.Split(prons, "/")
-			if len(slspl) < 2 {
-				continue
-			}
-			// TODO: clean up the symbols, remove accents, etc.
-			dict[word] = strings.TrimSpace(slspl[1])
+		registerDict(lang, dict)
+	}
+
+	// The languages without a dictionary, for which we use G2P rules.
+	registerG2PLangs()
+}
+
+// parseIPADict parses a dictionary in the open-dict-data tab-delimited
+// format, adding the words to the given dict.
+func parseIPADict(r io.Reader, dict IPADict) error {
+	// Scan line by line. Format is:
+	//   word<TAB>/pronunciation1/, /pronunciation2/, ...
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		word, prons, ok := strings.Cut(line, "\t")
+		if !ok || word == "" || prons == "" {
+			continue
 		}
 
-		// Add the namesIPA to all dictionaries.
-		// This also overrides the word if it exists. That's okay.
-		for name, ipa := range namesIPA {
-			dict[name] = ipa
+		// We keep the first pronunciation, as for our use case we only
+		// need one.
+		slspl := strings.Split(prons, "/")
+		if len(slspl) < 2 {
+			continue
 		}
+		// The symbols are normalized later, when converting to glyphs
+		// (see normalizeIPA).
+		dict[word] = strings.TrimSpace(slspl[1])
 	}
-
-	langMatcher = language.NewMatcher(langs, language.PreferSameScript(true))
+	return scanner.Err()
 }
 
 // IPA symbol to first ones glyph mapping.
 // This is manually curated, and we do our best to match symbols to glyphs.
 // There are gaps which are filled in by approximation.
-// See ipa/symbols.py for the helper used to extract the list from the
-// dictionaries.
+// See the "ipa-coverage" command for the helper used to extract the list
+// from the dictionaries, and find the missing symbols.
 //
 // To approximate and confirm the mappings, we use the following sources as
 // starting points:
@@ -137,6 +141,7 @@ var ipaToGlyphs2 = map[string]string{
 	"tʃ": "CH",    // Wikipedia (e.g. "CHurCH").
 	"dʒ": "J",     // Wikipedia (e.g.: "Jump").
 	"aɪ": "I",     // "I", "bY" en_US lookup
+	"əʊ": "gO",    // "go" in en-GB and en-AU, see dialects.
 }
 
 var ipaToGlyphs1 = map[rune]string{
@@ -209,6 +214,25 @@ var ipaToGlyphs1 = map[rune]string{
 	// "bOY" is in ipaToGlyphs2.
 	'j': "Yes", // "yes" en_US lookup
 
+	// Vowels from other languages, which don't have a glyph. We approximate
+	// them with the closest one.
+	'y': "tOO",  // Closest match (rounded "i"). fr: "tU", de: "Über".
+	'ʏ': "gOOd", // Closest match. de: "hÜbsch".
+	'ø': "fUn",  // Closest match. fr: "pEU", de: "schÖn".
+	'œ': "fUn",  // Closest match. fr: "sŒUr".
+	'ɐ': "fUn",  // Closest match. de: "bessER", pt: "cAma".
+	'ɥ': "W",    // Closest match. fr: "hUit".
+
+	// Vowels from other English dialects, same as above (see dialects).
+	'ɒ': "All", // Closest match. en-GB: "lOt".
+	'ɜ': "fUn", // Closest match. en-GB: "bIRd" (without the "r").
+
+	// Consonants from other languages, same as above.
+	'ʁ': "R",  // Closest match, the French/German R. fr: "Rouge".
+	'ʀ': "R",  // Closest match, trilled uvular R.
+	'χ': "K",  // Closest match, like 'x'.
+	'ç': "SH", // Closest match. de: "iCH".
+
 	//
 	// Ignored
 	//
@@ -216,66 +240,207 @@ var ipaToGlyphs1 = map[rune]string{
 	'ˌ': "", // Secondary stress mark.
 }
 
+// lookupWord returns the IPA representation of the word in the given
+// language, for each of its parts (see lookupParts). The user dictionary
+// takes precedence over the built-in ones; if it gives the glyphs directly,
+// they are returned as phonemes instead.
+func lookupWord(word, lang string) ([]ipaPart, string, error) {
+	if entry, ok := userDict.Load().lookup(word, lang); ok {
+		if entry.phonemes != "" {
+			return nil, entry.phonemes, nil
+		}
+		return []ipaPart{{word: word, ipa: entry.ipa}}, "", nil
+	}
+
+	parts, err := lookupParts(word, lang)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// The dictionary is American English, so the other dialects are derived
+	// from it.
+	d := langDialect(lang)
+	for i, part := range parts {
+		parts[i].ipa = d.transform(part.word, part.ipa)
+	}
+	return parts, "", nil
+}
+
 // langWord ToGlyphs converts a word in the given language, to a glyph
 // Word.
-func langWordToGlyphs(word, lang string) (Word, error) {
+func langWordToGlyphs(word, lang string, opts Options) (Word, error) {
+	// A '/' in the input indicates a new syllable. We record where they are
+	// in the input, then try to match them on the output.
+	syllablesIdxs := findSlashes(word)
+	word = strings.ReplaceAll(word, "/", "")
+
+	parts, phonemes, err := lookupWord(word, lang)
+	if err != nil {
+		return nil, err
+	}
+	if phonemes != "" {
+		// The user gave us the glyphs directly.
+		return phonemesToGlyphs(phonemes)
+	}
+	if len(parts) > 1 {
+		words := []string{}
+		for _, part := range parts {
+			words = append(words, part.word)
+		}
+		opts.note("%s: not in the dictionary, using %s",
+			word, strings.Join(words, " + "))
+	}
+
+	// Convert each part, keeping track of where the syllables and the parts
+	// begin.
+	glyphs := []Glyph{}
+	bounds := []int{}
+	partBounds := []int{}
+	for i, part := range parts {
+		if len(part.skipped) > 0 {
+			opts.note("%s: no pronunciation rules for %s, skipped",
+				part.word, quoteSymbols(part.skipped))
+		}
+
+		// Remove the diacritics and other symbols we don't use.
+		ipa, removed := normalizeIPA(part.ipa)
+		if len(removed) > 0 {
+			opts.note("%s: removed %s from the IPA, using /%s/",
+				part.word, quoteSymbols(removed), ipa)
+		}
+
+		pglyphs, pbounds, err := syllablesToGlyphs(ipa)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			partBounds = append(partBounds, len(glyphs))
+			bounds = append(bounds, len(glyphs))
+		}
+		for _, b := range pbounds {
+			bounds = append(bounds, len(glyphs)+b)
+		}
+		glyphs = append(glyphs, pglyphs...)
+	}
+
+	wordG := glyphsToSyllables(
+		glyphs, bounds, syllablesIdxs, len(word), opts)
+	if opts.parts && len(partBounds) > 0 {
+		// Also split where each part begins.
+		splits := append(wordSplits(wordG), partBounds...)
+		slices.Sort(splits)
+		wordG = splitGlyphs(glyphs, slices.Compact(splits))
+	}
+	return wordG, nil
+}
+
+// glyphsToSyllables splits the glyphs of a word into syllables, according to
+// opts.syllables. The bounds are the glyph indices at which each syllable
+// begins (see syllablesToGlyphs), and slashes the indices of the "/" in the
+// original word.
+func glyphsToSyllables(glyphs []Glyph, bounds, slashes []int, wlen int, opts Options) Word {
+	switch opts.syllables {
+	case "auto":
+		return splitGlyphs(glyphs, bounds)
+	case "anchor":
+		if len(bounds) > 0 {
+			splits := anchorSlashes(len(glyphs), slashes, wlen, bounds)
+			return splitGlyphs(glyphs, splits)
+		}
+		// Single syllable words have nothing to anchor to, so fall back to
+		// the manual split.
+	}
+	return mapSyllables(glyphs, slashes, wlen)
+}
+
+// syllablesToGlyphs converts the IPA of a word to glyphs, syllable by
+// syllable. It returns the glyphs, and the indices of the glyphs at which
+// each syllable (other than the first) begins.
+func syllablesToGlyphs(ipa string) ([]Glyph, []int, error) {
+	glyphs := []Glyph{}
+	bounds := []int{}
+	for i, syllable := range syllabifyIPA(ipa) {
+		if i > 0 {
+			bounds = append(bounds, len(glyphs))
+		}
+		sg, err := ipaToGlyphs(syllable.segments)
+		if err != nil {
+			return nil, nil, err
+		}
+		for j := range sg {
+			sg[j].stress = syllable.stress
+		}
+		glyphs = append(glyphs, sg...)
+	}
+	return glyphs, bounds, nil
+}
+
+// matchDict returns the index and dictionary for the given language.
+func matchDict(lang string) (int, IPADict, error) {
 	langTag, langIdx, confidence := langMatcher.Match(language.Make(lang))
 	if confidence <= language.Low {
-		return nil, fmt.Errorf("language not supported (sorry!)")
+		return 0, nil, fmt.Errorf(
+			"language not supported (sorry!), supported: %s",
+			strings.Join(supportedLangs(), ", "))
 	}
 
 	dict, ok := IPADicts[langIdx]
 	if !ok {
-		return nil, fmt.Errorf("no dictionary for language %q", langTag)
+		return 0, nil, fmt.Errorf("no dictionary for language %q", langTag)
 	}
+	return langIdx, dict, nil
+}
 
-	// We use a heuristic for the syllables.
-	// A '/' in the input indicates a new syllable. We record where they are
-	// in the input, then try to match them on the output.
-	syllablesIdxs := findSlashes(word)
-	word = strings.ReplaceAll(word, "/", "")
+// lookupIPA returns the IPA of the word, from the dictionary of the given
+// language. If the word is not in the dictionary, and we have G2P rules for
+// the language, they are used instead, and the letters they skipped are
+// returned too.
+func lookupIPA(word, lang string) (string, []string, error) {
+	langIdx, dict, err := matchDict(lang)
+	if err != nil {
+		return "", nil, err
+	}
 
-	// Get the IPA representation of the word.
-	ipa, ok := dict[word]
-	if !ok {
-		// Try the lowercase variant, for convenience.
-		// Note we can't just lowercase because some IPA dicts have
-		// intentional uppercase words.
-		ipa, ok = dict[strings.ToLower(word)]
-		if !ok {
-			return nil, fmt.Errorf("unknown word")
-		}
+	// Try the lowercase variant too, for convenience.
+	if ipa, ok := dictLookup(word, dict); ok {
+		return ipa, nil, nil
 	}
+	rules, hasRules := dictG2P[langIdx]
+	if !hasRules {
+		return "", nil, fmt.Errorf("unknown word")
+	}
+	ipa, skipped := rules.convert(word)
+	if ipa == "" {
+		return "", nil, fmt.Errorf("unknown word")
+	}
+	return ipa, skipped, nil
+}
 
-	// The conversion of IPA representation to glyphs is annoying, because we
-	// have to account for the two-symbol sequences.
-	ipaR := []rune(ipa)
+// ipaToGlyphs converts a sequence of IPA segments (see ipaSegments) to
+// glyphs.
+func ipaToGlyphs(segs []string) ([]Glyph, error) {
 	glyphs := []Glyph{}
-	for i := 0; i < len(ipaR); i++ {
-		// Look up this and the next rune in the two-symbol map.
-		// If we have a match, use it and skip the next rune.
-		if i+1 < len(ipaR) {
-			s := string(ipaR[i]) + string(ipaR[i+1])
-			if glyph, ok := ipaToGlyphs2[s]; ok {
-				glyphs = append(glyphs, mustGetGlyph(glyph))
-				i++
-				continue
-			}
+	for _, seg := range segs {
+		// The conversion is annoying, because we have to account for the
+		// two-symbol sequences. Luckily ipaSegments already grouped them.
+		if glyph, ok := ipaToGlyphs2[seg]; ok {
+			glyphs = append(glyphs, mustGetGlyph(glyph))
+			continue
 		}
 
 		// No match in the two-symbol map, look up this single symbol.
-		if glyph, ok := ipaToGlyphs1[ipaR[i]]; ok {
+		r := []rune(seg)[0]
+		if glyph, ok := ipaToGlyphs1[r]; ok {
 			if glyph == "" {
 				// Intentionally ignore empty glyphs.
 				continue
 			}
 			glyphs = append(glyphs, mustGetGlyph(glyph))
 		} else {
-			return nil, fmt.Errorf("unknown IPA symbol %q", ipaR[i])
+			return nil, fmt.Errorf("unknown IPA symbol %q", r)
 		}
 	}
-
-	return mapSyllables(glyphs, syllablesIdxs, len(word)), nil
+	return glyphs, nil
 }
 
 // findSlashes finds the indices of the slashes in the word.
@@ -345,29 +510,85 @@ func mapSyllables(glyphs []Glyph, slashes []int, wlen int) Word {
 
 // smartWordToGlyphs converts a string word to a glyph Word.
 // If the word has a dictionary prefix (e.g. "en:shadow"), we use that.
-// Otherwise, we search through some known IPA dictionaries to try to find it.
+// Otherwise, we use the language detected for the phrase (see wordLang).
 // And if that fails, we assume the word is a sequence of phonemes
 // (e.g. "SH-fEEt-R-All").
 // Syllables are separated by "/", and when doing IPA conversion we do a
-// best-effort heuristic mapping.
-func smartWordToGlyphs(word string) (Word, error) {
+// best-effort mapping, controlled by opts.syllables.
+func smartWordToGlyphs(word string, det langDetection, opts Options) (Word, error) {
+	lang, w := resolveWordLang(word, det, opts)
+	if lang == "" {
+		return phonemesToGlyphs(w)
+	}
+
+	gs, err := langWordToGlyphs(w, lang, opts)
+	if err == nil || strings.Contains(word, ":") {
+		// Language-prefixed words don't fall back to phonemes, so the user
+		// sees the error.
+		return gs, err
+	}
+
+	// We couldn't find the word so we assume it's a sequence of phonemes.
+	return phonemesToGlyphs(word)
+}
+
+// resolveWordLang returns the language to use for the word, and the word
+// without its prefix (if any). The language is "" if the word should be
+// treated as glyph names.
+func resolveWordLang(word string, det langDetection, opts Options) (string, string) {
 	if lang, w, ok := strings.Cut(word, ":"); ok {
-		if lang == "" || lang == "firstones" {
-			return phonemesToGlyphs(w)
+		if lang == "firstones" {
+			lang = ""
 		}
 		// Language-prefixed word.
-		return langWordToGlyphs(w, lang)
+		return opts.langDialect(lang), w
 	}
 
-	// No language prefix, try to find it through some known languages.
-	ds := []string{"es", "en"}
-	for _, lang := range ds {
-		gs, err := langWordToGlyphs(word, lang)
-		if err == nil {
-			return gs, nil
-		}
+	lang := wordLang(word, det)
+	if isEnglish(lang) {
+		// The region comes from the dictionary, not from the user, so we
+		// use the selected dialect.
+		lang = opts.langDialect("en")
 	}
+	return lang, word
+}
 
-	// We couldn't find the word so we assume it's a sequence of phonemes.
-	return phonemesToGlyphs(word)
+// wordsToGlyphs converts the words to glyph Words, using smartWordToGlyphs.
+// The language is detected for all the words together (see detectLanguage),
+// and then numbers and abbreviations are spelled out (see expandWords).
+// Words that don't have any glyphs are skipped.
+func wordsToGlyphs(words []string, opts Options) ([]Word, error) {
+	det := detectLanguage(words, opts.langs)
+	words, err := expandWords(words, det, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	wordsG := []Word{}
+	for _, word := range words {
+		if word == sentenceEnd {
+			// Sentence ends are represented as empty words, and only if we
+			// need to render them.
+			if opts.sentences != "" && opts.sentences != "none" &&
+				len(wordsG) > 0 && !wordsG[len(wordsG)-1].isSentenceEnd() {
+				wordsG = append(wordsG, Word{})
+			}
+			continue
+		}
+
+		wordG, err := smartWordToGlyphs(word, det, opts)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"error converting %q to glyphs: %v", word, err)
+		}
+
+		if len(wordG) == 0 {
+			// Skip empty words, this can happen with words that contain just
+			// "-" or "/".
+			continue
+		}
+
+		wordsG = append(wordsG, wordG)
+	}
+	return wordsG, nil
 }
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+)
+
+// JSON representation of the glyphs, for use by other programs.
+// The stress is only included if opts.stress is set.
+
+type jsonGlyph struct {
+	Name      string `json:"name"`
+	Connector bool   `json:"connector,omitempty"`
+	Stress    string `json:"stress,omitempty"`
+}
+
+type jsonSyllable struct {
+	Glyphs []jsonGlyph `json:"glyphs"`
+	Stress string      `json:"stress,omitempty"`
+}
+
+type jsonWord struct {
+	Text      string         `json:"text"`
+	Syllables []jsonSyllable `json:"syllables"`
+
+	// Whether the word ends a sentence. Only set if the sentences are not
+	// ignored (see sentenceModes).
+	SentenceEnd bool `json:"sentence_end,omitempty"`
+}
+
+func wordsToJSON(words []string, opts Options) ([]byte, error) {
+	wordsG, err := wordsToGlyphs(words, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	jws := []jsonWord{}
+	for _, wordG := range wordsG {
+		if wordG.isSentenceEnd() {
+			jws[len(jws)-1].SentenceEnd = true
+			continue
+		}
+
+		jw := jsonWord{Text: wordG.String()}
+		for _, syllable := range wordG {
+			js := jsonSyllable{}
+			if opts.stress {
+				js.Stress = syllable.stress().String()
+			}
+			for _, glyph := range syllable {
+				jg := jsonGlyph{
+					Name:      glyph.name,
+					Connector: glyph.connector,
+				}
+				if opts.stress {
+					jg.Stress = glyph.stress.String()
+				}
+				js.Glyphs = append(js.Glyphs, jg)
+			}
+			jw.Syllables = append(jw.Syllables, js)
+		}
+		jws = append(jws, jw)
+	}
+
+	return json.MarshalIndent(jws, "", "  ")
+}
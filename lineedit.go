@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// # Line editing
+//
+// The interactive mode (see replCmd) reads its lines with a small line
+// editor when the input is a terminal, so they can be edited, and the
+// previous ones recalled. It supports the usual keys:
+//
+//   - Left/Right (or Ctrl-B/Ctrl-F), Home/End (or Ctrl-A/Ctrl-E): move.
+//   - Backspace, Delete (or Ctrl-D): delete a character.
+//   - Ctrl-U, Ctrl-K: delete up to the start, or the end, of the line.
+//   - Ctrl-W: delete the word before the cursor.
+//   - Up/Down (or Ctrl-P/Ctrl-N): go through the history.
+//   - Ctrl-C: discard the line; Ctrl-D on an empty line: end of input.
+//
+// It assumes every character takes one column, and that the lines fit in
+// the terminal, which is enough for typing a few words.
+//
+// The terminal is put in non-canonical mode with stty, instead of using the
+// system calls directly, which would need code for each system. If it
+// fails, the lines are read as they are.
+
+// A source of lines for the interactive mode.
+type lineReader interface {
+	// readLine shows the prompt, and returns the next line, or io.EOF at
+	// the end of the input.
+	readLine(prompt string) (string, error)
+}
+
+// plainReader reads the lines as they are, for input that is not a
+// terminal.
+type plainReader struct {
+	scanner *bufio.Scanner
+	out     io.Writer
+}
+
+func (p *plainReader) readLine(prompt string) (string, error) {
+	fmt.Fprint(p.out, prompt)
+	if !p.scanner.Scan() {
+		return "", cmp.Or(p.scanner.Err(), io.EOF)
+	}
+	return p.scanner.Text(), nil
+}
+
+// Maximum number of lines to keep in the history.
+const maxHistory = 500
+
+// lineEditor reads lines from a terminal in non-canonical mode (without
+// echo), and takes care of the editing and the history.
+type lineEditor struct {
+	in  *bufio.Reader
+	out io.Writer
+
+	history []string
+
+	// The line being edited, and the position of the cursor in it.
+	buf []rune
+	pos int
+}
+
+func newLineEditor(in io.Reader, out io.Writer) *lineEditor {
+	return &lineEditor{in: bufio.NewReader(in), out: out}
+}
+
+// Control keys.
+const (
+	keyCtrlA     = 'A' - '@'
+	keyCtrlB     = 'B' - '@'
+	keyCtrlC     = 'C' - '@'
+	keyCtrlD     = 'D' - '@'
+	keyCtrlE     = 'E' - '@'
+	keyCtrlF     = 'F' - '@'
+	keyCtrlK     = 'K' - '@'
+	keyCtrlN     = 'N' - '@'
+	keyCtrlP     = 'P' - '@'
+	keyCtrlU     = 'U' - '@'
+	keyCtrlW     = 'W' - '@'
+	keyBackspace = 0x7f
+	keyEscape    = 0x1b
+)
+
+func (e *lineEditor) readLine(prompt string) (string, error) {
+	e.buf, e.pos = nil, 0
+
+	// Position in the history, and the line that was being typed before
+	// going through it.
+	hpos := len(e.history)
+	typed := []rune(nil)
+
+	e.redraw(prompt)
+	for {
+		r, _, err := e.in.ReadRune()
+		if err == io.EOF && len(e.buf) > 0 {
+			// Accept the last line, even without a newline.
+			return e.accept(), nil
+		} else if err != nil {
+			return "", err
+		}
+
+		switch r {
+		case '\r', '\n':
+			return e.accept(), nil
+		case keyCtrlC:
+			fmt.Fprint(e.out, "^C\n")
+			e.buf, e.pos = nil, 0
+			hpos = len(e.history)
+		case keyCtrlD:
+			if len(e.buf) == 0 {
+				return "", io.EOF
+			}
+			e.deleteAt(e.pos)
+		case keyBackspace, '\b':
+			if e.pos > 0 {
+				e.pos--
+				e.deleteAt(e.pos)
+			}
+		case keyCtrlA:
+			e.pos = 0
+		case keyCtrlE:
+			e.pos = len(e.buf)
+		case keyCtrlB:
+			e.pos = max(e.pos-1, 0)
+		case keyCtrlF:
+			e.pos = min(e.pos+1, len(e.buf))
+		case keyCtrlU:
+			e.buf = slices.Delete(e.buf, 0, e.pos)
+			e.pos = 0
+		case keyCtrlK:
+			e.buf = e.buf[:e.pos]
+		case keyCtrlW:
+			start := e.pos
+			for start > 0 && e.buf[start-1] == ' ' {
+				start--
+			}
+			for start > 0 && e.buf[start-1] != ' ' {
+				start--
+			}
+			e.buf = slices.Delete(e.buf, start, e.pos)
+			e.pos = start
+		case keyCtrlP, keyCtrlN:
+			hpos, typed = e.moveHistory(hpos, typed, r == keyCtrlP)
+		case keyEscape:
+			switch e.readEscape() {
+			case "[D":
+				e.pos = max(e.pos-1, 0)
+			case "[C":
+				e.pos = min(e.pos+1, len(e.buf))
+			case "[H", "OH", "[1~", "[7~":
+				e.pos = 0
+			case "[F", "OF", "[4~", "[8~":
+				e.pos = len(e.buf)
+			case "[3~":
+				e.deleteAt(e.pos)
+			case "[A", "OA":
+				hpos, typed = e.moveHistory(hpos, typed, true)
+			case "[B", "OB":
+				hpos, typed = e.moveHistory(hpos, typed, false)
+			}
+		default:
+			if !unicode.IsPrint(r) {
+				continue
+			}
+			e.buf = slices.Insert(e.buf, e.pos, r)
+			e.pos++
+		}
+		e.redraw(prompt)
+	}
+}
+
+// accept ends the line being edited, and returns it.
+func (e *lineEditor) accept() string {
+	fmt.Fprint(e.out, "\n")
+	line := string(e.buf)
+	e.addHistory(line)
+	return line
+}
+
+// readEscape reads the rest of an escape sequence (after the escape), and
+// returns it, e.g. "[A" for the up arrow. Sequences look like "[" or "O",
+// then optional parameters (digits and ";"), and a final letter or "~".
+func (e *lineEditor) readEscape() string {
+	seq := []byte{}
+	for len(seq) < 8 {
+		c, err := e.in.ReadByte()
+		if err != nil {
+			break
+		}
+		seq = append(seq, c)
+		if len(seq) == 1 {
+			if c != '[' && c != 'O' {
+				// Alt plus some key, which we ignore.
+				break
+			}
+			continue
+		}
+		if c >= 0x40 && c <= 0x7e {
+			break
+		}
+	}
+	return string(seq)
+}
+
+// deleteAt deletes the rune at the position, if there's one.
+func (e *lineEditor) deleteAt(i int) {
+	if i < len(e.buf) {
+		e.buf = slices.Delete(e.buf, i, i+1)
+	}
+}
+
+// moveHistory replaces the line with the previous (or next) one in the
+// history, from the position hpos. The line being typed is saved when
+// leaving it, and restored when coming back. It returns the new position,
+// and the saved line.
+func (e *lineEditor) moveHistory(hpos int, typed []rune, back bool) (
+	int, []rune) {
+	if hpos == len(e.history) {
+		typed = slices.Clone(e.buf)
+	}
+	switch {
+	case back && hpos > 0:
+		hpos--
+	case !back && hpos < len(e.history):
+		hpos++
+	default:
+		return hpos, typed
+	}
+
+	if hpos == len(e.history) {
+		e.buf = slices.Clone(typed)
+	} else {
+		e.buf = []rune(e.history[hpos])
+	}
+	e.pos = len(e.buf)
+	return hpos, typed
+}
+
+// addHistory adds the line to the history, unless it's empty, or the same
+// as the previous one.
+func (e *lineEditor) addHistory(line string) {
+	if strings.TrimSpace(line) == "" ||
+		(len(e.history) > 0 && e.history[len(e.history)-1] == line) {
+		return
+	}
+	e.history = append(e.history, line)
+	if len(e.history) > maxHistory {
+		e.history = e.history[len(e.history)-maxHistory:]
+	}
+}
+
+// redraw writes the prompt and the line, clears the rest of the terminal
+// line, and puts the cursor in place.
+func (e *lineEditor) redraw(prompt string) {
+	s := "\r" + prompt + string(e.buf) + "\x1b[K"
+	if n := len(e.buf) - e.pos; n > 0 {
+		s += fmt.Sprintf("\x1b[%dD", n)
+	}
+	fmt.Fprint(e.out, s)
+}
+
+var errNoStty = errors.New("can't change the terminal mode")
+
+// rawTerminal puts the terminal in non-canonical mode, without echo or
+// signals (so Ctrl-C only discards the line), using stty. It returns a
+// function to restore the previous mode.
+func rawTerminal(f *os.File) (func(), error) {
+	stty := func(args ...string) (string, error) {
+		ctx, cancel := context.WithTimeout(context.Background(),
+			time.Second)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, "stty", args...)
+		cmd.Stdin = f
+		out, err := cmd.Output()
+		return strings.TrimSpace(string(out)), err
+	}
+
+	saved, err := stty("-g")
+	if err != nil || saved == "" {
+		return nil, errNoStty
+	}
+	_, err = stty("-icanon", "-echo", "-isig", "min", "1", "time", "0")
+	if err != nil {
+		stty(saved)
+		return nil, errNoStty
+	}
+	return func() { stty(saved) }, nil
+}
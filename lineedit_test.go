@@ -0,0 +1,78 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLineEditor(t *testing.T) {
+	in := strings.Join([]string{
+		// Typing, with a mistake fixed by moving left.
+		"bth\x1b[D\x1b[Da\r",
+		// Up recalls the previous line; Home, Ctrl-E and Backspace.
+		"\x1b[A\x01x\x05\x7f\x7fh\n",
+		// Ctrl-C discards the line.
+		"adora\x03catra\r",
+		// Ctrl-U, Ctrl-K, Ctrl-W and Delete.
+		"abc def\x15x\x01\x1b[3~\x0b",
+		"glimmer\r",
+		"one two\x17three\r",
+		// Going up and back down restores what was being typed.
+		"tmp\x10\x10\x0e\x0e!\r",
+		// Non-printable characters and unknown sequences are ignored.
+		"a\x07\x1bxb\x1b[5~\r",
+		// The last line doesn't need a newline.
+		"last",
+	}, "")
+	e := newLineEditor(strings.NewReader(in), io.Discard)
+
+	got := []string{}
+	for {
+		line, err := e.readLine("> ")
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("readLine error: %v", err)
+		}
+		got = append(got, line)
+	}
+	expected := []string{
+		"bath", "xbah", "catra", "glimmer", "one three", "tmp!", "ab",
+		"last",
+	}
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Errorf("lines mismatch (-want +got):\n%s", diff)
+	}
+
+	// All the lines are different, so they're all in the history.
+	if diff := cmp.Diff(expected, e.history); diff != "" {
+		t.Errorf("history mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestLineEditorCtrlD(t *testing.T) {
+	// Ctrl-D deletes the character under the cursor, or ends the input on
+	// an empty line.
+	e := newLineEditor(strings.NewReader("ab\x02\x04\r\x04more\r"),
+		io.Discard)
+	if line, err := e.readLine("> "); line != "a" || err != nil {
+		t.Errorf("first line: %q, %v", line, err)
+	}
+	if line, err := e.readLine("> "); line != "" || err != io.EOF {
+		t.Errorf("second line: %q, %v; expected EOF", line, err)
+	}
+}
+
+func TestLineEditorRedraw(t *testing.T) {
+	out := &strings.Builder{}
+	e := newLineEditor(strings.NewReader("ab\x1b[D\r"), out)
+	e.readLine("> ")
+	expected := "\r> \x1b[K" + "\r> a\x1b[K" + "\r> ab\x1b[K" +
+		"\r> ab\x1b[K\x1b[1D" + "\n"
+	if got := out.String(); got != expected {
+		t.Errorf("got %q, expected %q", got, expected)
+	}
+}
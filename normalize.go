@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// # IPA normalization
+//
+// The dictionaries (especially the ones from other sources) use diacritics
+// and other marks that don't affect which glyph we pick: length marks, tie
+// bars, syllabic marks, nasalisation, aspiration, etc.
+// Before converting the IPA to glyphs, we remove them (or replace them) using
+// a set of rules, so the mapping tables only need to care about the base
+// symbols.
+//
+// After applying the rules, any remaining combining marks are removed too,
+// so e.g. "ẽ" is folded to "e" even if there is no explicit rule for it.
+
+// An IPA normalization rule: "from" is replaced by "to" (which can be empty,
+// to just remove it).
+type ipaRule struct {
+	from, to string
+}
+
+// Default normalization rules.
+var defaultIPARules = []ipaRule{
+	// Symbols that decompose into a base symbol and a combining mark, but
+	// have their own mapping, so we keep them as they are.
+	{"c\u0327", "ç"},
+
+	// Length marks.
+	{"ː", ""}, // Long.
+	{"ˑ", ""}, // Half-long.
+	{":", ""}, // Colon, commonly used instead of "ː".
+
+	// Tie bars.
+	{"\u0361", ""}, // Above, e.g. "t͡ʃ".
+	{"\u035c", ""}, // Below, e.g. "t͜ʃ".
+	{"‿", ""},      // Linking.
+
+	// Syllabicity.
+	{"\u0329", ""}, // Syllabic, e.g. "n̩".
+	{"\u030d", ""}, // Syllabic (above), e.g. "n̍".
+	{"\u032f", ""}, // Non-syllabic, e.g. "ɪ̯".
+
+	// Nasalisation.
+	{"\u0303", ""}, // Nasalized, e.g. "ɑ̃".
+
+	// Secondary articulations and releases.
+	{"ʰ", ""}, // Aspirated.
+	{"ʷ", ""}, // Labialized.
+	{"ʲ", ""}, // Palatalized.
+	{"ˠ", ""}, // Velarized.
+	{"ˤ", ""}, // Pharyngealized.
+	{"ⁿ", ""}, // Nasal release.
+	{"ˡ", ""}, // Lateral release.
+	{"ʼ", ""}, // Ejective.
+
+	// R-coloring.
+	{"˞", ""},
+
+	// Prosody that we don't use.
+	{"|", ""}, {"‖", ""}, {"↗", ""}, {"↘", ""},
+}
+
+// The rules in use. They are the default ones, plus the ones loaded with
+// loadIPARules.
+var ipaRules = slices.Clone(defaultIPARules)
+
+// loadIPARules loads normalization rules from the given file, and adds them
+// to the ones in use. They take precedence over the existing ones.
+// The file has one rule per line, with the format:
+//
+//	from<TAB>to
+//
+// "to" can be omitted to remove the symbol. Empty lines and lines starting
+// with "#" are ignored.
+func loadIPARules(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	rules := []ipaRule{}
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		from, to, _ := strings.Cut(line, "\t")
+		from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+		if from == "" {
+			return fmt.Errorf("%s:%d: empty symbol", path, n)
+		}
+		rules = append(rules, ipaRule{from, to})
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	ipaRules = append(rules, ipaRules...)
+	return nil
+}
+
+// normalizeIPA applies the normalization rules to the given IPA, and
+// returns the result, and the symbols that were removed or replaced (sorted,
+// without duplicates).
+func normalizeIPA(ipa string) (string, []string) {
+	changed := []string{}
+
+	// Decompose, so precomposed characters (e.g. "ẽ") become a base symbol
+	// and combining marks that the rules can match.
+	ipa = norm.NFD.String(ipa)
+
+	var sb strings.Builder
+	for len(ipa) > 0 {
+		matched := false
+		for _, rule := range ipaRules {
+			if strings.HasPrefix(ipa, rule.from) {
+				sb.WriteString(rule.to)
+				if rule.to != norm.NFC.String(rule.from) {
+					changed = append(changed, rule.from)
+				}
+				ipa = ipa[len(rule.from):]
+				matched = true
+				break
+			}
+		}
+		if matched {
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(ipa)
+		if unicode.Is(unicode.Mn, r) {
+			changed = append(changed, string(r))
+		} else {
+			sb.WriteRune(r)
+		}
+		ipa = ipa[size:]
+	}
+
+	slices.Sort(changed)
+	return norm.NFC.String(sb.String()), slices.Compact(changed)
+}
+
+// quoteSymbols returns the symbols quoted and separated by commas, for
+// reporting. The combining marks are shown over a dotted circle, so they are
+// visible.
+func quoteSymbols(symbols []string) string {
+	qs := []string{}
+	for _, s := range symbols {
+		r, _ := utf8.DecodeRuneInString(s)
+		if unicode.Is(unicode.Mn, r) {
+			s = "◌" + s
+		}
+		qs = append(qs, fmt.Sprintf("%q", s))
+	}
+	return strings.Join(qs, ", ")
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNormalizeIPA(t *testing.T) {
+	cases := []struct {
+		ipa, expected string
+		removed       []string
+	}{
+		{"", "", []string{}},
+		{"ˈʃiɹɑ", "ˈʃiɹɑ", []string{}},
+		{"fiːt", "fit", []string{"ː"}},
+		{"t͡ʃɛk", "tʃɛk", []string{"͡"}},
+		{"bʌtn̩", "bʌtn", []string{"̩"}},
+		{"ɑ̃fɑ̃", "ɑfɑ", []string{"̃"}},
+		{"pʰiːʷ", "pi", []string{"ʰ", "ʷ", "ː"}},
+
+		// Precomposed characters are decomposed, and combining marks without
+		// a rule are removed anyway.
+		{"ẽ", "e", []string{"̃"}},
+		{"ä", "a", []string{"̈"}},
+
+		// Except for the ones that have their own mapping.
+		{"ɪç", "ɪç", []string{}},
+	}
+	for _, c := range cases {
+		got, removed := normalizeIPA(c.ipa)
+		if got != c.expected {
+			t.Errorf("normalizeIPA(%q) = %q, expected %q",
+				c.ipa, got, c.expected)
+		}
+		if diff := cmp.Diff(c.removed, removed); diff != "" {
+			t.Errorf("normalizeIPA(%q) removed mismatch:\n%s", c.ipa, diff)
+		}
+	}
+}
+
+func TestLoadIPARules(t *testing.T) {
+	defer func(orig []ipaRule) { ipaRules = orig }(slices.Clone(ipaRules))
+
+	path := filepath.Join(t.TempDir(), "rules")
+	content := "# Comment.\n\nʀ\tɹ\nˀ\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadIPARules(path); err != nil {
+		t.Fatalf("loadIPARules: %v", err)
+	}
+
+	got, removed := normalizeIPA("ʀuːˀ")
+	if got != "ɹu" {
+		t.Errorf("normalizeIPA with rules = %q, expected %q", got, "ɹu")
+	}
+	if diff := cmp.Diff([]string{"ʀ", "ˀ", "ː"}, removed); diff != "" {
+		t.Errorf("removed mismatch:\n%s", diff)
+	}
+
+	if err := loadIPARules(path + "-missing"); err == nil {
+		t.Errorf("loadIPARules on missing file did not fail")
+	}
+
+	os.WriteFile(path, []byte("\tx\n"), 0o644)
+	if err := loadIPARules(path); err == nil {
+		t.Errorf("loadIPARules with empty symbol did not fail")
+	}
+}
+
+func TestQuoteSymbols(t *testing.T) {
+	got := quoteSymbols([]string{"ː", "̃"})
+	expected := `"ː", "◌̃"`
+	if got != expected {
+		t.Errorf("quoteSymbols = %q, expected %q", got, expected)
+	}
+}
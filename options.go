@@ -0,0 +1,268 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// Options that control how the words are converted to glyphs, and how they
+// are rendered.
+// They come from the command line flags, or from the HTTP request
+// parameters.
+type Options struct {
+	// How to split the words into syllables, see syllableModes.
+	syllables string
+
+	// Show the stressed syllables.
+	stress bool
+
+	// Angle of the word line, in degrees (see defaultAngle).
+	angle int
+
+	// How to render the end of sentences, see sentenceModes.
+	sentences string
+
+	// Keep the parts of compound words as separate syllables, see
+	// splitCompound.
+	parts bool
+
+	// Preferred languages, used by the language detection as the default
+	// when the words don't give it away (see detectLanguage).
+	langs []language.Tag
+
+	// English dialect for the words without an explicit region, one of
+	// dialectTags.
+	dialect language.Tag
+
+	// Whether the dialect was given explicitly, instead of coming from the
+	// preferred languages; if so, changing the languages doesn't change it.
+	explicitDialect bool
+
+	// Colors and strokes of the rendered words.
+	style style
+
+	// Animation of the SVG output.
+	anim animation
+
+	// Draw the outlines of the strokes, instead of the strokes.
+	outline outlineOptions
+
+	// Function to report notes about the conversion (e.g. what was removed
+	// from the IPA). Can be nil, in which case the notes are discarded.
+	notef func(format string, args ...interface{})
+}
+
+var (
+	syllablesFlag = flag.String("syllables", "manual",
+		"how to split words into syllables: manual, anchor, or auto")
+	stressFlag = flag.Bool("stress", false,
+		"emphasize the stressed syllables")
+	angleFlag = flag.Int("angle", defaultAngle,
+		"angle of the word line, in degrees")
+	partsFlag = flag.Bool("parts", false,
+		"keep each part of compound words (e.g. \"rainbow-cat\") as its "+
+			"own syllable")
+	sentencesFlag = flag.String("sentences", "none",
+		"how to show the end of sentences: none, gap, or mark")
+	ipaRulesFlag = flag.String("ipa-rules", "",
+		"file with additional IPA normalization rules")
+	langFlag = flag.String("lang", "",
+		"preferred languages for words without a prefix, comma-separated "+
+			"(e.g. \"fr,en\"); by default it is detected")
+	dialectFlag = flag.String("dialect", "",
+		"English dialect (e.g. en-US, en-GB, en-AU); by default it is "+
+			"taken from -lang, or en-US")
+	userDictFlag = flag.String("user-dict", "",
+		"file with additional words, which take precedence over the "+
+			"built-in dictionaries")
+)
+
+// Additional dictionaries, see loadDicts.
+var dictFlags dictFlag
+
+func init() {
+	flag.Var(&dictFlags, "dict",
+		"additional dictionary, as lang=[format:]path (can be repeated); "+
+			"formats: ipa-dict, cmudict, csv")
+}
+
+// optionsFromFlags returns the options given in the command line.
+func optionsFromFlags() (Options, error) {
+	opts := Options{
+		syllables: *syllablesFlag,
+		stress:    *stressFlag,
+		angle:     *angleFlag,
+		sentences: *sentencesFlag,
+		parts:     *partsFlag,
+		anim:      animationFromFlags(),
+		langs:     []language.Tag{},
+		notef: func(format string, args ...interface{}) {
+			fmt.Fprintf(os.Stderr, "note: "+format+"\n", args...)
+		},
+		outline: outlineOptions{
+			enabled: *outlineFlag,
+			width:   *outlineWidthFlag,
+		},
+	}
+	if *langFlag != "" {
+		langs, err := parseLangs(*langFlag)
+		if err != nil {
+			return opts, err
+		}
+		opts.langs = langs
+	}
+
+	opts.dialect = preferredDialect(opts.langs)
+	if *dialectFlag != "" {
+		tag, err := parseDialect(*dialectFlag)
+		if err != nil {
+			return opts, err
+		}
+		opts.dialect = tag
+		opts.explicitDialect = true
+	}
+
+	if err := opts.style.applyValues(styleFlagValues()); err != nil {
+		return opts, err
+	}
+	return opts, opts.check()
+}
+
+// optionsFromRequest returns the options given in the HTTP request, using
+// the defaults for the ones that are missing.
+func optionsFromRequest(r *http.Request) (Options, error) {
+	r.ParseForm()
+	opts := Options{
+		syllables: "manual",
+		angle:     defaultAngle,
+		sentences: "none",
+		anim: animation{
+			glyphTime: defaultGlyphTime,
+			pause:     defaultPause,
+		},
+	}
+
+	// The browser's languages are the default for detection (and the
+	// dialect). Errors are ignored, since the header is not in the user's
+	// control.
+	opts.langs, _, _ = language.ParseAcceptLanguage(
+		r.Header.Get("Accept-Language"))
+	opts.dialect = preferredDialect(opts.langs)
+
+	if err := opts.applyValues(r.Form); err != nil {
+		return opts, err
+	}
+	return opts, opts.check()
+}
+
+// applyValues overrides the options with the ones given as URL values (like
+// the HTTP request parameters). Options that are not present are left as
+// they are.
+func (o *Options) applyValues(v url.Values) error {
+	if s := v.Get("syllables"); s != "" {
+		o.syllables = s
+	}
+	if v.Has("stress") {
+		o.stress = isTrue(v.Get("stress"))
+	}
+	if v.Has("parts") {
+		o.parts = isTrue(v.Get("parts"))
+	}
+	if s := v.Get("angle"); s != "" {
+		angle, err := strconv.Atoi(s)
+		if err != nil {
+			return fmt.Errorf("invalid angle %q", s)
+		}
+		o.angle = angle
+	}
+	if s := v.Get("sentences"); s != "" {
+		o.sentences = s
+	}
+	if s := v.Get("lang"); s != "" {
+		langs, err := parseLangs(s)
+		if err != nil {
+			return err
+		}
+		o.langs = langs
+		if !o.explicitDialect {
+			o.dialect = preferredDialect(langs)
+		}
+	}
+	if s := v.Get("dialect"); s != "" {
+		tag, err := parseDialect(s)
+		if err != nil {
+			return err
+		}
+		o.dialect = tag
+		o.explicitDialect = true
+	}
+	if err := o.anim.applyValues(v); err != nil {
+		return err
+	}
+	return o.style.applyValues(v)
+}
+
+// isTrue returns true if the value of a boolean option is set.
+func isTrue(s string) bool {
+	return s == "1" || s == "true"
+}
+
+// parseLangs parses a comma-separated list of languages.
+func parseLangs(s string) ([]language.Tag, error) {
+	langs := []language.Tag{}
+	for _, l := range strings.Split(s, ",") {
+		tag, err := language.Parse(strings.TrimSpace(l))
+		if err != nil {
+			return nil, fmt.Errorf("invalid language %q: %v", l, err)
+		}
+		langs = append(langs, tag)
+	}
+	return langs, nil
+}
+
+// note reports a note about the conversion, see Options.notef.
+func (o Options) note(format string, args ...interface{}) {
+	if o.notef != nil {
+		o.notef(format, args...)
+	}
+}
+
+// langDialect returns the language to use for a word in the given language,
+// which is the selected dialect if it's English without an explicit region.
+func (o Options) langDialect(lang string) string {
+	if !isEnglish(lang) || o.dialect == (language.Tag{}) {
+		return lang
+	}
+	if _, conf := language.Make(lang).Region(); conf == language.Exact {
+		return lang
+	}
+	return o.dialect.String()
+}
+
+// check that the options are valid.
+func (o Options) check() error {
+	if err := checkSyllableMode(o.syllables); err != nil {
+		return err
+	}
+	if o.angle < -maxAngle || o.angle > maxAngle {
+		return fmt.Errorf("angle %d out of range, must be between %d and %d",
+			o.angle, -maxAngle, maxAngle)
+	}
+	if err := checkSentenceMode(o.sentences); err != nil {
+		return err
+	}
+	if err := o.outline.check(); err != nil {
+		return err
+	}
+	if o.anim.enabled && o.outline.enabled {
+		return fmt.Errorf("animations can't be used with outlines")
+	}
+	return o.anim.check()
+}
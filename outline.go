@@ -0,0 +1,404 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// # Outlines
+//
+// Some outputs (like cutting, or 3D printing) can't use strokes: they need
+// the outline of the area that the shapes cover.
+//
+// To get it, we compute the signed distance from each point of a fine grid
+// to the area covered by the shapes (negative inside), and then trace its
+// contour with marching squares. The distance makes the contour follow the
+// edges closely, interpolating between the grid points, and makes it easy
+// to union the shapes (it's the minimum of their distances), and to grow
+// the area by some amount (it's the contour at that distance).
+//
+// Sharp corners (like the ones of miter joins) are rounded at the scale of
+// the grid, which is fine for our purposes.
+//
+// With -outline, the SVG output has the outline of the words instead of
+// their strokes: a single filled path, which vinyl cutters and font tools
+// can use as is. It has the stroke color, so the word lines lose their own
+// color, if the style has one. The DXF output uses it too (see dxfBackend).
+
+var (
+	outlineFlag = flag.Bool("outline", false,
+		"draw the strokes as filled outlines: a single path in svg, and "+
+			"closed polylines in dxf")
+	outlineWidthFlag = flag.Float64("outline-width", 0,
+		"with -outline, width of the strokes in millimetres; 0 keeps the "+
+			"stroke width")
+)
+
+// Maximum width of the outlined strokes.
+const maxOutlineWidth = 5
+
+type outlineOptions struct {
+	enabled bool
+
+	// Width of the strokes, replacing the base stroke width of the style
+	// (see shapes). 0 keeps it.
+	width float64
+}
+
+// check that the outline options are valid.
+func (o outlineOptions) check() error {
+	if o.width < 0 || o.width > maxOutlineWidth {
+		return fmt.Errorf("outline width %v out of range, must be up to %v",
+			o.width, maxOutlineWidth)
+	}
+	return nil
+}
+
+// shapes returns the shapes with the strokes at the width of the outline.
+// The strokes are scaled from the base width (the one of the style), so
+// the ones that are wider (like stressed syllables) stay so.
+func (o outlineOptions) shapes(shapes []shape, base float64) []shape {
+	if o.width == 0 || base == 0 {
+		return shapes
+	}
+	scaled := make([]shape, len(shapes))
+	for i, s := range shapes {
+		s.strokeWidth *= o.width / base
+		scaled[i] = s
+	}
+	return scaled
+}
+
+// outlineSVG returns the outline of the words SVG (as returned by
+// wordsToSVG), as a single filled path.
+func outlineSVG(wsvg SVG, width, height int, opts Options) (SVG, error) {
+	sc, err := wordsScene(wsvg, width, height)
+	if err != nil {
+		return "", err
+	}
+	st := opts.style.resolved()
+	sps := outlineShapes(opts.outline.shapes(sc.shapes, st.width), 0)
+	return SVGfn(`<path d="%s" fill="%s" /> <!-- Outline -->`,
+		pathData(sps), st.glyphStroke()), nil
+}
+
+// pathData returns the subpaths as SVG path data.
+func pathData(sps []subpath) string {
+	d := []string{}
+	for _, sp := range sps {
+		for i, p := range sp.points {
+			op := "L"
+			if i == 0 {
+				op = "M"
+			}
+			d = append(d, op+svgNum(p.x)+" "+svgNum(p.y))
+		}
+		if sp.closed {
+			d = append(d, "Z")
+		}
+	}
+	return strings.Join(d, " ")
+}
+
+// Size of the cells of the grid, in millimetres.
+const outlineResolution = 0.05
+
+// Maximum distance from the simplified outline to the traced one, in
+// millimetres.
+const outlineTolerance = 0.005
+
+// outlineShapes returns the outline of the area covered by the shapes
+// (their fills and strokes), grown by the given distance. The outline is
+// made of closed subpaths: the ones around the area and the ones around its
+// holes go in opposite directions, so the inside is always on the same side
+// (to the (-dy, dx) side of each segment).
+func outlineShapes(shapes []shape, grow float64) []subpath {
+	res := outlineResolution
+	margin := grow + 2*res
+
+	// The grid covers all the shapes, with some room so the contour never
+	// touches its edges.
+	min := point{math.Inf(1), math.Inf(1)}
+	max := point{math.Inf(-1), math.Inf(-1)}
+	dists := []func(point) float64{}
+	boxes := [][2]point{}
+	for _, s := range shapes {
+		d := shapeDistance(s)
+		if d == nil {
+			continue
+		}
+		smin, smax := s.bounds()
+		smin = point{smin.x - margin, smin.y - margin}
+		smax = point{smax.x + margin, smax.y + margin}
+		dists = append(dists, d)
+		boxes = append(boxes, [2]point{smin, smax})
+		min = point{math.Min(min.x, smin.x), math.Min(min.y, smin.y)}
+		max = point{math.Max(max.x, smax.x), math.Max(max.y, smax.y)}
+	}
+	if len(dists) == 0 {
+		return nil
+	}
+	min = point{min.x - res, min.y - res}
+	nx := int(math.Ceil((max.x-min.x)/res)) + 2
+	ny := int(math.Ceil((max.y-min.y)/res)) + 2
+
+	// Outside of the box of each shape the distance is at least margin, so
+	// we only need to compute it inside.
+	far := float32(margin + res)
+	grid := make([]float32, nx*ny)
+	for i := range grid {
+		grid[i] = far
+	}
+	for k, d := range dists {
+		i0 := int((boxes[k][0].x - min.x) / res)
+		j0 := int((boxes[k][0].y - min.y) / res)
+		i1 := int(math.Ceil((boxes[k][1].x - min.x) / res))
+		j1 := int(math.Ceil((boxes[k][1].y - min.y) / res))
+		for j := j0; j <= j1 && j < ny; j++ {
+			for i := i0; i <= i1 && i < nx; i++ {
+				p := point{min.x + float64(i)*res, min.y + float64(j)*res}
+				v := float32(d(p) - grow)
+				if v < grid[j*nx+i] {
+					grid[j*nx+i] = v
+				}
+			}
+		}
+	}
+
+	sps := traceContour(grid, nx, ny, func(x, y float64) point {
+		return point{min.x + x*res, min.y + y*res}
+	})
+	for i := range sps {
+		sps[i].points = simplifyRing(sps[i].points, outlineTolerance)
+	}
+	return sps
+}
+
+// ringArea returns the signed area of the closed subpath, positive when the
+// inside is to the (-dy, dx) side of the segments.
+func ringArea(sp subpath) float64 {
+	a := 0.0
+	for i, p := range sp.points {
+		q := sp.points[(i+1)%len(sp.points)]
+		a += p.x*q.y - q.x*p.y
+	}
+	return a / 2
+}
+
+// traceContour returns the contour of the area where the grid values are
+// negative, using marching squares. The grid has nx x ny values, and is
+// assumed to be positive at its edges. The points are converted to the
+// final coordinates with pos, given the position in the grid.
+func traceContour(grid []float32, nx, ny int,
+	pos func(x, y float64) point) []subpath {
+	inside := func(i, j int) bool {
+		return grid[j*nx+i] < 0
+	}
+
+	// The crossing points of the contour are on the edges of the grid; we
+	// identify them by the edge, which is horizontal (from (i, j) to
+	// (i+1, j)) or vertical (from (i, j) to (i, j+1)).
+	hEdge := func(i, j int) int { return 2 * (j*nx + i) }
+	vEdge := func(i, j int) int { return 2*(j*nx+i) + 1 }
+	crossing := func(e int) point {
+		i, j := (e/2)%nx, (e/2)/nx
+		a := grid[j*nx+i]
+		b := grid[j*nx+i+1]
+		if e%2 == 1 {
+			b = grid[(j+1)*nx+i]
+		}
+		t := float64(a / (a - b))
+		if e%2 == 0 {
+			return pos(float64(i)+t, float64(j))
+		}
+		return pos(float64(i), float64(j)+t)
+	}
+
+	// For each crossing, the next one along the contour. In each cell,
+	// going around its corners, the contour goes from where we exit the
+	// area to where we enter it, which leaves the inside to the (-dy, dx)
+	// side.
+	next := map[int]int{}
+	order := []int{}
+	for j := range ny - 1 {
+		for i := range nx - 1 {
+			corners := [4]bool{
+				inside(i, j), inside(i+1, j),
+				inside(i+1, j+1), inside(i, j+1),
+			}
+			// Edges between corners k and k+1: top, right, bottom, left.
+			edges := [4]int{
+				hEdge(i, j), vEdge(i+1, j), hEdge(i, j+1), vEdge(i, j),
+			}
+			exits, entries := []int{}, []int{}
+			for k := range 4 {
+				a, b := corners[k], corners[(k+1)%4]
+				if a && !b {
+					exits = append(exits, k)
+				} else if !a && b {
+					entries = append(entries, k)
+				}
+			}
+			switch len(exits) {
+			case 1:
+				next[edges[exits[0]]] = edges[entries[0]]
+				order = append(order, edges[exits[0]])
+			case 2:
+				// A saddle: if the center is inside, the inside corners
+				// are connected, and each exit goes to the following
+				// entry; otherwise, to the previous one.
+				center := grid[j*nx+i] + grid[j*nx+i+1] +
+					grid[(j+1)*nx+i] + grid[(j+1)*nx+i+1]
+				for _, k := range exits {
+					e := (k + 1) % 4
+					if center >= 0 {
+						e = (k + 3) % 4
+					}
+					next[edges[k]] = edges[e]
+					order = append(order, edges[k])
+				}
+			}
+		}
+	}
+
+	sps := []subpath{}
+	for _, start := range order {
+		if _, ok := next[start]; !ok {
+			continue
+		}
+		sp := subpath{closed: true}
+		for e := start; ; {
+			sp.points = append(sp.points, crossing(e))
+			n := next[e]
+			delete(next, e)
+			if n == start {
+				break
+			}
+			e = n
+		}
+		sps = append(sps, sp)
+	}
+	return sps
+}
+
+// simplifyRing removes the points of the closed ring that are within tol
+// of the lines between the ones that are kept (Douglas-Peucker).
+func simplifyRing(pts []point, tol float64) []point {
+	if len(pts) < 4 {
+		return pts
+	}
+	// Split the ring at the first point, and the one farthest from it.
+	far := 0
+	for i, p := range pts {
+		if dist(p, pts[0]) > dist(pts[far], pts[0]) {
+			far = i
+		}
+	}
+	ring := append(pts[:len(pts):len(pts)], pts[0])
+	first := simplifyLine(ring[:far+1], tol)
+	second := simplifyLine(ring[far:], tol)
+	out := append(first[:len(first)-1:len(first)-1], second...)
+	return out[:len(out)-1]
+}
+
+// simplifyLine is the Douglas-Peucker simplification of an open line.
+func simplifyLine(pts []point, tol float64) []point {
+	if len(pts) < 3 {
+		return pts
+	}
+	a, b := pts[0], pts[len(pts)-1]
+	far, fd := 0, -1.0
+	for i := 1; i < len(pts)-1; i++ {
+		if d := segmentDistance(pts[i], a, b); d > fd {
+			far, fd = i, d
+		}
+	}
+	if fd <= tol {
+		return []point{a, b}
+	}
+	left := simplifyLine(pts[:far+1], tol)
+	right := simplifyLine(pts[far:], tol)
+	return append(left[:len(left)-1:len(left)-1], right...)
+}
+
+func dist(a, b point) float64 {
+	return math.Hypot(b.x-a.x, b.y-a.y)
+}
+
+// shapeDistance returns a function with the signed distance from a point
+// to the area covered by the shape (its fill and stroke): negative inside,
+// and positive outside. Inside, it's only approximate. It returns nil if
+// the shape isn't painted.
+func shapeDistance(s shape) func(p point) float64 {
+	var fill, stroke func(p point) float64
+	hw := s.strokeWidth / 2
+
+	switch {
+	case s.circle:
+		fill = func(p point) float64 {
+			return dist(p, s.center) - s.r
+		}
+		stroke = func(p point) float64 {
+			return math.Abs(dist(p, s.center)-s.r) - hw
+		}
+	default:
+		fill = func(p point) float64 {
+			return polygonDistance(s.subpaths, p)
+		}
+		if s.linecap == "round" && s.linejoin == "round" {
+			// Everything within hw of the segments.
+			stroke = func(p point) float64 {
+				d := math.Inf(1)
+				for _, sp := range s.subpaths {
+					segments(sp, func(a, b point) {
+						d = math.Min(d, segmentDistance(p, a, b))
+					})
+				}
+				return d - hw
+			}
+			break
+		}
+		polys, disks := s.strokeParts()
+		stroke = func(p point) float64 {
+			d := math.Inf(1)
+			for _, dk := range disks {
+				d = math.Min(d, dist(p, dk.center)-dk.r)
+			}
+			for _, poly := range polys {
+				d = math.Min(d, polygonDistance([]subpath{poly}, p))
+			}
+			return d
+		}
+	}
+
+	switch {
+	case s.hasFill() && s.hasStroke():
+		return func(p point) float64 {
+			return math.Min(fill(p), stroke(p))
+		}
+	case s.hasFill():
+		return fill
+	case s.hasStroke():
+		return stroke
+	}
+	return nil
+}
+
+// polygonDistance returns the signed distance from p to the area inside
+// the subpaths (all considered closed, with the non-zero rule).
+func polygonDistance(sps []subpath, p point) float64 {
+	d := math.Inf(1)
+	for _, sp := range sps {
+		closed := sp
+		closed.closed = true
+		segments(closed, func(a, b point) {
+			d = math.Min(d, segmentDistance(p, a, b))
+		})
+	}
+	if windingNumber(sps, p) != 0 {
+		return -d
+	}
+	return d
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"image/color"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestOutlineShapes(t *testing.T) {
+	black := color.NRGBA{0, 0, 0, 255}
+	line := func(a, b point) shape {
+		return shape{
+			subpaths: []subpath{{points: []point{a, b}}},
+			stroke:   black, strokeWidth: 2,
+			linecap: "round", linejoin: "round",
+		}
+	}
+	cases := []struct {
+		name   string
+		shapes []shape
+		grow   float64
+		areas  []float64
+	}{
+		{"line", []shape{line(point{0, 0}, point{10, 0})}, 0,
+			[]float64{10*2 + math.Pi}},
+		{"grown line", []shape{line(point{0, 0}, point{10, 0})}, 0.5,
+			[]float64{10*3 + math.Pi*1.5*1.5}},
+		{"butt line", []shape{{
+			subpaths: []subpath{{points: []point{{0, 0}, {10, 0}}}},
+			stroke:   black, strokeWidth: 2, linecap: "butt",
+			linejoin: "miter",
+		}}, 0, []float64{10 * 2}},
+		// The overlapping part counts once.
+		{"cross", []shape{
+			line(point{0, 5}, point{10, 5}), line(point{5, 0}, point{5, 10}),
+		}, 0, []float64{2*(10*2+math.Pi) - 2*2}},
+		// A ring has a hole, in the opposite direction.
+		{"circle", []shape{{
+			circle: true, center: point{5, 5}, r: 3,
+			stroke: black, strokeWidth: 2,
+		}}, 0, []float64{math.Pi * 4 * 4, -math.Pi * 2 * 2}},
+		{"filled circle", []shape{{
+			circle: true, center: point{5, 5}, r: 3, fill: black,
+		}}, 0, []float64{math.Pi * 3 * 3}},
+		{"not painted", []shape{{
+			circle: true, center: point{5, 5}, r: 3,
+		}}, 0, nil},
+	}
+	for _, c := range cases {
+		sps := outlineShapes(c.shapes, c.grow)
+		if len(sps) != len(c.areas) {
+			t.Errorf("%s: got %d subpaths, expected %d", c.name, len(sps),
+				len(c.areas))
+			continue
+		}
+		for i, sp := range sps {
+			if !sp.closed {
+				t.Errorf("%s: subpath %d is not closed", c.name, i)
+			}
+			// The outline is a polygon, so it is slightly smaller.
+			if a := ringArea(sp); math.Abs(a-c.areas[i]) > 0.1 {
+				t.Errorf("%s: subpath %d has area %.3f, expected %.3f",
+					c.name, i, a, c.areas[i])
+			}
+		}
+	}
+}
+
+func TestSimplifyRing(t *testing.T) {
+	// A square with extra points on its sides.
+	pts := []point{
+		{0, 0}, {1, 0}, {2, 0.001}, {3, 0}, {3, 1}, {3, 3}, {1.5, 3},
+		{0, 3}, {0, 2},
+	}
+	got := simplifyRing(pts, 0.01)
+	expected := []point{{0, 0}, {3, 0}, {3, 3}, {0, 3}}
+	if len(got) != len(expected) {
+		t.Fatalf("got %v, expected %v", got, expected)
+	}
+	for i := range got {
+		if got[i] != expected[i] {
+			t.Errorf("got %v, expected %v", got, expected)
+			break
+		}
+	}
+}
+
+func TestOutlineSVG(t *testing.T) {
+	area := func(width float64) float64 {
+		t.Helper()
+		opts := Options{syllables: "manual", angle: defaultAngle,
+			sentences: "none",
+			outline:   outlineOptions{enabled: true, width: width}}
+		svg, err := genSVG([]string{"hi"}, opts, false)
+		if err != nil {
+			t.Fatalf("genSVG error: %v", err)
+		}
+		if n := strings.Count(svg, "<path "); n != 1 ||
+			strings.Contains(svg, "<use ") {
+			t.Errorf("expected a single path, got %d", n)
+		}
+
+		// It's a single filled shape, with the holes going the other way.
+		sc, err := parseScene(svg)
+		if err != nil {
+			t.Fatalf("parseScene error: %v", err)
+		}
+		if len(sc.shapes) != 1 || !sc.shapes[0].hasFill() ||
+			sc.shapes[0].hasStroke() {
+			t.Fatalf("unexpected scene: %+v", sc.shapes)
+		}
+		a := 0.0
+		for _, sp := range sc.shapes[0].subpaths {
+			a += ringArea(sp)
+		}
+		return a
+	}
+
+	// Wider strokes cover more area (but not twice as much, since the
+	// filled parts stay the same).
+	thin, thick := area(0), area(1)
+	if thick < thin*1.2 {
+		t.Errorf("areas %.2f and %.2f, expected the second to be larger",
+			thin, thick)
+	}
+
+	opts := Options{syllables: "manual", angle: defaultAngle,
+		sentences: "none", anim: animation{enabled: true},
+		outline: outlineOptions{enabled: true}}
+	if err := opts.check(); err == nil {
+		t.Errorf("animated outline did not fail")
+	}
+	opts.anim.enabled = false
+	opts.outline.width = 10
+	if err := opts.check(); err == nil {
+		t.Errorf("outline width out of range did not fail")
+	}
+}
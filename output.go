@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// # Output formats
+//
+// The words are always rendered to SVG first (see wordsToSVG). The other
+// formats are produced from it by an output backend, usually through the
+// scene (see parseScene).
+//
+// The backends are registered in outputBackends by name, which is also the
+// file extension, and are used by the command line (with -o) and by the
+// HTTP server alike.
+
+var (
+	outputFlag = flag.String("o", "",
+		"write the output to this file, instead of stdout; the format is "+
+			"taken from the extension ("+
+			strings.Join(outputFormats(), ", ")+")")
+	overwriteFlag = flag.Bool("overwrite", false,
+		"overwrite the output file if it already exists")
+)
+
+// An output backend, which renders a document in a specific format.
+type outputBackend interface {
+	// MIME type of the format, e.g. "image/png".
+	contentType() string
+
+	// write the document in this format.
+	write(w io.Writer, doc *document) error
+}
+
+// Registered output backends, by name (which is also the file extension).
+var outputBackends = map[string]outputBackend{
+	"svg":  svgBackend{},
+	"png":  pngBackend{},
+	"pdf":  pdfBackend{},
+	"gif":  gifBackend{},
+	"apng": apngBackend{},
+	"dxf":  dxfBackend{},
+	"stl":  stlBackend{},
+	"dst":  embroideryBackend{writeDST},
+	"pes":  embroideryBackend{writePES},
+	"tex":  tikzBackend{},
+}
+
+// outputFormats returns the names of the registered backends, sorted.
+func outputFormats() []string {
+	return slices.Sorted(maps.Keys(outputBackends))
+}
+
+// backendFor returns the backend for the given file name, based on its
+// extension.
+func backendFor(path string) (string, outputBackend, error) {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+	b, ok := outputBackends[ext]
+	if !ok {
+		return "", nil, fmt.Errorf(
+			"unknown output format %q, supported: %s",
+			ext, strings.Join(outputFormats(), ", "))
+	}
+	return ext, b, nil
+}
+
+// A rendered document, which the backends convert to their format.
+type document struct {
+	// The full SVG document.
+	svg string
+
+	// The scene, parsed from the SVG on demand (see scene).
+	sc *scene
+
+	// The words, and their SVG on its own (as returned by wordsToSVG,
+	// without outline or animation), for the backends that only draw the
+	// words, like gif. Its scene is parsed on demand (see wordsScene).
+	words         []string
+	wsvg          SVG
+	width, height int
+	wsc           *scene
+
+	opts Options
+}
+
+// renderDocument renders the words into a document.
+func renderDocument(words []string, opts Options, grid bool) (*document, error) {
+	wsvg, width, height, err := wordsToSVG(words, opts)
+	if err != nil {
+		return nil, err
+	}
+	doc := &document{words: words, wsvg: wsvg, width: width,
+		height: height, opts: opts}
+
+	if opts.outline.enabled {
+		wsvg, err = outlineSVG(wsvg, width, height, opts)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if opts.anim.enabled {
+		wsvg, err = animateSVG(wsvg, width, height, opts.anim)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	buf.WriteString(string(svgHeader(width, height)))
+
+	writeDefs(buf)
+	st := opts.style.resolved()
+	buf.WriteString(string(st.effectDefs()))
+	buf.WriteString(string(st.backgroundSVG()))
+
+	if grid {
+		buf.WriteString(string(svgGrid(width, height)))
+	}
+
+	buf.WriteString(string(st.withEffect(wsvg)))
+	buf.WriteString("</svg>\n")
+
+	doc.svg = buf.String()
+	return doc, nil
+}
+
+// scene returns the scene of the document, parsing it if needed.
+func (d *document) scene() (*scene, error) {
+	if d.sc == nil {
+		sc, err := parseScene(d.svg)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing the SVG: %v", err)
+		}
+		d.sc = sc
+	}
+	return d.sc, nil
+}
+
+// wordsScene returns the scene of the words on their own, parsing it if
+// needed.
+func (d *document) wordsScene() (*scene, error) {
+	if d.wsc == nil {
+		sc, err := wordsScene(d.wsvg, d.width, d.height)
+		if err != nil {
+			return nil, err
+		}
+		d.wsc = sc
+	}
+	return d.wsc, nil
+}
+
+// svgBackend writes the SVG as is.
+type svgBackend struct{}
+
+func (svgBackend) contentType() string {
+	return "image/svg+xml"
+}
+
+func (svgBackend) write(w io.Writer, doc *document) error {
+	_, err := io.WriteString(w, doc.svg)
+	return err
+}
+
+// writeOutput renders the document with the backend for the path, and
+// writes it to the file (see writeFileAtomic).
+func writeOutput(path string, doc *document, overwrite bool) error {
+	_, backend, err := backendFor(path)
+	if err != nil {
+		return err
+	}
+	buf := &bytes.Buffer{}
+	if err := backend.write(buf, doc); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, buf.Bytes(), overwrite)
+}
+
+// writeDocument writes the document to the output file given with -o, in
+// the format of its extension, or to w in the given format. It's for the
+// commands that have a format of their own, like "gif".
+func writeDocument(w io.Writer, doc *document, format string) error {
+	if *outputFlag != "" {
+		return writeOutput(*outputFlag, doc, *overwriteFlag)
+	}
+	return outputBackends[format].write(w, doc)
+}
+
+var errExists = errors.New("file already exists (use -overwrite)")
+
+// createTemp creates a new temporary file next to the path, to write it
+// and then move it into place. Unlike os.CreateTemp, which makes it only
+// readable by the user, the mode is 0666 minus the umask, like for any
+// other new file.
+func createTemp(path string) (*os.File, error) {
+	dir, base := filepath.Dir(path), filepath.Base(path)
+	for range 1000 {
+		name := filepath.Join(dir,
+			fmt.Sprintf(".%s.%d.tmp", base, rand.Uint32()))
+		f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL,
+			0o666)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		return f, err
+	}
+	return nil, fmt.Errorf("%s: can't create a temporary file", path)
+}
+
+// writeFileAtomic writes the data to the file, so that it either has the new
+// content, or the old one (if any), but never a partial write: we write to
+// a temporary file in the same directory, and then move it into place.
+// If overwrite is false and the file already exists, it returns an error.
+func writeFileAtomic(path string, data []byte, overwrite bool) error {
+	tmp, err := createTemp(path)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	if overwrite {
+		return os.Rename(tmp.Name(), path)
+	}
+
+	// Linking fails if the file exists, so there's no race between checking
+	// and writing. Some filesystems don't support links, in which case we
+	// check and rename instead.
+	err = os.Link(tmp.Name(), path)
+	if errors.Is(err, fs.ErrExist) {
+		return fmt.Errorf("%s: %w", path, errExists)
+	}
+	if err != nil {
+		if _, serr := os.Lstat(path); serr == nil {
+			return fmt.Errorf("%s: %w", path, errExists)
+		}
+		return os.Rename(tmp.Name(), path)
+	}
+	return nil
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseColor(t *testing.T) {
+	current := color.NRGBA{1, 2, 3, 255}
+	cases := []struct {
+		s        string
+		expected color.NRGBA
+		ok       bool
+	}{
+		{"orange", color.NRGBA{255, 165, 0, 255}, true},
+		{"#f80", color.NRGBA{255, 136, 0, 255}, true},
+		{"#ff880080", color.NRGBA{255, 136, 0, 128}, true},
+		{"currentcolor", current, true},
+		{"none", color.NRGBA{}, false},
+		{"transparent", color.NRGBA{}, false},
+		{"#ff880000", color.NRGBA{}, false},
+		{"#12", color.NRGBA{}, false},
+		{"notacolor", color.NRGBA{}, false},
+	}
+	for _, c := range cases {
+		got, ok := parseColor(c.s, current)
+		if ok != c.ok || (ok && got != c.expected) {
+			t.Errorf("parseColor(%q) = %v, %v; expected %v, %v",
+				c.s, got, ok, c.expected, c.ok)
+		}
+	}
+}
+
+func TestParseTransform(t *testing.T) {
+	cases := []struct {
+		s        string
+		p        point
+		expected point
+	}{
+		{"", point{1, 2}, point{1, 2}},
+		{"translate(10 5)", point{1, 2}, point{11, 7}},
+		{"translate(10)", point{1, 2}, point{11, 2}},
+		{"scale(2)", point{1, 2}, point{2, 4}},
+		{"rotate(90)", point{1, 0}, point{0, 1}},
+		{"rotate(180, 1, 1)", point{0, 0}, point{2, 2}},
+		{"translate(1,1) scale(2)", point{1, 2}, point{3, 5}},
+		{"matrix(1 0 0 1 3 4)", point{1, 2}, point{4, 6}},
+	}
+	for _, c := range cases {
+		tr, err := parseTransform(c.s)
+		if err != nil {
+			t.Errorf("parseTransform(%q) error: %v", c.s, err)
+			continue
+		}
+		got := tr.apply(c.p)
+		if math.Abs(got.x-c.expected.x) > 1e-9 ||
+			math.Abs(got.y-c.expected.y) > 1e-9 {
+			t.Errorf("%q applied to %v = %v, expected %v",
+				c.s, c.p, got, c.expected)
+		}
+	}
+
+	for _, s := range []string{"skewX(10)", "translate(a)", "scale("} {
+		if _, err := parseTransform(s); err == nil {
+			t.Errorf("parseTransform(%q) did not fail", s)
+		}
+	}
+}
+
+func TestParsePathData(t *testing.T) {
+	cases := []struct {
+		d        string
+		expected []subpath
+	}{
+		{"M 0 0 L 1 1", []subpath{
+			{points: []point{{0, 0}, {1, 1}}}}},
+		{"M0,0 h2 v2 H0 z", []subpath{
+			{points: []point{{0, 0}, {2, 0}, {2, 2}, {0, 2}}, closed: true}}},
+		{"m 1 1 2 0 m 0 1 l -2 0", []subpath{
+			{points: []point{{1, 1}, {3, 1}}},
+			{points: []point{{3, 2}, {1, 2}}}}},
+	}
+	for _, c := range cases {
+		got, err := parsePathData(c.d)
+		if err != nil {
+			t.Errorf("parsePathData(%q) error: %v", c.d, err)
+			continue
+		}
+		diff := cmp.Diff(c.expected, got,
+			cmp.AllowUnexported(subpath{}, point{}))
+		if diff != "" {
+			t.Errorf("parsePathData(%q) (-want +got):\n%s", c.d, diff)
+		}
+	}
+
+	if _, err := parsePathData("M 0 0 C 1 1 2 2 3 3"); err == nil {
+		t.Errorf("unsupported command did not fail")
+	}
+}
+
+func TestParseScene(t *testing.T) {
+	svg := `<svg viewBox="-10 -10 20 20">
+  <defs><g id="glyph:g1"><line x1="0" y1="0" x2="1" y2="0" /></g></defs>
+  <g color="orange" stroke="currentcolor" stroke-width="0.5">
+    <use href="#glyph:g1" transform="translate(1 2)" />
+    <g class="word-line"><circle cx="0" cy="0" r="1" fill="none" /></g>
+  </g>
+</svg>`
+	sc, err := parseScene(svg)
+	if err != nil {
+		t.Fatalf("parseScene error: %v", err)
+	}
+	if sc.width != 20 || sc.height != 20 || len(sc.shapes) != 2 {
+		t.Fatalf("unexpected scene: %+v", sc)
+	}
+
+	orange := color.NRGBA{255, 165, 0, 255}
+	line, circle := sc.shapes[0], sc.shapes[1]
+	expected := []point{{11, 12}, {12, 12}}
+	if line.glyph != "g1" || line.stroke != orange ||
+		line.strokeWidth != 0.5 ||
+		!cmp.Equal(line.subpaths[0].points, expected,
+			cmp.AllowUnexported(point{})) {
+		t.Errorf("unexpected line: %+v", line)
+	}
+	if !circle.circle || circle.center != (point{10, 10}) ||
+		circle.hasFill() || !circle.hasStroke() ||
+		circle.class != "word-line" || line.class != "" {
+		t.Errorf("unexpected circle: %+v", circle)
+	}
+
+	if _, err := parseScene(`<svg></svg>`); err == nil {
+		t.Errorf("SVG without viewBox did not fail")
+	}
+}
+
+func TestOutputBackends(t *testing.T) {
+	doc, err := renderDocument([]string{"hello"}, Options{
+		syllables: "manual", angle: defaultAngle, sentences: "none",
+	}, false)
+	if err != nil {
+		t.Fatalf("renderDocument error: %v", err)
+	}
+
+	for _, name := range outputFormats() {
+		buf := &bytes.Buffer{}
+		if err := outputBackends[name].write(buf, doc); err != nil {
+			t.Errorf("%s: error: %v", name, err)
+			continue
+		}
+		out := buf.String()
+		switch name {
+		case "svg":
+			if out != doc.svg {
+				t.Errorf("svg: output is not the document's SVG")
+			}
+		case "png":
+			img, err := png.Decode(buf)
+			if err != nil {
+				t.Errorf("png: error decoding: %v", err)
+				continue
+			}
+			b := img.Bounds()
+			sc, _ := doc.scene()
+			if b.Dx() != int(math.Ceil(sc.width*rasterScale)) {
+				t.Errorf("png: unexpected size %v", b)
+			}
+		case "pdf":
+			if !strings.HasPrefix(out, "%PDF-1.4\n") ||
+				!strings.HasSuffix(out, "%%EOF\n") {
+				t.Errorf("pdf: unexpected header or trailer: %q", out)
+			}
+		case "gif":
+			if _, err := gif.DecodeAll(buf); err != nil {
+				t.Errorf("gif: error decoding: %v", err)
+			}
+		case "apng":
+			if !strings.Contains(out, "acTL") {
+				t.Errorf("apng: no animation control chunk")
+			}
+		case "dxf":
+			if !strings.HasPrefix(out, "0\nSECTION\n") ||
+				!strings.HasSuffix(out, "0\nEOF\n") {
+				t.Errorf("dxf: unexpected start or end: %q", out)
+			}
+		case "dst":
+			if !strings.HasPrefix(out, "LA:") || len(out) < 512 {
+				t.Errorf("dst: unexpected header: %q", out)
+			}
+		case "pes":
+			if !strings.HasPrefix(out, "#PES") {
+				t.Errorf("pes: unexpected header: %q", out)
+			}
+		case "tex":
+			if !strings.Contains(out, "\\begin{tikzpicture}") {
+				t.Errorf("tex: no tikzpicture: %q", out)
+			}
+		case "stl":
+			// Binary: the header, the number of triangles, and 50
+			// bytes for each.
+			if buf.Len() < 84 {
+				t.Errorf("stl: too short: %q", out)
+				continue
+			}
+			n := binary.LittleEndian.Uint32(buf.Bytes()[80:])
+			if n == 0 || buf.Len() != 84+50*int(n) {
+				t.Errorf("stl: %d bytes, for %d triangles",
+					buf.Len(), n)
+			}
+		}
+	}
+}
+
+func TestBackendFor(t *testing.T) {
+	for path, expected := range map[string]string{
+		"a.svg": "svg", "dir/b.PNG": "png", "c.pdf": "pdf",
+		"d.gif": "gif", "e.apng": "apng", "f.dxf": "dxf",
+		"g.stl": "stl", "h.dst": "dst", "i.PES": "pes",
+		"j.tex": "tex",
+	} {
+		ext, _, err := backendFor(path)
+		if err != nil || ext != expected {
+			t.Errorf("backendFor(%q) = %q, %v; expected %q",
+				path, ext, err, expected)
+		}
+	}
+	for _, path := range []string{"a.txt", "noext", ""} {
+		if _, _, err := backendFor(path); err == nil {
+			t.Errorf("backendFor(%q) did not fail", path)
+		}
+	}
+}
+
+// An animated document is written as an animated PNG by the png backend.
+func TestPNGBackendAnimated(t *testing.T) {
+	opts := Options{syllables: "manual", angle: defaultAngle,
+		sentences: "none"}
+	opts.anim.enabled = true
+	doc, err := renderDocument([]string{"hi"}, opts, false)
+	if err != nil {
+		t.Fatalf("renderDocument error: %v", err)
+	}
+	buf := &bytes.Buffer{}
+	if err := outputBackends["png"].write(buf, doc); err != nil {
+		t.Fatalf("png error: %v", err)
+	}
+	if !strings.Contains(buf.String(), "acTL") {
+		t.Errorf("animated document did not give an animated PNG")
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.svg")
+
+	if err := writeFileAtomic(path, []byte("one"), false); err != nil {
+		t.Fatalf("first write error: %v", err)
+	}
+	err := writeFileAtomic(path, []byte("two"), false)
+	if !errors.Is(err, errExists) {
+		t.Errorf("second write without overwrite: %v", err)
+	}
+	expectFile(t, path, "one")
+
+	if err := writeFileAtomic(path, []byte("three"), true); err != nil {
+		t.Errorf("write with overwrite error: %v", err)
+	}
+	expectFile(t, path, "three")
+
+	// No temporary files should be left behind.
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("unexpected files in %s: %v", dir, entries)
+	}
+}
+
+func expectFile(t *testing.T, path, expected string) {
+	t.Helper()
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("error reading %s: %v", path, err)
+	}
+	if string(got) != expected {
+		t.Errorf("%s: got %q, expected %q", path, got, expected)
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// # PDF output
+//
+// A minimal PDF writer: a single page, of the same size as the SVG, with
+// the shapes of the scene as vector paths. There are no fonts, images or
+// compression, which keeps it simple and the output reproducible.
+
+// Points per SVG user unit (millimetre).
+const pdfScale = 72 / 25.4
+
+// Control point distance for approximating a quarter of a circle with a
+// cubic Bézier curve.
+const bezierCircle = 0.5522847498
+
+// pdfBackend renders the scene to a PDF document.
+type pdfBackend struct{}
+
+func (pdfBackend) contentType() string {
+	return "application/pdf"
+}
+
+func (pdfBackend) write(w io.Writer, doc *document) error {
+	sc, err := doc.scene()
+	if err != nil {
+		return err
+	}
+
+	content := &bytes.Buffer{}
+	pdfContent(content, sc)
+
+	width := pdfNum(sc.width * pdfScale)
+	height := pdfNum(sc.height * pdfScale)
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 " + width + " " +
+			height + "] /Contents 4 0 R /Resources << >> >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream",
+			content.Len(), content),
+	}
+
+	buf := &bytes.Buffer{}
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := []int{}
+	for i, obj := range objects {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(buf, "trailer\n<< /Size %d /Root 1 0 R >>\n", len(objects)+1)
+	fmt.Fprintf(buf, "startxref\n%d\n%%%%EOF\n", xref)
+
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// pdfContent writes the content stream for the scene.
+func pdfContent(w io.Writer, sc *scene) {
+	// PDF's origin is at the bottom left, and its unit is the point, so we
+	// flip and scale to use the SVG coordinates directly.
+	fmt.Fprintf(w, "%s 0 0 %s 0 %s cm\n",
+		pdfNum(pdfScale), pdfNum(-pdfScale), pdfNum(sc.height*pdfScale))
+	fmt.Fprintf(w, "%d M\n", miterLimit)
+
+	for _, s := range sc.shapes {
+		fill, stroke := s.hasFill(), s.hasStroke()
+		if !fill && !stroke {
+			continue
+		}
+		if fill {
+			fmt.Fprintf(w, "%s rg\n", pdfColor(s.fill))
+		}
+		if stroke {
+			fmt.Fprintf(w, "%s RG %s w %d J %d j\n",
+				pdfColor(s.stroke), pdfNum(s.strokeWidth),
+				pdfLineCaps[s.linecap], pdfLineJoins[s.linejoin])
+		}
+
+		if s.circle {
+			pdfCircle(w, s.center, s.r)
+		} else {
+			for _, sp := range s.subpaths {
+				for i, p := range sp.points {
+					op := "l"
+					if i == 0 {
+						op = "m"
+					}
+					fmt.Fprintf(w, "%s %s %s\n", pdfNum(p.x), pdfNum(p.y), op)
+				}
+				if sp.closed {
+					fmt.Fprintln(w, "h")
+				}
+			}
+		}
+
+		switch {
+		case fill && stroke:
+			fmt.Fprintln(w, "B")
+		case fill:
+			fmt.Fprintln(w, "f")
+		default:
+			fmt.Fprintln(w, "S")
+		}
+	}
+}
+
+// PDF line cap and join styles, by their SVG name. Unknown names map to 0,
+// which are the SVG defaults (butt, and miter).
+var (
+	pdfLineCaps  = map[string]int{"butt": 0, "round": 1, "square": 2}
+	pdfLineJoins = map[string]int{"miter": 0, "round": 1, "bevel": 2}
+)
+
+// pdfCircle writes a circle path, as four Bézier curves.
+func pdfCircle(w io.Writer, c point, r float64) {
+	k := r * bezierCircle
+	n := func(v float64) string { return pdfNum(v) }
+	fmt.Fprintf(w, "%s %s m\n", n(c.x+r), n(c.y))
+	fmt.Fprintf(w, "%s %s %s %s %s %s c\n",
+		n(c.x+r), n(c.y+k), n(c.x+k), n(c.y+r), n(c.x), n(c.y+r))
+	fmt.Fprintf(w, "%s %s %s %s %s %s c\n",
+		n(c.x-k), n(c.y+r), n(c.x-r), n(c.y+k), n(c.x-r), n(c.y))
+	fmt.Fprintf(w, "%s %s %s %s %s %s c\n",
+		n(c.x-r), n(c.y-k), n(c.x-k), n(c.y-r), n(c.x), n(c.y-r))
+	fmt.Fprintf(w, "%s %s %s %s %s %s c\n",
+		n(c.x+k), n(c.y-r), n(c.x+r), n(c.y-k), n(c.x+r), n(c.y))
+	fmt.Fprintln(w, "h")
+}
+
+// pdfColor returns the color as PDF RGB components.
+func pdfColor(c color.NRGBA) string {
+	return pdfNum(float64(c.R)/255) + " " + pdfNum(float64(c.G)/255) + " " +
+		pdfNum(float64(c.B)/255)
+}
+
+// pdfNum formats the number for PDF, which doesn't support exponents.
+func pdfNum(v float64) string {
+	s := strconv.FormatFloat(v, 'f', 4, 64)
+	s = trimZeros(s)
+	if s == "-0" {
+		s = "0"
+	}
+	return s
+}
+
+// trimZeros removes the trailing zeros of a decimal number (and the point,
+// if there's nothing after it).
+func trimZeros(s string) string {
+	if !strings.Contains(s, ".") {
+		return s
+	}
+	for s[len(s)-1] == '0' {
+		s = s[:len(s)-1]
+	}
+	if s[len(s)-1] == '.' {
+		s = s[:len(s)-1]
+	}
+	return s
+}
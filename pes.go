@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image/color"
+	"math"
+)
+
+// # PES
+//
+// Brother machines use PES files, which have two sections: the PES one,
+// with the design as the editing software sees it, and the PEC one, with
+// the stitches, the threads and thumbnails, which is what the machines
+// read. We write a version 1 file with only the PEC section (the PES one
+// is empty), like other free tools do.
+//
+// The PEC section is:
+//
+//   - A 512 byte header, with the label, the size of the thumbnails and the
+//     threads (as indexes in the Brother palette, see pecThreads).
+//   - The stitch block: its length, the size of the design, and the
+//     stitches, as relative moves in units of 0.1 mm. Short moves take 1
+//     byte per axis, long ones (and jumps) take 2.
+//   - The thumbnails, 48x38 pixels and 1 bit per pixel: one of the whole
+//     design, and one for each thread.
+
+// Size of the thumbnails, in pixels.
+const (
+	pecIconWidth  = 48
+	pecIconHeight = 38
+)
+
+// Flags of the long form of the PEC moves.
+const (
+	pecLong = 0x8000
+	pecJump = 0x1000
+	pecTrim = 0x2000
+)
+
+// writePES writes the pattern as a PES file.
+func writePES(buf *bytes.Buffer, pat *stitchPattern, label string) {
+	buf.WriteString("#PES0001")
+	// Where the PEC section starts: right after this header.
+	buf.Write(binary.LittleEndian.AppendUint32(nil, 22))
+	buf.Write(make([]byte, 10))
+	writePEC(buf, pat, label)
+}
+
+func writePEC(buf *bytes.Buffer, pat *stitchPattern, label string) {
+	start := buf.Len()
+	fmt.Fprintf(buf, "LA:%-16s\r", label)
+	buf.Write(bytes.Repeat([]byte(" "), 12))
+	buf.Write([]byte{0xff, 0x00, pecIconWidth / 8, pecIconHeight})
+	buf.Write(bytes.Repeat([]byte(" "), 12))
+	buf.WriteByte(byte(len(pat.colors) - 1))
+	for _, c := range pat.colors {
+		buf.WriteByte(pecThread(c))
+	}
+	for buf.Len()-start < 512 {
+		buf.WriteByte(' ')
+	}
+
+	// The stitch block, with its length (counting from its start) filled
+	// in at the end.
+	block := buf.Len()
+	buf.Write([]byte{0, 0, 0, 0, 0, 0x31, 0xff, 0xf0})
+	lo, hi := pat.bounds()
+	le16 := func(v int) {
+		buf.Write(binary.LittleEndian.AppendUint16(nil, uint16(v)))
+	}
+	be16 := func(v int) {
+		buf.Write(binary.BigEndian.AppendUint16(nil, uint16(v)))
+	}
+	le16(hi.X - lo.X)
+	le16(hi.Y - lo.Y)
+	le16(0x1e0)
+	le16(0x1b0)
+	// The position of the origin relative to the top left corner, as a
+	// long jump.
+	be16(pecLong | pecJump | (-lo.X & 0xfff))
+	be16(pecLong | pecJump | (-lo.Y & 0xfff))
+
+	x, y := 0, 0
+	trimmed := false
+	stop := byte(2)
+	for _, s := range pat.stitches {
+		dx, dy := s.x-x, s.y-y
+		if s.kind == stitchSew || s.kind == stitchJump {
+			x, y = s.x, s.y
+		}
+		switch s.kind {
+		case stitchSew:
+			for _, m := range splitMove(dx, dy, 0x7ff) {
+				if m[0] > -64 && m[0] < 63 && m[1] > -64 && m[1] < 63 {
+					buf.Write([]byte{byte(m[0]) & 0x7f, byte(m[1]) & 0x7f})
+				} else {
+					be16(pecLong | (m[0] & 0xfff))
+					be16(pecLong | (m[1] & 0xfff))
+				}
+			}
+		case stitchJump:
+			flag := pecJump
+			if trimmed {
+				flag = pecTrim
+			}
+			for _, m := range splitMove(dx, dy, 0x7ff) {
+				be16(pecLong | flag | (m[0] & 0xfff))
+				be16(pecLong | flag | (m[1] & 0xfff))
+			}
+		case stitchColor:
+			// The stop code alternates between 2 and 1.
+			buf.Write([]byte{0xfe, 0xb0, stop})
+			stop = 3 - stop
+		}
+		trimmed = s.kind == stitchTrim
+	}
+	buf.Write([]byte{0xff, 0x00}) // End.
+
+	n := buf.Len() - block
+	b := buf.Bytes()
+	b[block+2], b[block+3], b[block+4] = byte(n), byte(n>>8), byte(n>>16)
+
+	for _, icon := range pecIcons(pat) {
+		buf.Write(icon)
+	}
+}
+
+// pecIcons returns the thumbnails: the whole design, and the stitches of
+// each thread. Each row of pixels is 6 bytes, with the first pixel in the
+// lowest bit.
+func pecIcons(pat *stitchPattern) [][]byte {
+	icons := make([][]byte, 1+len(pat.colors))
+	for i := range icons {
+		icons[i] = make([]byte, pecIconWidth/8*pecIconHeight)
+	}
+
+	// Fit the design inside the icon, with a margin.
+	const margin = 3
+	lo, hi := pat.bounds()
+	scale := min(
+		float64(pecIconWidth-2*margin-1)/float64(max(hi.X-lo.X, 1)),
+		float64(pecIconHeight-2*margin-1)/float64(max(hi.Y-lo.Y, 1)))
+	set := func(icon []byte, x, y int) {
+		px := margin + int(float64(x-lo.X)*scale+0.5)
+		py := margin + int(float64(y-lo.Y)*scale+0.5)
+		icon[py*pecIconWidth/8+px/8] |= 1 << (px % 8)
+	}
+
+	block := 1
+	prev := stitch{kind: stitchJump}
+	for _, s := range pat.stitches {
+		if s.kind == stitchColor {
+			block++
+		}
+		if s.kind == stitchSew && prev.kind == stitchSew {
+			// Draw the stitch, with enough points to fill the line.
+			n := max(abs(s.x-prev.x), abs(s.y-prev.y))*int(scale+1) + 1
+			for k := 0; k <= n; k++ {
+				x := prev.x + (s.x-prev.x)*k/n
+				y := prev.y + (s.y-prev.y)*k/n
+				set(icons[0], x, y)
+				set(icons[block], x, y)
+			}
+		}
+		prev = s
+	}
+	return icons
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// The threads of the Brother palette, by their index.
+var pecThreads = []color.RGBA{
+	1:  {14, 31, 124, 255},   // Prussian blue.
+	2:  {10, 85, 163, 255},   // Blue.
+	3:  {0, 135, 119, 255},   // Teal green.
+	4:  {75, 107, 175, 255},  // Cornflower blue.
+	5:  {237, 23, 31, 255},   // Red.
+	6:  {209, 92, 0, 255},    // Reddish brown.
+	7:  {145, 54, 151, 255},  // Magenta.
+	8:  {228, 154, 203, 255}, // Light lilac.
+	9:  {145, 95, 172, 255},  // Lilac.
+	10: {158, 214, 125, 255}, // Mint green.
+	11: {232, 169, 0, 255},   // Deep gold.
+	12: {254, 186, 53, 255},  // Orange.
+	13: {255, 255, 0, 255},   // Yellow.
+	14: {112, 188, 31, 255},  // Lime green.
+	15: {186, 152, 0, 255},   // Brass.
+	16: {168, 168, 168, 255}, // Silver.
+	17: {125, 111, 0, 255},   // Russet brown.
+	18: {255, 255, 179, 255}, // Cream brown.
+	19: {79, 85, 86, 255},    // Pewter.
+	20: {0, 0, 0, 255},       // Black.
+	21: {11, 61, 145, 255},   // Ultramarine.
+	22: {119, 1, 118, 255},   // Royal purple.
+	23: {41, 49, 51, 255},    // Dark gray.
+	24: {42, 19, 1, 255},     // Dark brown.
+	25: {246, 74, 138, 255},  // Deep rose.
+	26: {178, 118, 36, 255},  // Light brown.
+	27: {252, 187, 197, 255}, // Salmon pink.
+	28: {254, 55, 15, 255},   // Vermilion.
+	29: {240, 240, 240, 255}, // White.
+	30: {106, 28, 138, 255},  // Violet.
+	31: {168, 221, 196, 255}, // Seacrest.
+	32: {37, 132, 187, 255},  // Sky blue.
+	33: {254, 179, 67, 255},  // Pumpkin.
+	34: {255, 243, 107, 255}, // Cream yellow.
+	35: {208, 166, 96, 255},  // Khaki.
+	36: {209, 84, 0, 255},    // Clay brown.
+	37: {102, 186, 73, 255},  // Leaf green.
+	38: {19, 74, 70, 255},    // Peacock blue.
+	39: {135, 135, 135, 255}, // Gray.
+	40: {216, 204, 198, 255}, // Warm gray.
+	41: {67, 86, 7, 255},     // Dark olive.
+	42: {253, 217, 222, 255}, // Flesh pink.
+	43: {249, 147, 188, 255}, // Pink.
+	44: {0, 56, 34, 255},     // Deep green.
+	45: {178, 175, 212, 255}, // Lavender.
+	46: {104, 106, 176, 255}, // Wisteria violet.
+	47: {239, 227, 185, 255}, // Beige.
+	48: {247, 56, 102, 255},  // Carmine.
+	49: {181, 75, 100, 255},  // Amber red.
+	50: {19, 43, 26, 255},    // Olive green.
+	51: {199, 1, 86, 255},    // Dark fuchsia.
+	52: {254, 158, 50, 255},  // Tangerine.
+	53: {168, 222, 235, 255}, // Light blue.
+	54: {0, 103, 62, 255},    // Emerald green.
+	55: {78, 41, 144, 255},   // Purple.
+	56: {47, 126, 32, 255},   // Moss green.
+	57: {255, 204, 204, 255}, // Flesh pink.
+	58: {255, 217, 17, 255},  // Harvest gold.
+	59: {9, 91, 166, 255},    // Electric blue.
+	60: {240, 249, 112, 255}, // Lemon yellow.
+	61: {227, 243, 91, 255},  // Fresh green.
+	62: {255, 153, 0, 255},   // Orange.
+	63: {255, 240, 141, 255}, // Cream yellow.
+}
+
+// pecThread returns the index of the thread of the Brother palette that's
+// closest to the color.
+func pecThread(c color.NRGBA) byte {
+	best, bestDist := 0, math.MaxInt
+	for i, t := range pecThreads {
+		if i == 0 {
+			continue
+		}
+		dr, dg, db := int(c.R)-int(t.R), int(c.G)-int(t.G), int(c.B)-int(t.B)
+		if d := dr*dr + dg*dg + db*db; d < bestDist {
+			best, bestDist = i, d
+		}
+	}
+	return byte(best)
+}
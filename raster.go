@@ -0,0 +1,297 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+)
+
+// # Rasterizer
+//
+// A small anti-aliased rasterizer for the scene, used for the PNG output
+// (and anything else that needs pixels).
+//
+// Strokes with round joins and caps are drawn by looking at the distance
+// from each pixel to the segments. The other strokes, and the fills, are
+// supersampled. This is simple, and good enough for the shapes we have.
+
+// Pixels per SVG user unit (millimetre), for the PNG output.
+// 10 is about 254 DPI.
+const rasterScale = 10
+
+// Samples per pixel side, for the fills (and some strokes).
+const fillSamples = 4
+
+// pngBackend renders the scene to a PNG image, or animated documents to an
+// animated PNG (see apngBackend).
+type pngBackend struct{}
+
+func (pngBackend) contentType() string {
+	return "image/png"
+}
+
+func (pngBackend) write(w io.Writer, doc *document) error {
+	if doc.opts.anim.enabled {
+		return apngBackend{}.write(w, doc)
+	}
+	sc, err := doc.scene()
+	if err != nil {
+		return err
+	}
+	img := rasterize(sc, rasterScale, color.NRGBA{})
+	return png.Encode(w, img)
+}
+
+// rasterize draws the scene on a new image, with the given scale (pixels per
+// unit) and background color.
+func rasterize(sc *scene, scale float64, bg color.NRGBA) *image.RGBA {
+	w := int(math.Ceil(sc.width * scale))
+	h := int(math.Ceil(sc.height * scale))
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	if bg.A > 0 {
+		draw.Draw(img, img.Bounds(), image.NewUniform(bg), image.Point{},
+			draw.Src)
+	}
+
+	for _, s := range sc.shapes {
+		drawShape(img, s, scale)
+	}
+	return img
+}
+
+// drawShape draws the shape on the image: first the fill, and then the
+// stroke, like SVG does.
+func drawShape(img *image.RGBA, s shape, scale float64) {
+	// Work in pixel coordinates.
+	s = scaleShape(s, scale)
+
+	min, max := s.bounds()
+	r := image.Rect(
+		int(math.Floor(min.x))-1, int(math.Floor(min.y))-1,
+		int(math.Ceil(max.x))+1, int(math.Ceil(max.y))+1,
+	).Intersect(img.Bounds())
+	if r.Empty() {
+		return
+	}
+
+	if s.hasFill() {
+		paint(img, r, s.fill, fillCoverage(s))
+	}
+	if s.hasStroke() {
+		paint(img, r, s.stroke, strokeCoverage(s))
+	}
+}
+
+// paint the color over the image, within r, with the coverage given by the
+// function for each pixel (at its top-left corner).
+func paint(img *image.RGBA, r image.Rectangle, c color.NRGBA,
+	coverage func(x, y float64) float64) {
+	mask := image.NewAlpha(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			cov := coverage(float64(x), float64(y))
+			mask.SetAlpha(x, y, color.Alpha{uint8(math.Round(cov * 255))})
+		}
+	}
+	draw.DrawMask(img, r, image.NewUniform(c), image.Point{},
+		mask, r.Min, draw.Over)
+}
+
+// scaleShape returns a copy of the shape, scaled.
+func scaleShape(s shape, scale float64) shape {
+	sps := make([]subpath, len(s.subpaths))
+	for i, sp := range s.subpaths {
+		sps[i] = subpath{closed: sp.closed}
+		for _, p := range sp.points {
+			sps[i].points = append(sps[i].points,
+				point{p.x * scale, p.y * scale})
+		}
+	}
+	s.subpaths = sps
+	s.center = point{s.center.x * scale, s.center.y * scale}
+	s.r *= scale
+	s.strokeWidth *= scale
+	return s
+}
+
+// fillCoverage returns a function that tells the fraction of the pixel with
+// its top-left corner at (x, y) that is inside the shape.
+func fillCoverage(s shape) func(x, y float64) float64 {
+	if s.circle {
+		return func(x, y float64) float64 {
+			d := math.Hypot(x+0.5-s.center.x, y+0.5-s.center.y)
+			return clamp01(s.r - d + 0.5)
+		}
+	}
+
+	// The samples are at the same heights for all the pixels of a row, so
+	// for each of those heights we find where the edges cross it (once),
+	// and then the winding number of each sample is the sum of the
+	// directions of the crossings to its right (see windingNumber). This
+	// matters for the shapes with many edges, like outlines.
+	type crossing struct {
+		x   float64
+		dir int
+	}
+	rows := map[float64][]crossing{}
+	crossings := func(py float64) []crossing {
+		if cs, ok := rows[py]; ok {
+			return cs
+		}
+		cs := []crossing{}
+		for _, sp := range s.subpaths {
+			pts := sp.points
+			for i := range pts {
+				a, b := pts[i], pts[(i+1)%len(pts)]
+				dir := 0
+				if a.y <= py && b.y > py {
+					dir = 1
+				} else if b.y <= py && a.y > py {
+					dir = -1
+				}
+				if dir != 0 {
+					x := a.x + (py-a.y)*(b.x-a.x)/(b.y-a.y)
+					cs = append(cs, crossing{x, dir})
+				}
+			}
+		}
+		rows[py] = cs
+		return cs
+	}
+
+	return supersampleFunc(func(p point) bool {
+		wn := 0
+		for _, c := range crossings(p.y) {
+			if c.x > p.x {
+				wn += c.dir
+			}
+		}
+		return wn != 0
+	})
+}
+
+// supersampleFunc returns a coverage function that supersamples the pixels
+// with the given function.
+func supersampleFunc(inside func(p point) bool) func(x, y float64) float64 {
+	return func(x, y float64) float64 {
+		return supersample(x, y, inside)
+	}
+}
+
+// supersample returns the fraction of the samples of the pixel with its
+// top-left corner at (x, y) that are inside, according to the function.
+func supersample(x, y float64, inside func(p point) bool) float64 {
+	n := 0
+	for i := range fillSamples {
+		for j := range fillSamples {
+			p := point{
+				x + (float64(i)+0.5)/fillSamples,
+				y + (float64(j)+0.5)/fillSamples,
+			}
+			if inside(p) {
+				n++
+			}
+		}
+	}
+	return float64(n) / (fillSamples * fillSamples)
+}
+
+// strokeCoverage returns a function that tells how much the pixel with its
+// top-left corner at (x, y) is covered by the stroke of the shape.
+func strokeCoverage(s shape) func(x, y float64) float64 {
+	hw := s.strokeWidth / 2
+	if s.circle {
+		return func(x, y float64) float64 {
+			p := point{x + 0.5, y + 0.5}
+			d := math.Abs(math.Hypot(p.x-s.center.x, p.y-s.center.y) - s.r)
+			return clamp01(hw - d + 0.5)
+		}
+	}
+
+	// With round caps and joins, the stroke is everything within hw of the
+	// segments, so we can use the distance for a smooth edge.
+	if s.linecap == "round" && s.linejoin == "round" {
+		return func(x, y float64) float64 {
+			p := point{x + 0.5, y + 0.5}
+			d := math.Inf(1)
+			for _, sp := range s.subpaths {
+				segments(sp, func(a, b point) {
+					d = math.Min(d, segmentDistance(p, a, b))
+				})
+			}
+			return clamp01(hw - d + 0.5)
+		}
+	}
+
+	// Otherwise, we supersample the parts of the stroke.
+	polys, disks := s.strokeParts()
+	inside := func(p point) bool {
+		for _, d := range disks {
+			if math.Hypot(p.x-d.center.x, p.y-d.center.y) <= d.r {
+				return true
+			}
+		}
+		for _, poly := range polys {
+			if windingNumber([]subpath{poly}, p) != 0 {
+				return true
+			}
+		}
+		return false
+	}
+	return supersampleFunc(inside)
+}
+
+// segments calls fn for each segment of the subpath, including the closing
+// one.
+func segments(sp subpath, fn func(a, b point)) {
+	pts := sp.points
+	if len(pts) == 1 {
+		fn(pts[0], pts[0])
+	}
+	for i := 1; i < len(pts); i++ {
+		fn(pts[i-1], pts[i])
+	}
+	if sp.closed && len(pts) > 2 {
+		fn(pts[len(pts)-1], pts[0])
+	}
+}
+
+// segmentDistance returns the distance from p to the segment a-b.
+func segmentDistance(p, a, b point) float64 {
+	dx, dy := b.x-a.x, b.y-a.y
+	l2 := dx*dx + dy*dy
+	t := 0.0
+	if l2 > 0 {
+		t = clamp01(((p.x-a.x)*dx + (p.y-a.y)*dy) / l2)
+	}
+	return math.Hypot(p.x-(a.x+t*dx), p.y-(a.y+t*dy))
+}
+
+// windingNumber returns the winding number of the subpaths (all considered
+// closed) around p. It's non-zero if p is inside.
+func windingNumber(sps []subpath, p point) int {
+	wn := 0
+	for _, sp := range sps {
+		pts := sp.points
+		for i := range pts {
+			a, b := pts[i], pts[(i+1)%len(pts)]
+			// Which side of a-b is p on.
+			side := (b.x-a.x)*(p.y-a.y) - (p.x-a.x)*(b.y-a.y)
+			if a.y <= p.y {
+				if b.y > p.y && side > 0 {
+					wn++
+				}
+			} else if b.y <= p.y && side < 0 {
+				wn--
+			}
+		}
+	}
+	return wn
+}
+
+func clamp01(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// # Interactive mode
+//
+// The "repl" command reads lines from the input, and prints the glyphs for
+// each one. This is much faster than running the "svg" command for every
+// attempt, since the dictionaries are only loaded once.
+//
+// Lines that begin with ":" are directives, which change the options or act
+// on the last line (see replDirectives).
+//
+// When the input is a terminal, the lines can be edited, and the previous
+// ones recalled with the arrows (see lineEditor).
+
+const replHelp = `Type words to see their glyphs. Directives:
+  :lang [languages]    show or set the preferred languages (e.g. "fr,en-GB")
+  :angle [degrees]     show or set the angle of the word line
+  :explain [words]     explain how the words (or the last ones) are converted
+  :save <file>         save the last words to the file (svg, png or pdf)
+  :help                show this help
+  :quit                exit (same as end of input)
+`
+
+// REPL directives, by name; the argument is the rest of the line.
+var replDirectives = map[string]func(r *repl, arg string) error{
+	"lang":    (*repl).lang,
+	"angle":   (*repl).angle,
+	"explain": (*repl).explain,
+	"save":    (*repl).save,
+	"help":    (*repl).help,
+}
+
+// State of the interactive session.
+type repl struct {
+	out  io.Writer
+	opts Options
+
+	// The last words that were converted.
+	last []string
+}
+
+// replCmd implements the "repl" command.
+func replCmd(in io.Reader, out io.Writer, opts Options) error {
+	r := &repl{out: out, opts: opts}
+	r.opts.notef = func(format string, args ...interface{}) {
+		fmt.Fprintf(out, "note: "+format+"\n", args...)
+	}
+
+	var lines lineReader = &plainReader{scanner: bufio.NewScanner(in),
+		out: out}
+	if f, ok := in.(*os.File); ok && isTerminal(f) {
+		if restore, err := rawTerminal(f); err == nil {
+			defer restore()
+			lines = newLineEditor(in, out)
+		}
+	}
+	return r.loop(lines)
+}
+
+// loop handles the lines until the end of the input, or ":quit".
+func (r *repl) loop(lines lineReader) error {
+	for {
+		line, err := lines.readLine("> ")
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		line = strings.TrimSpace(line)
+		if line == ":quit" || line == ":q" {
+			break
+		}
+		if err := r.handle(line); err != nil {
+			fmt.Fprintf(r.out, "error: %v\n", err)
+		}
+	}
+	fmt.Fprintln(r.out)
+	return nil
+}
+
+// handle a single line of input.
+func (r *repl) handle(line string) error {
+	if line == "" {
+		return nil
+	}
+
+	if strings.HasPrefix(line, ":") {
+		name, arg, _ := strings.Cut(line[1:], " ")
+		directive, ok := replDirectives[name]
+		if !ok {
+			return fmt.Errorf("unknown directive %q, see :help", name)
+		}
+		return directive(r, strings.TrimSpace(arg))
+	}
+
+	words := tokenize(line)
+	if len(words) == 0 {
+		return nil
+	}
+	wordsG, err := wordsToGlyphs(words, r.opts)
+	if err != nil {
+		return err
+	}
+	r.last = words
+
+	ws := []string{}
+	for _, w := range wordsG {
+		if w.isSentenceEnd() {
+			ws = append(ws, sentenceEnd)
+			continue
+		}
+		ws = append(ws, w.String())
+	}
+	fmt.Fprintln(r.out, strings.Join(ws, " "))
+	return nil
+}
+
+func (r *repl) lang(arg string) error {
+	if arg != "" {
+		langs, err := parseLangs(arg)
+		if err != nil {
+			return err
+		}
+		r.opts.langs = langs
+		if !r.opts.explicitDialect {
+			r.opts.dialect = preferredDialect(langs)
+		}
+	}
+
+	langs := []string{}
+	for _, tag := range r.opts.langs {
+		langs = append(langs, tag.String())
+	}
+	if len(langs) == 0 {
+		langs = append(langs, "(detected)")
+	}
+	fmt.Fprintf(r.out, "languages: %s, English dialect: %s\n",
+		strings.Join(langs, ","), r.opts.dialect)
+	return nil
+}
+
+func (r *repl) angle(arg string) error {
+	if arg != "" {
+		angle, err := strconv.Atoi(arg)
+		if err != nil {
+			return fmt.Errorf("invalid angle %q", arg)
+		}
+		opts := r.opts
+		opts.angle = angle
+		if err := opts.check(); err != nil {
+			return err
+		}
+		r.opts = opts
+	}
+	fmt.Fprintf(r.out, "angle: %d\n", r.opts.angle)
+	return nil
+}
+
+func (r *repl) explain(arg string) error {
+	words := r.last
+	if arg != "" {
+		words = tokenize(arg)
+	}
+	if len(words) == 0 {
+		return fmt.Errorf("nothing to explain")
+	}
+	return explainWords(r.out, words, r.opts)
+}
+
+func (r *repl) save(arg string) error {
+	if arg == "" {
+		return fmt.Errorf("missing file name")
+	}
+	if len(r.last) == 0 {
+		return fmt.Errorf("nothing to save")
+	}
+	doc, err := renderDocument(r.last, r.opts, *showGrid)
+	if err != nil {
+		return err
+	}
+	if err := writeOutput(arg, doc, *overwriteFlag); err != nil {
+		return err
+	}
+	fmt.Fprintf(r.out, "saved %q to %s\n", strings.Join(r.last, " "), arg)
+	return nil
+}
+
+func (r *repl) help(arg string) error {
+	fmt.Fprint(r.out, replHelp)
+	return nil
+}
+
+// explainWords writes how each of the words is converted: the detected
+// language, and for each word its language, IPA and glyphs.
+func explainWords(w io.Writer, words []string, opts Options) error {
+	det := detectLanguage(words, opts.langs)
+	if det.words > 0 {
+		fmt.Fprintf(w, "Language: %s\n", det)
+	}
+
+	words, err := expandWords(words, det, opts)
+	if err != nil {
+		return err
+	}
+	for _, word := range words {
+		if word == sentenceEnd {
+			fmt.Fprintln(w, "  (end of sentence)")
+			continue
+		}
+
+		gs, err := smartWordToGlyphs(word, det, opts)
+		if err != nil {
+			fmt.Fprintf(w, "  %s: error: %v\n", word, err)
+			continue
+		}
+
+		lang, lw := resolveWordLang(word, det, opts)
+		source := "glyph names"
+		if lang != "" {
+			source = lang + " " + explainIPA(lw, lang)
+		}
+		fmt.Fprintf(w, "  %s: %s -> %s\n", word, source, gs)
+	}
+	return nil
+}
+
+// explainIPA returns a description of the IPA used for the word, like
+// "/ipa/" or "/ipa1/ + /ipa2/" for compound words.
+func explainIPA(word, lang string) string {
+	parts, phonemes, err := lookupWord(strings.ReplaceAll(word, "/", ""), lang)
+	switch {
+	case err != nil:
+		// The word was converted as glyph names instead.
+		return "(not found, used as glyph names)"
+	case phonemes != "":
+		return "(glyphs from the user dictionary)"
+	}
+
+	ipas := []string{}
+	for _, part := range parts {
+		ipa, _ := normalizeIPA(part.ipa)
+		ipas = append(ipas, "/"+ipa+"/")
+	}
+	return strings.Join(ipas, " + ")
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/language"
+)
+
+func TestREPL(t *testing.T) {
+	dir := t.TempDir()
+	svgPath := filepath.Join(dir, "out.svg")
+
+	in := strings.Join([]string{
+		"bath",
+		":angle 20",
+		":angle 90",
+		":lang en-GB",
+		"bath",
+		":explain",
+		":save " + svgPath,
+		":unknown",
+		":quit",
+		"not reached",
+	}, "\n")
+	out := &strings.Builder{}
+	opts := Options{syllables: "manual", sentences: "none", angle: defaultAngle}
+	if err := replCmd(strings.NewReader(in), out, opts); err != nil {
+		t.Fatalf("replCmd: %v", err)
+	}
+
+	expected := []string{
+		"> B-sAd-TH\n",
+		"> angle: 20\n",
+		"> error: angle 90 out of range",
+		"> languages: en-GB, English dialect: en-GB\n",
+		"> B-All-TH\n",
+		"  bath: en-GB /ˈbɑθ/ -> B-All-TH\n",
+		`> saved "bath" to `,
+		`> error: unknown directive "unknown"`,
+	}
+	got := out.String()
+	for _, e := range expected {
+		if !strings.Contains(got, e) {
+			t.Errorf("output does not contain %q:\n%s", e, got)
+		}
+	}
+	if strings.Contains(got, "reached") {
+		t.Errorf("input after :quit was processed:\n%s", got)
+	}
+
+	svg, err := os.ReadFile(svgPath)
+	if err != nil {
+		t.Fatalf("error reading saved SVG: %v", err)
+	}
+	if !strings.Contains(string(svg), "rotate(20)") {
+		t.Errorf("saved SVG does not use the angle:\n%s", svg)
+	}
+}
+
+func TestREPLExplicitDialect(t *testing.T) {
+	// An explicit dialect is kept when the languages change.
+	opts := Options{syllables: "manual", sentences: "none", angle: defaultAngle,
+		dialect: language.MustParse("en-AU"), explicitDialect: true}
+	out := &strings.Builder{}
+	err := replCmd(strings.NewReader(":lang en-GB\n"), out, opts)
+	if err != nil {
+		t.Fatalf("replCmd: %v", err)
+	}
+	expected := "languages: en-GB, English dialect: en-AU\n"
+	if got := out.String(); !strings.Contains(got, expected) {
+		t.Errorf("output does not contain %q:\n%s", expected, got)
+	}
+}
@@ -0,0 +1,394 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"maps"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// # Terminal output
+//
+// The "show" command draws the words in the terminal. There are a few ways
+// of doing that:
+//
+//   - braille: Unicode braille patterns, each character has 2x4 dots. It
+//     works in any terminal with a Unicode font, but it's monochrome.
+//   - blocks: Unicode half blocks, each character has 1x2 pixels. Coarser,
+//     but some fonts don't have braille patterns.
+//   - sixel: the image, in the DEC sixel graphics format. Supported by
+//     xterm (with -ti vt340), mlterm, foot, and others.
+//   - kitty: the image, as PNG, with the kitty graphics protocol. Supported
+//     by kitty, WezTerm, ghostty and others.
+//
+// The graphics protocols are detected from the environment and the terminfo
+// database (see detectTerminal), since querying the terminal needs raw mode
+// and a reply that might never come. The text modes are the fallback, and
+// can be chosen with -terminal.
+
+var terminalFlag = flag.String("terminal", "auto",
+	"in show, how to draw in the terminal: auto, braille, blocks, sixel, "+
+		"kitty")
+
+var terminalModes = map[string]func(io.Writer, *showImage) error{
+	"braille": writeBraille,
+	"blocks":  writeBlocks,
+	"sixel":   writeSixel,
+	"kitty":   writeKitty,
+}
+
+// Terminals that support each of the graphics protocols, by TERM, or by
+// TERM_PROGRAM (prefixed with "program:").
+var graphicsTerminals = map[string]string{
+	"xterm-kitty":     "kitty",
+	"xterm-ghostty":   "kitty",
+	"program:WezTerm": "kitty",
+	"program:ghostty": "kitty",
+	"mlterm":          "sixel",
+	"foot":            "sixel",
+	"foot-extra":      "sixel",
+	"contour":         "sixel",
+	"yaft-256color":   "sixel",
+	"program:mintty":  "sixel",
+}
+
+// What to draw in the terminal.
+type showImage struct {
+	// The words only, for the text modes: pixels with ink are the ones
+	// that are mostly opaque.
+	ink *image.RGBA
+
+	// The whole image (with the background), for the graphics modes.
+	full *image.RGBA
+}
+
+// Terminfo capabilities that say the terminal supports sixel graphics. They
+// are extensions, so not all entries have them: tmux uses "Sxl", and some
+// entries "Sixel".
+var sixelCaps = []string{"Sxl", "Sixel"}
+
+// detectTerminal returns the best mode for the terminal, based on the
+// environment variables (as returned by getenv), and the capabilities of
+// the terminal in the terminfo database (as returned by caps, see
+// terminfoCaps). Terminfo has nothing about the kitty protocol, so that one
+// is only detected from the environment.
+func detectTerminal(getenv func(string) string,
+	caps func(term string) map[string]bool) string {
+	if getenv("KITTY_WINDOW_ID") != "" {
+		return "kitty"
+	}
+	if m, ok := graphicsTerminals["program:"+getenv("TERM_PROGRAM")]; ok {
+		return m
+	}
+	term := getenv("TERM")
+	if m, ok := graphicsTerminals[term]; ok {
+		return m
+	}
+	if strings.Contains(term, "sixel") {
+		return "sixel"
+	}
+	if term != "" {
+		tc := caps(term)
+		for _, c := range sixelCaps {
+			if tc[c] {
+				return "sixel"
+			}
+		}
+	}
+	return "braille"
+}
+
+// terminfoCaps returns the names of the capabilities of the terminal in the
+// terminfo database, using infocmp. If that fails (for example, because
+// infocmp is not installed, or the terminal is unknown), it returns nil.
+func terminfoCaps(term string) map[string]bool {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "infocmp", "-x", "-1", term)
+	out, err := cmd.Output()
+	if err != nil {
+		return nil
+	}
+
+	// With -1 there is a capability per line, indented: booleans are just
+	// the name, the others are followed by "#" or "=" and their value, and
+	// the cancelled ones by "@".
+	caps := map[string]bool{}
+	for _, line := range strings.Split(string(out), "\n") {
+		if !strings.HasPrefix(line, "\t") {
+			continue
+		}
+		name := strings.TrimSuffix(strings.TrimSpace(line), ",")
+		if strings.HasSuffix(name, "@") {
+			continue
+		}
+		if i := strings.IndexAny(name, "#="); i >= 0 {
+			name = name[:i]
+		}
+		caps[name] = true
+	}
+	return caps
+}
+
+// terminalColumns returns the width of the terminal, in characters.
+func terminalColumns(getenv func(string) string) int {
+	if n, err := strconv.Atoi(getenv("COLUMNS")); err == nil && n > 0 {
+		return n
+	}
+	return 80
+}
+
+// isTerminal returns true if the file is a terminal (or some other
+// character device).
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// showCmd implements the "show" command: it draws the words in the
+// terminal.
+func showCmd(w io.Writer, words []string, opts Options, tty bool) error {
+	mode := *terminalFlag
+	if mode == "auto" {
+		mode = "braille"
+		// Escape sequences are only useful if they go to the terminal.
+		if tty {
+			mode = detectTerminal(os.Getenv, terminfoCaps)
+		}
+	}
+	write, ok := terminalModes[mode]
+	if !ok {
+		return fmt.Errorf("unknown terminal mode %q, supported: auto, %s",
+			mode, strings.Join(slices.Sorted(maps.Keys(terminalModes)), ", "))
+	}
+
+	// The text modes use the whole width of the terminal, and the graphics
+	// modes the one given with -width.
+	cols := terminalColumns(os.Getenv) - 1
+	width := map[string]int{
+		"braille": 2 * cols, "blocks": cols,
+	}[mode]
+	if width == 0 {
+		width = *widthFlag
+	}
+
+	img, err := renderShowImage(words, opts, width)
+	if err != nil {
+		return err
+	}
+	return write(w, img)
+}
+
+// renderShowImage draws the words for the terminal, with the given width in
+// pixels.
+func renderShowImage(words []string, opts Options, width int) (
+	*showImage, error) {
+	wsvg, svgW, svgH, err := wordsToSVG(words, opts)
+	if err != nil {
+		return nil, err
+	}
+	sc, err := wordsScene(wsvg, svgW, svgH)
+	if err != nil {
+		return nil, err
+	}
+	scale := float64(width) / sc.width
+	img := &showImage{ink: rasterize(sc, scale, color.NRGBA{})}
+
+	doc, err := renderDocument(words, opts, false)
+	if err != nil {
+		return nil, err
+	}
+	full, err := doc.scene()
+	if err != nil {
+		return nil, err
+	}
+	img.full = rasterize(full, scale, color.NRGBA{})
+	return img, nil
+}
+
+// Alpha from which a pixel has ink, in the text modes. It's lower than
+// half, so the thin strokes don't break up.
+const inkAlpha = 80
+
+func hasInk(img *image.RGBA, x, y int) bool {
+	if !(image.Point{x, y}.In(img.Bounds())) {
+		return false
+	}
+	return img.Pix[img.PixOffset(x, y)+3] >= inkAlpha
+}
+
+// Bits of each dot of the braille patterns, by [y][x].
+var brailleDots = [4][2]rune{
+	{0x01, 0x08},
+	{0x02, 0x10},
+	{0x04, 0x20},
+	{0x40, 0x80},
+}
+
+// writeBraille draws the image with braille patterns.
+func writeBraille(w io.Writer, img *showImage) error {
+	return writeCells(w, img.ink, 2, 4, func(ink func(x, y int) bool) rune {
+		r := rune(0x2800)
+		for dy, row := range brailleDots {
+			for dx, bit := range row {
+				if ink(dx, dy) {
+					r |= bit
+				}
+			}
+		}
+		return r
+	})
+}
+
+// writeBlocks draws the image with half blocks.
+func writeBlocks(w io.Writer, img *showImage) error {
+	return writeCells(w, img.ink, 1, 2, func(ink func(x, y int) bool) rune {
+		switch top, bottom := ink(0, 0), ink(0, 1); {
+		case top && bottom:
+			return '█'
+		case top:
+			return '▀'
+		case bottom:
+			return '▄'
+		}
+		return ' '
+	})
+}
+
+// writeCells draws the image with characters of cw x ch pixels each, as
+// returned by cell (which is given a function to check for ink, relative
+// to the cell). Trailing blank characters are removed.
+func writeCells(w io.Writer, img *image.RGBA, cw, ch int,
+	cell func(ink func(x, y int) bool) rune) error {
+	r := img.Bounds()
+	blank := cell(func(x, y int) bool { return false })
+	buf := &bytes.Buffer{}
+	for y := r.Min.Y; y < r.Max.Y; y += ch {
+		line := []rune{}
+		for x := r.Min.X; x < r.Max.X; x += cw {
+			line = append(line, cell(func(dx, dy int) bool {
+				return hasInk(img, x+dx, y+dy)
+			}))
+		}
+		for len(line) > 0 && line[len(line)-1] == blank {
+			line = line[:len(line)-1]
+		}
+		buf.WriteString(string(line) + "\n")
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// writeSixel draws the image with sixel graphics. Transparent pixels are
+// left as they are.
+func writeSixel(w io.Writer, img *showImage) error {
+	pal, transparent := gifPalette(img.full)
+	conv := paletteConverter{pal: pal, transparent: transparent,
+		cache: map[color.RGBA]uint8{}}
+	pimg := conv.convert(img.full)
+	r := pimg.Bounds()
+
+	buf := &bytes.Buffer{}
+	// P2=1 keeps the pixels that are not drawn.
+	fmt.Fprintf(buf, "\x1bP0;1;0q\"1;1;%d;%d", r.Dx(), r.Dy())
+	first := 0
+	if transparent {
+		first = 1
+	}
+	for i := first; i < len(pal); i++ {
+		c := pal[i].(color.RGBA)
+		fmt.Fprintf(buf, "#%d;2;%d;%d;%d", i, int(c.R)*100/255,
+			int(c.G)*100/255, int(c.B)*100/255)
+	}
+
+	// Each band is 6 pixels high, and drawn once per color, with each
+	// character having the 6 vertical pixels of that color.
+	row := make([]byte, r.Dx())
+	for y := r.Min.Y; y < r.Max.Y; y += 6 {
+		for i := first; i < len(pal); i++ {
+			used := false
+			for x := r.Min.X; x < r.Max.X; x++ {
+				bits := byte(0)
+				for dy := range 6 {
+					if y+dy < r.Max.Y &&
+						pimg.ColorIndexAt(x, y+dy) == uint8(i) {
+						bits |= 1 << dy
+					}
+				}
+				row[x-r.Min.X] = '?' + bits
+				used = used || bits != 0
+			}
+			if !used {
+				continue
+			}
+			fmt.Fprintf(buf, "#%d", i)
+			writeSixelRow(buf, bytes.TrimRight(row, "?"))
+			buf.WriteByte('$')
+		}
+		buf.WriteByte('-')
+	}
+	buf.WriteString("\x1b\\\n")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// writeSixelRow writes the sixel characters, with runs of the same one
+// compressed.
+func writeSixelRow(buf *bytes.Buffer, row []byte) {
+	for i := 0; i < len(row); {
+		n := 1
+		for i+n < len(row) && row[i+n] == row[i] {
+			n++
+		}
+		if n > 3 {
+			fmt.Fprintf(buf, "!%d%c", n, row[i])
+		} else {
+			buf.Write(row[i : i+n])
+		}
+		i += n
+	}
+}
+
+// Maximum size of the data in each kitty graphics escape sequence.
+const kittyChunkSize = 4096
+
+// writeKitty draws the image with the kitty graphics protocol, sending it
+// as PNG.
+func writeKitty(w io.Writer, img *showImage) error {
+	pngBuf := &bytes.Buffer{}
+	if err := png.Encode(pngBuf, img.full); err != nil {
+		return err
+	}
+	data := base64.StdEncoding.EncodeToString(pngBuf.Bytes())
+
+	buf := &bytes.Buffer{}
+	for i := 0; i < len(data); i += kittyChunkSize {
+		chunk := data[i:min(i+kittyChunkSize, len(data))]
+		more := 0
+		if i+kittyChunkSize < len(data) {
+			more = 1
+		}
+		// Only the first chunk has the control data: transmit and display
+		// (a=T), PNG format (f=100).
+		ctrl := fmt.Sprintf("m=%d", more)
+		if i == 0 {
+			ctrl = "a=T,f=100," + ctrl
+		}
+		fmt.Fprintf(buf, "\x1b_G%s;%s\x1b\\", ctrl, chunk)
+	}
+	buf.WriteString("\n")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
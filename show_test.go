@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/png"
+	"os/exec"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestDetectTerminal(t *testing.T) {
+	cases := []struct {
+		env      map[string]string
+		expected string
+	}{
+		{map[string]string{}, "braille"},
+		{map[string]string{"TERM": "xterm-256color"}, "braille"},
+		{map[string]string{"TERM": "xterm-kitty"}, "kitty"},
+		{map[string]string{"TERM": "xterm-256color",
+			"KITTY_WINDOW_ID": "1"}, "kitty"},
+		{map[string]string{"TERM": "xterm-256color",
+			"TERM_PROGRAM": "WezTerm"}, "kitty"},
+		{map[string]string{"TERM": "foot"}, "sixel"},
+		{map[string]string{"TERM": "xterm-sixel"}, "sixel"},
+		{map[string]string{"TERM": "screen", "TERM_PROGRAM": "mintty"},
+			"sixel"},
+		// From terminfo.
+		{map[string]string{"TERM": "tmux-256color"}, "sixel"},
+		{map[string]string{"TERM": "mysixel"}, "sixel"},
+		{map[string]string{"TERM": "unknown"}, "braille"},
+	}
+	caps := func(term string) map[string]bool {
+		return map[string]map[string]bool{
+			"xterm-256color": {"am": true, "Smulx": true},
+			"tmux-256color":  {"am": true, "Sxl": true},
+			"mysixel":        {"Sixel": true},
+		}[term]
+	}
+	for _, c := range cases {
+		getenv := func(k string) string { return c.env[k] }
+		got := detectTerminal(getenv, caps)
+		if got != c.expected {
+			t.Errorf("detectTerminal(%v) = %q, expected %q", c.env, got,
+				c.expected)
+		}
+	}
+}
+
+// testShowImage returns a 4x4 image, with a diagonal line and a pixel
+// that's too transparent to count as ink.
+func testShowImage() *showImage {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	orange := color.RGBA{255, 165, 0, 255}
+	for i := range 4 {
+		img.SetRGBA(i, i, orange)
+	}
+	img.SetRGBA(3, 0, color.RGBA{10, 6, 0, 10})
+	return &showImage{ink: img, full: img}
+}
+
+func TestTextModes(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := writeBraille(buf, testShowImage()); err != nil {
+		t.Fatalf("writeBraille error: %v", err)
+	}
+	// Dots 1, 5 in the first character, and 3, 8 in the second.
+	if got, expected := buf.String(), "⠑⢄\n"; got != expected {
+		t.Errorf("braille: got %q, expected %q", got, expected)
+	}
+
+	buf.Reset()
+	if err := writeBlocks(buf, testShowImage()); err != nil {
+		t.Fatalf("writeBlocks error: %v", err)
+	}
+	if got, expected := buf.String(), "▀▄\n  ▀▄\n"; got != expected {
+		t.Errorf("blocks: got %q, expected %q", got, expected)
+	}
+}
+
+func TestGraphicsModes(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := writeSixel(buf, testShowImage()); err != nil {
+		t.Fatalf("writeSixel error: %v", err)
+	}
+	// The transparent pixels are not drawn, and the faint one is
+	// transparent for the palette.
+	expected := "\x1bP0;1;0q\"1;1;4;4#1;2;100;64;0#1@ACG$-\x1b\\\n"
+	if got := buf.String(); got != expected {
+		t.Errorf("sixel: got %q, expected %q", got, expected)
+	}
+
+	// Run length encoding.
+	buf.Reset()
+	writeSixelRow(buf, []byte("??~~~~~A"))
+	if got := buf.String(); got != "??!5~A" {
+		t.Errorf("writeSixelRow: got %q", got)
+	}
+
+	// A large image (of noise, which doesn't compress), so the data is
+	// sent in more than one sequence.
+	img := image.NewRGBA(image.Rect(0, 0, 200, 200))
+	x := uint32(1)
+	for i := range img.Pix {
+		x = x*1103515245 + 12345
+		img.Pix[i] = uint8(x >> 16)
+	}
+	buf.Reset()
+	if err := writeKitty(buf, &showImage{full: img}); err != nil {
+		t.Fatalf("writeKitty error: %v", err)
+	}
+	seqs := regexp.MustCompile("\x1b_G([^;]*);([^\x1b]*)\x1b\\\\").
+		FindAllStringSubmatch(buf.String(), -1)
+	if len(seqs) < 2 {
+		t.Fatalf("expected several sequences, got %d", len(seqs))
+	}
+	data := ""
+	for i, s := range seqs {
+		ctrl := "m=1"
+		if i == 0 {
+			ctrl = "a=T,f=100,m=1"
+		} else if i == len(seqs)-1 {
+			ctrl = "m=0"
+		}
+		if s[1] != ctrl {
+			t.Errorf("sequence %d: control %q, expected %q", i, s[1], ctrl)
+		}
+		data += s[2]
+	}
+	raw, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		t.Fatalf("error decoding base64: %v", err)
+	}
+	if _, err := png.Decode(bytes.NewReader(raw)); err != nil {
+		t.Errorf("error decoding PNG: %v", err)
+	}
+}
+
+func TestShowCmd(t *testing.T) {
+	opts := Options{syllables: "manual", angle: defaultAngle,
+		sentences: "none"}
+	t.Setenv("COLUMNS", "40")
+	buf := &bytes.Buffer{}
+	if err := showCmd(buf, []string{"hi"}, opts, false); err != nil {
+		t.Fatalf("showCmd error: %v", err)
+	}
+	// Not a terminal, so braille, using the width of the terminal.
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	width := 0
+	for _, l := range lines {
+		width = max(width, len([]rune(l)))
+		if strings.ContainsAny(l, "\x1b ") {
+			t.Errorf("unexpected characters in %q", l)
+		}
+	}
+	if width == 0 || width > 39 {
+		t.Errorf("unexpected width %d", width)
+	}
+}
+
+func TestTerminfoCaps(t *testing.T) {
+	if _, err := exec.LookPath("infocmp"); err != nil {
+		t.Skip("infocmp is not installed")
+	}
+	caps := terminfoCaps("xterm")
+	if caps == nil {
+		t.Skip("xterm is not in the terminfo database")
+	}
+	// A boolean, a number and a string.
+	for _, c := range []string{"am", "colors", "bel"} {
+		if !caps[c] {
+			t.Errorf("xterm does not have %q: %v", c, caps)
+		}
+	}
+	if caps := terminfoCaps("no-such-terminal"); caps != nil {
+		t.Errorf("unknown terminal has capabilities: %v", caps)
+	}
+}
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+)
+
+// # STL
+//
+// The "stl" command makes a 3D model of the words, for 3D printing
+// nameplates and stamps: the outline of the strokes (see outlineShapes) is
+// extruded on top of a base plate. With -stamp the words are mirrored, so
+// they read right once stamped (for example, on sealing wax).
+//
+// The plate is a rectangle around the words, or a disc (with -plate-shape
+// round), which is the usual shape of seals.
+//
+// The model is a single closed surface, which slicers need: the top of the
+// plate has holes where the words stand, and the walls of the words share
+// their edges with it, so nothing overlaps and there are no gaps. It's
+// written as binary STL, or ASCII with -ascii, in millimetres, by the "stl"
+// output backend, which -o and the HTTP server use as well.
+
+var (
+	reliefFlag = flag.Float64("relief", 1,
+		"in stl, height of the words over the plate, in millimetres")
+	plateFlag = flag.Float64("plate", 2,
+		"in stl, thickness of the base plate in millimetres; 0 for none")
+	plateShapeFlag = flag.String("plate-shape", "rect",
+		"in stl, shape of the base plate: rect, round")
+	marginFlag = flag.Float64("margin", 3,
+		"in stl, space between the words and the edge of the plate, in "+
+			"millimetres")
+	stampFlag = flag.Bool("stamp", false,
+		"in stl, mirror the words, so the model can be used as a stamp")
+	asciiFlag = flag.Bool("ascii", false,
+		"in stl, write ASCII instead of binary")
+)
+
+// Limits, so the values make some sense.
+const (
+	maxRelief = 20
+	maxPlate  = 20
+	minMargin = 0.5
+	maxMargin = 50
+)
+
+// Number of sides of the round plate.
+const roundPlatePoints = 128
+
+// stlOptions are the options for the STL output.
+type stlOptions struct {
+	relief float64
+	plate  float64
+	margin float64
+	round  bool
+	stamp  bool
+}
+
+// A point in space. Z goes up.
+type point3 struct {
+	x, y, z float64
+}
+
+// A triangle of the surface, with its vertices counterclockwise when seen
+// from the outside.
+type facet [3]point3
+
+// stlCmd implements the "stl" command: it writes the words as STL to the
+// output file given with -o (in the format of its extension), or to w.
+func stlCmd(w io.Writer, words []string, opts Options) error {
+	doc, err := renderDocument(words, opts, false)
+	if err != nil {
+		return err
+	}
+	return writeDocument(w, doc, "stl")
+}
+
+// stlBackend writes the model of the words of the document as STL, with
+// the options given by the flags.
+type stlBackend struct{}
+
+func (stlBackend) contentType() string {
+	return "model/stl"
+}
+
+func (stlBackend) write(w io.Writer, doc *document) error {
+	so := stlOptions{
+		relief: *reliefFlag, plate: *plateFlag, margin: *marginFlag,
+		stamp: *stampFlag,
+	}
+	switch *plateShapeFlag {
+	case "rect":
+	case "round":
+		so.round = true
+	default:
+		return fmt.Errorf("unknown plate shape %q, supported: rect, round",
+			*plateShapeFlag)
+	}
+	switch {
+	case so.relief <= 0 || so.relief > maxRelief:
+		return fmt.Errorf("relief must be more than 0 and up to %d",
+			maxRelief)
+	case so.plate < 0 || so.plate > maxPlate:
+		return fmt.Errorf("plate must be between 0 and %d", maxPlate)
+	case so.margin < minMargin || so.margin > maxMargin:
+		return fmt.Errorf("margin must be between %v and %d", minMargin,
+			maxMargin)
+	}
+
+	facets, err := stlFacets(doc, so)
+	if err != nil {
+		return err
+	}
+	buf := &bytes.Buffer{}
+	if *asciiFlag {
+		writeASCIISTL(buf, facets)
+	} else {
+		writeBinarySTL(buf, facets)
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// stlFacets returns the model of the words of the document.
+func stlFacets(doc *document, so stlOptions) ([]facet, error) {
+	sc, err := doc.wordsScene()
+	if err != nil {
+		return nil, err
+	}
+	opts := doc.opts
+	shapes := opts.outline.shapes(sc.shapes, opts.style.resolved().width)
+	rings := outlineShapes(shapes, 0)
+	if len(rings) == 0 {
+		return nil, fmt.Errorf("there is nothing to extrude")
+	}
+	return extrude(rings, so), nil
+}
+
+// extrude returns the model for the outline (as returned by outlineShapes):
+// the plate under it, and the area inside it raised over the plate.
+func extrude(rings []subpath, so stlOptions) []facet {
+	min, max := ringsBounds(rings)
+	var plate subpath
+	if so.round {
+		c := point{(min.x + max.x) / 2, (min.y + max.y) / 2}
+		r := dist(min, max)/2 + so.margin
+		plate = subpath{closed: true}
+		for i := range roundPlatePoints {
+			a := 2 * math.Pi * float64(i) / roundPlatePoints
+			plate.points = append(plate.points,
+				point{c.x + r*math.Cos(a), c.y + r*math.Sin(a)})
+		}
+	} else {
+		m := so.margin
+		plate = subpath{closed: true, points: []point{
+			{min.x - m, min.y - m}, {max.x + m, min.y - m},
+			{max.x + m, max.y + m}, {min.x - m, max.y + m},
+		}}
+	}
+	if so.plate > 0 {
+		min, max = ringsBounds([]subpath{plate})
+	}
+
+	// In the model Y goes up, so the words are flipped, and the corner of
+	// the plate is at the origin. Flipping changes the direction of the
+	// rings, and mirroring (for stamps) changes it back.
+	place := func(sp subpath) subpath {
+		out := subpath{closed: true}
+		for _, p := range sp.points {
+			x := p.x - min.x
+			if so.stamp {
+				x = max.x - p.x
+			}
+			out.points = append(out.points, point{x, max.y - p.y})
+		}
+		if !so.stamp {
+			slices.Reverse(out.points)
+		}
+		return out
+	}
+	plate = place(plate)
+	rings = slices.Clone(rings)
+	for i, r := range rings {
+		rings[i] = place(r)
+	}
+
+	facets := []facet{}
+	face := func(rings []subpath, z float64, up bool) {
+		for _, poly := range ringPolygons(rings) {
+			for _, t := range triangulate(poly[0], poly[1:]) {
+				a := point3{t[0].x, t[0].y, z}
+				b := point3{t[1].x, t[1].y, z}
+				c := point3{t[2].x, t[2].y, z}
+				if !up {
+					b, c = c, b
+				}
+				facets = append(facets, facet{a, b, c})
+			}
+		}
+	}
+	walls := func(rings []subpath, z0, z1 float64) {
+		for _, r := range rings {
+			for i, p := range r.points {
+				q := r.points[(i+1)%len(r.points)]
+				p0, q0 := point3{p.x, p.y, z0}, point3{q.x, q.y, z0}
+				p1, q1 := point3{p.x, p.y, z1}, point3{q.x, q.y, z1}
+				facets = append(facets, facet{p0, q0, q1}, facet{p0, q1, p1})
+			}
+		}
+	}
+
+	z := 0.0
+	if so.plate > 0 {
+		z = so.plate
+		face([]subpath{plate}, 0, false)
+		walls([]subpath{plate}, 0, z)
+
+		// The top of the plate is what the words don't cover: the rings,
+		// in the other direction, are its holes (and the holes of the
+		// words are parts of it).
+		top := []subpath{plate}
+		for _, r := range rings {
+			rev := subpath{closed: true, points: slices.Clone(r.points)}
+			slices.Reverse(rev.points)
+			top = append(top, rev)
+		}
+		face(top, z, true)
+	} else {
+		face(rings, 0, false)
+	}
+	walls(rings, z, z+so.relief)
+	face(rings, z+so.relief, true)
+	return facets
+}
+
+// ringsBounds returns the bounding box of the points of the rings.
+func ringsBounds(rings []subpath) (min, max point) {
+	min = point{math.Inf(1), math.Inf(1)}
+	max = point{math.Inf(-1), math.Inf(-1)}
+	for _, r := range rings {
+		for _, p := range r.points {
+			min = point{math.Min(min.x, p.x), math.Min(min.y, p.y)}
+			max = point{math.Max(max.x, p.x), math.Max(max.y, p.y)}
+		}
+	}
+	return min, max
+}
+
+// ringPolygons groups the rings in polygons, each one being an outer ring
+// (with positive area, see ringArea) followed by the holes inside it (with
+// negative area).
+func ringPolygons(rings []subpath) [][][]point {
+	polys := [][][]point{}
+	areas := []float64{}
+	holes := []subpath{}
+	for _, r := range rings {
+		switch a := ringArea(r); {
+		case a > 0:
+			polys = append(polys, [][]point{r.points})
+			areas = append(areas, a)
+		case a < 0:
+			holes = append(holes, r)
+		}
+	}
+
+	// Each hole goes in the smallest ring around it. The rings don't
+	// cross, so it's enough to check one of its points.
+	for _, h := range holes {
+		best := -1
+		for i, p := range polys {
+			if insideRing(p[0], h.points[0]) &&
+				(best < 0 || areas[i] < areas[best]) {
+				best = i
+			}
+		}
+		if best >= 0 {
+			polys[best] = append(polys[best], h.points)
+		}
+	}
+	return polys
+}
+
+// insideRing returns true if the point is inside the ring (using the
+// even-odd rule).
+func insideRing(ring []point, p point) bool {
+	inside := false
+	for i, a := range ring {
+		b := ring[(i+1)%len(ring)]
+		if (a.y > p.y) != (b.y > p.y) &&
+			p.x < a.x+(p.y-a.y)*(b.x-a.x)/(b.y-a.y) {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// normal returns the unit normal of the facet, or zero if it has no area.
+func (f facet) normal() point3 {
+	u := point3{f[1].x - f[0].x, f[1].y - f[0].y, f[1].z - f[0].z}
+	v := point3{f[2].x - f[0].x, f[2].y - f[0].y, f[2].z - f[0].z}
+	n := point3{u.y*v.z - u.z*v.y, u.z*v.x - u.x*v.z, u.x*v.y - u.y*v.x}
+	l := math.Sqrt(n.x*n.x + n.y*n.y + n.z*n.z)
+	if l == 0 {
+		return point3{}
+	}
+	return point3{n.x / l, n.y / l, n.z / l}
+}
+
+// writeBinarySTL writes the facets as binary STL: an 80 byte header, the
+// number of facets, and for each one its normal and vertices as 32 bit
+// floats, and 2 unused bytes.
+func writeBinarySTL(buf *bytes.Buffer, facets []facet) {
+	// The header must not start with "solid", or it could be taken for
+	// ASCII.
+	header := make([]byte, 80)
+	copy(header, "firstones")
+	buf.Write(header)
+
+	b := binary.LittleEndian.AppendUint32(nil, uint32(len(facets)))
+	for _, f := range facets {
+		for _, p := range append([]point3{f.normal()}, f[:]...) {
+			for _, v := range []float64{p.x, p.y, p.z} {
+				b = binary.LittleEndian.AppendUint32(b,
+					math.Float32bits(float32(v)))
+			}
+		}
+		b = append(b, 0, 0)
+	}
+	buf.Write(b)
+}
+
+// writeASCIISTL writes the facets as ASCII STL.
+func writeASCIISTL(buf *bytes.Buffer, facets []facet) {
+	// Same precision as the binary format.
+	num := func(v float64) string {
+		if v == 0 {
+			return "0" // Not "-0".
+		}
+		return strconv.FormatFloat(float64(float32(v)), 'g', -1, 32)
+	}
+	buf.WriteString("solid firstones\n")
+	for _, f := range facets {
+		n := f.normal()
+		fmt.Fprintf(buf, "facet normal %s %s %s\n", num(n.x), num(n.y),
+			num(n.z))
+		buf.WriteString("  outer loop\n")
+		for _, p := range f {
+			fmt.Fprintf(buf, "    vertex %s %s %s\n", num(p.x), num(p.y),
+				num(p.z))
+		}
+		buf.WriteString("  endloop\nendfacet\n")
+	}
+	buf.WriteString("endsolid firstones\n")
+}
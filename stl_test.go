@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestTriangulate(t *testing.T) {
+	square := func(x, y, s float64) []point {
+		return []point{{x, y}, {x + s, y}, {x + s, y + s}, {x, y + s}}
+	}
+	cases := []struct {
+		outer []point
+		holes [][]point
+		area  float64
+	}{
+		{square(0, 0, 4), nil, 16},
+		// A U shape, in the other direction.
+		{[]point{{0, 0}, {0, 3}, {1, 3}, {1, 1}, {2, 1}, {2, 3}, {3, 3},
+			{3, 0}}, nil, 7},
+		{square(0, 0, 4), [][]point{square(1, 1, 1), square(2.5, 2.5, 1)},
+			14},
+	}
+	for i, c := range cases {
+		n := len(c.outer) - 2
+		for _, h := range c.holes {
+			n += len(h) + 2
+		}
+		tris := triangulate(c.outer, c.holes)
+		if len(tris) != n {
+			t.Errorf("%d: got %d triangles, expected %d", i, len(tris), n)
+		}
+		area := 0.0
+		for _, tr := range tris {
+			a := ringArea(subpath{points: tr[:]})
+			if a <= 0 {
+				t.Errorf("%d: triangle %v has area %v", i, tr, a)
+			}
+			area += a
+		}
+		if math.Abs(area-c.area) > 1e-9 {
+			t.Errorf("%d: got area %v, expected %v", i, area, c.area)
+		}
+	}
+}
+
+// checkClosed checks that the facets make a closed surface, with all of them
+// facing out: each edge must be in exactly two facets, in opposite
+// directions.
+func checkClosed(t *testing.T, facets []facet) {
+	t.Helper()
+	edges := map[[2]point3]int{}
+	for _, f := range facets {
+		for i := range 3 {
+			if a, b := f[i], f[(i+1)%3]; a != b {
+				edges[[2]point3{a, b}]++
+			}
+		}
+	}
+	for e, n := range edges {
+		if n != 1 || edges[[2]point3{e[1], e[0]}] != 1 {
+			t.Errorf("edge %v is in %d facets, and reversed in %d", e, n,
+				edges[[2]point3{e[1], e[0]}])
+			return
+		}
+	}
+}
+
+// volume returns the volume inside the closed surface, using the
+// divergence theorem.
+func volume(facets []facet) float64 {
+	v := 0.0
+	for _, f := range facets {
+		a, b, c := f[0], f[1], f[2]
+		v += a.x*(b.y*c.z-b.z*c.y) - a.y*(b.x*c.z-b.z*c.x) +
+			a.z*(b.x*c.y-b.y*c.x)
+	}
+	return v / 6
+}
+
+func TestExtrude(t *testing.T) {
+	opts := Options{syllables: "manual", angle: defaultAngle,
+		sentences: "none"}
+	wsvg, w, h, err := wordsToSVG([]string{"hello", "adora"}, opts)
+	if err != nil {
+		t.Fatalf("wordsToSVG error: %v", err)
+	}
+	sc, err := wordsScene(wsvg, w, h)
+	if err != nil {
+		t.Fatalf("wordsScene error: %v", err)
+	}
+	rings := outlineShapes(sc.shapes, 0)
+	area := 0.0
+	for _, r := range rings {
+		area += ringArea(r)
+	}
+	min, max := ringsBounds(rings)
+
+	cases := []stlOptions{
+		{relief: 1, plate: 2, margin: 3},
+		{relief: 1.5, plate: 0, margin: 3},
+		{relief: 1, plate: 2, margin: 3, stamp: true},
+		{relief: 1, plate: 1, margin: 2, round: true},
+	}
+	for _, so := range cases {
+		facets := extrude(rings, so)
+		checkClosed(t, facets)
+
+		// The size of the plate, from the origin.
+		size := point{max.x - min.x, max.y - min.y}
+		plateArea := 0.0
+		switch {
+		case so.round:
+			d := dist(min, max) + 2*so.margin
+			size = point{d, d}
+			plateArea = math.Pi * d * d / 4
+		case so.plate > 0:
+			size = point{size.x + 2*so.margin, size.y + 2*so.margin}
+			plateArea = size.x * size.y
+		}
+		fmin := point3{math.Inf(1), math.Inf(1), math.Inf(1)}
+		fmax := point3{math.Inf(-1), math.Inf(-1), math.Inf(-1)}
+		for _, f := range facets {
+			for _, p := range f {
+				fmin = point3{math.Min(fmin.x, p.x), math.Min(fmin.y, p.y),
+					math.Min(fmin.z, p.z)}
+				fmax = point3{math.Max(fmax.x, p.x), math.Max(fmax.y, p.y),
+					math.Max(fmax.z, p.z)}
+			}
+		}
+		expMax := point3{size.x, size.y, so.plate + so.relief}
+		if math.Abs(fmin.x)+math.Abs(fmin.y)+math.Abs(fmin.z) > 1e-9 ||
+			math.Abs(fmax.x-expMax.x)+math.Abs(fmax.y-expMax.y)+
+				math.Abs(fmax.z-expMax.z) > 1e-3 {
+			t.Errorf("%+v: got bounds %v - %v, expected 0 - %v", so, fmin,
+				fmax, expMax)
+		}
+
+		// The round plate is a polygon, a bit smaller than the circle.
+		expected := plateArea*so.plate + area*so.relief
+		if v := volume(facets); math.Abs(v-expected) > expected*1e-3 {
+			t.Errorf("%+v: got volume %v, expected %v", so, v, expected)
+		}
+	}
+}
+
+func TestWriteSTL(t *testing.T) {
+	facets := []facet{
+		{{0, 0, 0}, {1, 0, 0}, {0, 1, 0}},
+		{{0, 0, 0}, {0, 1, 0}, {0, 0, -1.5}},
+	}
+
+	buf := &bytes.Buffer{}
+	writeBinarySTL(buf, facets)
+	b := buf.Bytes()
+	if len(b) != 84+2*50 {
+		t.Fatalf("binary STL has %d bytes, expected %d", len(b), 84+2*50)
+	}
+	if strings.HasPrefix(string(b), "solid") {
+		t.Errorf("binary STL starts with \"solid\"")
+	}
+	if n := binary.LittleEndian.Uint32(b[80:]); n != 2 {
+		t.Errorf("binary STL has %d facets, expected 2", n)
+	}
+	floats := []float32{}
+	for i := 84 + 50; i < 84+50+48; i += 4 {
+		floats = append(floats,
+			math.Float32frombits(binary.LittleEndian.Uint32(b[i:])))
+	}
+	expected := []float32{-1, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, -1.5}
+	if !slices.Equal(floats, expected) {
+		t.Errorf("second facet: got %v, expected %v", floats, expected)
+	}
+
+	buf.Reset()
+	writeASCIISTL(buf, facets[:1])
+	expectedASCII := "solid firstones\n" +
+		"facet normal 0 0 1\n" +
+		"  outer loop\n" +
+		"    vertex 0 0 0\n" +
+		"    vertex 1 0 0\n" +
+		"    vertex 0 1 0\n" +
+		"  endloop\n" +
+		"endfacet\n" +
+		"endsolid firstones\n"
+	if got := buf.String(); got != expectedASCII {
+		t.Errorf("ASCII STL: got %q, expected %q", got, expectedASCII)
+	}
+}
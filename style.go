@@ -0,0 +1,261 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image/color"
+	"maps"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// # Styles
+//
+// The style controls the colors and strokes of the rendered words. It can
+// be given as a named theme (see themes), and each of its values can be
+// overridden individually. The same names are used for the command line
+// flags and for the HTTP parameters:
+//
+//   - theme: the base theme.
+//   - stroke: color of the glyph strokes.
+//   - fill: color of the filled parts of the glyphs (the dots and solid
+//     shapes), by default the same as the stroke.
+//   - line: color of the word line, and its marks (stress, sentence ends),
+//     by default the same as the stroke.
+//   - background: color of the background, or "none" for transparent.
+//   - stroke-width: width of the strokes, in mm. Stressed syllables are
+//     proportionally thicker (see stressWidth).
+//   - linecap: butt, round, or square.
+//   - linejoin: miter, round, or bevel.
+//   - effect: a visual effect (see effects), or "none".
+//
+// Colors are validated with parseColor, which also ensures they are safe to
+// include in the SVG.
+
+type style struct {
+	stroke     string
+	fill       string
+	line       string
+	background string
+	width      float64
+
+	// Line caps and joins. Empty means the SVG default (butt, and miter).
+	linecap  string
+	linejoin string
+
+	// Visual effect, see effects. Empty (or "none") means no effect.
+	effect string
+}
+
+// Named themes. The "default" theme is used when none is given.
+var themes = map[string]style{
+	"default": {
+		stroke:     "orange",
+		background: "none",
+		width:      0.5,
+	},
+	"gold": {
+		stroke:     "#d4af37",
+		fill:       "#f5d76e",
+		line:       "#b8860b",
+		background: "black",
+		width:      0.5,
+		linecap:    "round",
+		linejoin:   "round",
+	},
+	"dark": {
+		stroke:     "#e6e6e6",
+		line:       "#9e9e9e",
+		background: "#1e1e1e",
+		width:      0.5,
+		linecap:    "round",
+		linejoin:   "round",
+	},
+	"engraving": {
+		stroke:     "black",
+		background: "white",
+		width:      0.35,
+		linecap:    "square",
+		linejoin:   "miter",
+	},
+	"runestone": {
+		stroke:     "#4a4540",
+		line:       "#3b3733",
+		background: "#8c877f",
+		width:      0.7,
+		linecap:    "round",
+		linejoin:   "round",
+		effect:     "carved",
+	},
+}
+
+// Maximum stroke width, in mm. Beyond that, the glyphs become blobs.
+const maxStrokeWidth = 3
+
+var (
+	themeFlag = flag.String("theme", "",
+		"style theme: "+strings.Join(themeNames(), ", ")+
+			"; the other style flags override its values")
+	strokeFlag = flag.String("stroke", "",
+		"color of the glyph strokes (e.g. \"orange\", \"#d4af37\")")
+	fillFlag = flag.String("fill", "",
+		"color of the filled parts of the glyphs; by default, the stroke "+
+			"color")
+	lineFlag = flag.String("line", "",
+		"color of the word line; by default, the stroke color")
+	backgroundFlag = flag.String("background", "",
+		"background color, or \"none\" for transparent")
+	strokeWidthFlag = flag.String("stroke-width", "",
+		"width of the strokes, in mm")
+	linecapFlag = flag.String("linecap", "",
+		"shape of the end of the strokes: butt, round, or square")
+	linejoinFlag = flag.String("linejoin", "",
+		"shape of the corners of the strokes: miter, round, or bevel")
+	effectFlag = flag.String("effect", "",
+		"visual effect: "+strings.Join(effectNames(), ", ")+", or none")
+)
+
+// themeNames returns the names of the themes, sorted.
+func themeNames() []string {
+	return slices.Sorted(maps.Keys(themes))
+}
+
+// styleFlagValues returns the style flags that were given, as URL values
+// (see style.applyValues).
+func styleFlagValues() url.Values {
+	v := url.Values{}
+	for name, value := range map[string]string{
+		"theme":        *themeFlag,
+		"stroke":       *strokeFlag,
+		"fill":         *fillFlag,
+		"line":         *lineFlag,
+		"background":   *backgroundFlag,
+		"stroke-width": *strokeWidthFlag,
+		"linecap":      *linecapFlag,
+		"linejoin":     *linejoinFlag,
+		"effect":       *effectFlag,
+	} {
+		if value != "" {
+			v.Set(name, value)
+		}
+	}
+	return v
+}
+
+// applyValues overrides the style with the values given (see the list
+// above). The theme is applied first, and the other values on top of it.
+func (s *style) applyValues(v url.Values) error {
+	if name := v.Get("theme"); name != "" {
+		t, ok := themes[name]
+		if !ok {
+			return fmt.Errorf("unknown theme %q, must be one of: %s",
+				name, strings.Join(themeNames(), ", "))
+		}
+		*s = t
+	}
+
+	for name, dst := range map[string]*string{
+		"stroke":     &s.stroke,
+		"fill":       &s.fill,
+		"line":       &s.line,
+		"background": &s.background,
+	} {
+		if c := v.Get(name); c != "" {
+			c, err := checkColor(c)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %v", name, err)
+			}
+			*dst = c
+		}
+	}
+
+	if w := v.Get("stroke-width"); w != "" {
+		width, err := strconv.ParseFloat(w, 64)
+		if err != nil || width <= 0 || width > maxStrokeWidth {
+			return fmt.Errorf(
+				"invalid stroke-width %q, must be a number between 0 "+
+					"and %d", w, maxStrokeWidth)
+		}
+		s.width = width
+	}
+	if c := v.Get("linecap"); c != "" {
+		if !slices.Contains([]string{"butt", "round", "square"}, c) {
+			return fmt.Errorf("invalid linecap %q", c)
+		}
+		s.linecap = c
+	}
+	if j := v.Get("linejoin"); j != "" {
+		if !slices.Contains([]string{"miter", "round", "bevel"}, j) {
+			return fmt.Errorf("invalid linejoin %q", j)
+		}
+		s.linejoin = j
+	}
+	if e := v.Get("effect"); e != "" {
+		if _, ok := effects[e]; !ok && e != "none" {
+			return fmt.Errorf("unknown effect %q, must be one of: %s, none",
+				e, strings.Join(effectNames(), ", "))
+		}
+		s.effect = e
+	}
+	return nil
+}
+
+// checkColor checks that the color is valid, and returns it normalized.
+func checkColor(c string) (string, error) {
+	c = strings.ToLower(strings.TrimSpace(c))
+	if c == "none" || c == "transparent" {
+		return c, nil
+	}
+	if _, ok := parseColor(c, color.NRGBA{}); !ok {
+		return "", fmt.Errorf("unknown color %q", c)
+	}
+	return c, nil
+}
+
+// resolved returns the style with the missing values filled in: from the
+// default theme, and the fill and line colors from the stroke.
+func (s style) resolved() style {
+	def := themes["default"]
+	if s.stroke == "" {
+		s.stroke = def.stroke
+	}
+	if s.fill == "" {
+		s.fill = s.stroke
+	}
+	if s.line == "" {
+		s.line = s.stroke
+	}
+	if s.background == "" {
+		s.background = def.background
+	}
+	if s.width == 0 {
+		s.width = def.width
+	}
+	return s
+}
+
+// group wraps the SVG in a group with the given colors and stroke width,
+// and the line caps and joins of the style. The fill color is given as the
+// "color", since the glyphs use "currentcolor" for their filled parts.
+func (s style) group(stroke, fill string, width float64, svg SVG) SVG {
+	attrs := SVGf(`color="%s" stroke="%s" stroke-width="%g"`,
+		fill, stroke, width)
+	if s.linecap != "" {
+		attrs += SVGf(` stroke-linecap="%s"`, s.linecap)
+	}
+	if s.linejoin != "" {
+		attrs += SVGf(` stroke-linejoin="%s"`, s.linejoin)
+	}
+	return SVG("<g "+attrs+">\n") + indent(svg, 2) + SVG("</g>\n")
+}
+
+// backgroundSVG returns the background of the image, if it has one.
+func (s style) backgroundSVG() SVG {
+	if s.background == "none" || s.background == "transparent" {
+		return ""
+	}
+	return SVGfn(`<rect width="100%%" height="100%%" fill="%s" />`,
+		s.background)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"image/color"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestStyleApplyValues(t *testing.T) {
+	cases := []struct {
+		values   string
+		expected style
+	}{
+		{"", style{}},
+		{"theme=engraving", themes["engraving"]},
+		{"theme=gold&stroke=Red&stroke-width=1.5&linecap=butt",
+			style{
+				stroke: "red", fill: "#f5d76e", line: "#b8860b",
+				background: "black", width: 1.5,
+				linecap: "butt", linejoin: "round",
+			}},
+		{"fill=%23fff&background=none&linejoin=bevel",
+			style{fill: "#fff", background: "none", linejoin: "bevel"}},
+	}
+	for _, c := range cases {
+		v, _ := url.ParseQuery(c.values)
+		got := style{}
+		if err := got.applyValues(v); err != nil {
+			t.Errorf("%q: error: %v", c.values, err)
+			continue
+		}
+		if got != c.expected {
+			t.Errorf("%q: got %+v, expected %+v", c.values, got, c.expected)
+		}
+	}
+
+	invalid := []string{
+		"theme=nope",
+		"stroke=blurple",
+		"line=red%22%3E%3Cscript%3E",
+		"stroke-width=0",
+		"stroke-width=10",
+		"stroke-width=x",
+		"linecap=pointy",
+		"linejoin=butt",
+	}
+	for _, s := range invalid {
+		v, _ := url.ParseQuery(s)
+		st := style{}
+		if err := st.applyValues(v); err == nil {
+			t.Errorf("%q: expected error, got %+v", s, st)
+		}
+	}
+}
+
+func TestStyleResolved(t *testing.T) {
+	got := style{}.resolved()
+	expected := style{stroke: "orange", fill: "orange", line: "orange",
+		background: "none", width: 0.5}
+	if got != expected {
+		t.Errorf("got %+v, expected %+v", got, expected)
+	}
+
+	got = style{stroke: "red", line: "blue"}.resolved()
+	if got.fill != "red" || got.line != "blue" {
+		t.Errorf("unexpected colors: %+v", got)
+	}
+}
+
+func TestStyledSVG(t *testing.T) {
+	opts := Options{syllables: "manual", angle: defaultAngle,
+		sentences: "none", stress: true}
+	opts.style.applyValues(url.Values{
+		"theme": {"dark"}, "line": {"#123456"}, "stroke-width": {"1"},
+	})
+	svg, err := genSVG([]string{"hello"}, opts, false)
+	if err != nil {
+		t.Fatalf("genSVG error: %v", err)
+	}
+	for _, s := range []string{
+		`<rect width="100%" height="100%" fill="#1e1e1e" />`,
+		`<g color="#123456" stroke="#123456" stroke-width="1" ` +
+			`stroke-linecap="round" stroke-linejoin="round">`,
+		// Stressed syllable, 1.6 times the width.
+		`<g color="#e6e6e6" stroke="#e6e6e6" stroke-width="1.6" `,
+	} {
+		if !strings.Contains(svg, s) {
+			t.Errorf("SVG does not contain %q", s)
+		}
+	}
+
+	// The background and colors should make it to the scene too.
+	doc := &document{svg: svg}
+	sc, err := doc.scene()
+	if err != nil {
+		t.Fatalf("scene error: %v", err)
+	}
+	bg := sc.shapes[0]
+	if bg.fill != (color.NRGBA{0x1e, 0x1e, 0x1e, 0xff}) ||
+		bg.subpaths[0].points[2] != (point{sc.width, sc.height}) {
+		t.Errorf("unexpected background: %+v", bg)
+	}
+	if sc.shapes[1].linecap != "round" || sc.shapes[1].linejoin != "round" {
+		t.Errorf("unexpected caps and joins: %+v", sc.shapes[1])
+	}
+}
+
+func TestStrokeParts(t *testing.T) {
+	// An "L", 2 units wide.
+	sp := subpath{points: []point{{0, 0}, {10, 0}, {10, 10}}}
+	inside := func(s shape, p point) bool {
+		polys, disks := s.strokeParts()
+		for _, d := range disks {
+			dx, dy := p.x-d.center.x, p.y-d.center.y
+			if dx*dx+dy*dy <= d.r*d.r {
+				return true
+			}
+		}
+		for _, poly := range polys {
+			if windingNumber([]subpath{poly}, p) != 0 {
+				return true
+			}
+		}
+		return false
+	}
+
+	cases := []struct {
+		linecap, linejoin string
+		p                 point
+		expected          bool
+	}{
+		// On the segments.
+		{"butt", "miter", point{5, 0.5}, true},
+		{"butt", "miter", point{5, 1.5}, false},
+		// Beyond the start: covered by the square and round caps only.
+		{"butt", "miter", point{-0.5, 0.5}, false},
+		{"square", "miter", point{-0.5, 0.5}, true},
+		{"round", "miter", point{-0.5, 0.5}, true},
+		{"round", "miter", point{-0.9, 0.9}, false},
+		// The outer corner: only the miter covers it.
+		{"butt", "miter", point{10.9, -0.9}, true},
+		{"butt", "bevel", point{10.9, -0.9}, false},
+		{"butt", "round", point{10.9, -0.9}, false},
+		{"butt", "bevel", point{10.4, -0.4}, true},
+	}
+	for _, c := range cases {
+		s := shape{subpaths: []subpath{sp}, strokeWidth: 2,
+			linecap: c.linecap, linejoin: c.linejoin}
+		if got := inside(s, c.p); got != c.expected {
+			t.Errorf("%s/%s: %v inside = %v, expected %v",
+				c.linecap, c.linejoin, c.p, got, c.expected)
+		}
+	}
+}
@@ -62,12 +62,31 @@ func rotate(angle int, svg SVG) SVG {
 		indent(svg, 2) + SVG("</g>\n")
 }
 
-func color(color string, svg SVG) SVG {
+func colored(color string, svg SVG) SVG {
+	return stroke(color, 0.5, svg)
+}
+
+func stroke(color string, width float64, svg SVG) SVG {
 	return SVGfn(
-		`<g color="%s" stroke="%s" stroke-width="0.5">`, color, color) +
+		`<g color="%s" stroke="%s" stroke-width="%g">`, color, color, width) +
 		indent(svg, 2) + SVG("</g>\n")
 }
 
+// Stroke width for the glyphs of stressed syllables, when we show the
+// stress, relative to the normal width (see style.width).
+var stressWidth = map[Stress]float64{
+	unstressed:      1,
+	secondaryStress: 1.3,
+	primaryStress:   1.6,
+}
+
+// Radius of the mark on the word line for stressed syllables, when we show
+// the stress.
+var stressMarkRadius = map[Stress]float64{
+	secondaryStress: 0.8,
+	primaryStress:   1.2,
+}
+
 func vertLine(l int) SVG {
 	return SVGf(
 		`<line x1="0" y1="0" x2="0" y2="%d" />`, l)
@@ -121,25 +140,35 @@ func writeDefs(w io.Writer) {
 	fmt.Fprintln(w, `</defs>`)
 }
 
-func syllableToSVG(syllable Syllable) SVG {
+func syllableToSVG(syllable Syllable, opts Options) SVG {
 	svg := SVGf("<g> <!-- Syllable: %v -->\n", syllable)
 	height := 0
 
+	st := opts.style.resolved()
+
 	// Was the previous glyph a connector?
 	prevConnector := false
 	for _, glyph := range syllable {
+		// The width comes from the stress of each glyph, since the
+		// syllable may cover more than one of the pronunciation (see
+		// Syllable.stress).
+		width := st.width
+		if opts.stress {
+			width *= stressWidth[glyph.stress]
+		}
+
 		if !glyph.connector && !prevConnector {
 			// If the glyph is not a connector, and the previous one was not a
 			// connector either, we need to draw a vertical line to connect it
 			// to the previous glyph (or the word branch).
-			svg += color("orange",
+			svg += st.group(st.glyphStroke(), st.fill, width,
 				move(0, height,
 					vertLine(3)),
 			)
 			height += 3
 		}
 
-		svg += color("orange",
+		svg += st.group(st.glyphStroke(), st.fill, width,
 			move(0, height,
 				glyph.svg),
 		)
@@ -161,7 +190,7 @@ const (
 	// How much space we leave between each word?
 	wordSpacing = 10
 
-	// How much space we leave at the top?
+	// How much space we leave at the top (and at the bottom)?
 	// This is so elements on the border don't end up getting chopped.
 	topMargin = 5
 )
@@ -196,21 +225,37 @@ func (wl WordLine) LenX() float64 {
 	return math.Cos(rad) * float64(totalLength)
 }
 
-func wordLineSVG(n int) WordLine {
+// sentenceEndSVG returns the mark for the end of a sentence, a ring around
+// the end of the word line.
+func (wl WordLine) sentenceEndSVG() SVG {
+	startX := -wl.nsyllables * syllableSpacing
+	return rotate(wl.angle, SVGfn(
+		`<circle cx="%d" cy="0" r="2" fill="none" /> <!-- Sentence end -->`,
+		startX))
+}
+
+// Default angle of the word line, in degrees.
+//
+// Angles in published media:
+//   - Official PDF: 23°, 29.5°, 24.5°, 24°
+//   - "Happy new year": 30°, 27.5°
+//   - "April fools": 23°
+const defaultAngle = -12
+
+// Maximum angle of the word line (in either direction), beyond that the
+// glyphs of the syllables overlap.
+const maxAngle = 45
+
+func wordLineSVG(n, angle int) WordLine {
 	// Draw the slanted line for the word branch.
 	// n is how many syllables we will have, and determines the length of
 	// the line.
 	wl := WordLine{
 		nsyllables: n,
-
-		// Angles in published media:
-		//  - Official PDF: 23°, 29.5°, 24.5°, 24°
-		//  - "Happy new year": 30°, 27.5°
-		//  - "April fools": 23°
-		angle: -12,
+		angle:      angle,
 	}
 
-	wl.svg = SVG("<g> <!-- Word line -->\n")
+	wl.svg = SVG("<g class=\"word-line\"> <!-- Word line -->\n")
 
 	// The line sits at Y=0.
 	// X goes from -(n * syllableSpacing) to 0: because we draw the text
@@ -230,49 +275,63 @@ func wordLineSVG(n int) WordLine {
 	return wl
 }
 
-func wordsWidthHeight(words []Word) (int, int) {
-	// Calculate how wide and tall the words will be in the SVG.
-	// Doesn't have to be super accurate (and isn't due to the slanting), it's
-	// used to size the general canvas, and compute the starting position.
+// wordsWidthHeight returns how wide and tall the words will be in the SVG,
+// with the word lines at the given angle, and the Y where the word lines
+// start.
+// The width doesn't have to be super accurate (and isn't due to the
+// slanting), it's used to size the general canvas, and compute the starting
+// position. The height takes the slant into account, since steep word lines
+// go well above or below their start.
+func wordsWidthHeight(words []Word, angle int) (int, int, float64) {
 	width := 0
-	height := 0
-	maxSyllables := 0
+	top, bottom := 0.0, 0.0
 	for _, word := range words {
-		if len(word) > maxSyllables {
-			maxSyllables = len(word)
+		// Sentence ends get an extra gap (in "mark" mode it's not needed,
+		// but it doesn't hurt to have a bit more room).
+		if word.isSentenceEnd() {
+			width += wordSpacing
+			continue
 		}
 
 		width += syllableSpacing * len(word)
 		width += wordSpacing
 
-		for _, syllable := range word {
-			sh := 0
-			prevC := false
-			for _, glyph := range syllable {
-				sh += glyph.height
-				if !glyph.connector && !prevC {
-					// Connector line.
-					sh += 3
-				}
-				prevC = glyph.connector
-			}
-			if sh > height {
-				height = sh
-			}
+		// The word line goes from Y=0 to the end, and the syllables hang
+		// from it.
+		wl := WordLine{nsyllables: len(word), angle: angle}
+		rad := float64(angle) * math.Pi / 180
+		end := -math.Sin(rad) * float64(len(word)*syllableSpacing)
+		top, bottom = min(top, end), max(bottom, end)
+		for i, syllable := range word {
+			_, y := wl.offsetFor(i)
+			top = min(top, y)
+			bottom = max(bottom, y+float64(syllableHeight(syllable)))
 		}
 	}
 
-	// We start at Y=topMargin, so we add that to the height.
-	height += topMargin
-
-	// Leave some margin for the rotation.  This is not accurate, but works
-	// for reasonable syllable lengths.
-	height += 5 * maxSyllables
+	// Leave a margin at the top and at the bottom.
+	y := topMargin - top
+	height := int(math.Ceil(y + bottom + topMargin))
+	return width, height, y
+}
 
-	return width, height
+// syllableHeight returns how tall the syllable is, including the
+// connector lines.
+func syllableHeight(syllable Syllable) int {
+	sh := 0
+	prevC := false
+	for _, glyph := range syllable {
+		sh += glyph.height
+		if !glyph.connector && !prevC {
+			// Connector line.
+			sh += 3
+		}
+		prevC = glyph.connector
+	}
+	return sh
 }
 
-func wordsToSVG(words []string) (SVG, int, int, error) {
+func wordsToSVG(words []string, opts Options) (SVG, int, int, error) {
 	for _, word := range words {
 		// The word is user-provided. Check it doesn't contain any problematic
 		// characters that would cause issues in the SVG.
@@ -285,41 +344,50 @@ func wordsToSVG(words []string) (SVG, int, int, error) {
 	svg := SVGfn("<!-- Words: %v -->", words)
 
 	// wordsG contains the words, as syllables of Glyphs.
-	wordsG := []Word{}
-	for _, word := range words {
-		wordG, err := smartWordToGlyphs(word)
-		if err != nil {
-			return svg, 0, 0, fmt.Errorf(
-				"error converting %q to glyphs: %v", word, err)
-		}
-
-		if len(wordG) == 0 {
-			// Skip empty words, this can happen with words that contain just
-			// "-" or "/".
-			continue
-		}
-
-		wordsG = append(wordsG, wordG)
+	wordsG, err := wordsToGlyphs(words, opts)
+	if err != nil {
+		return svg, 0, 0, err
 	}
 
 	// The language is right to left, so we compute the total width, and start
 	// there (+ some margin) and go backwards.
-	width, height := wordsWidthHeight(wordsG)
+	width, height, y := wordsWidthHeight(wordsG, opts.angle)
 	x := float64(width)
+	st := opts.style.resolved()
+
+	for i, wordG := range wordsG {
+		if wordG.isSentenceEnd() {
+			if opts.sentences == "gap" {
+				svg += SVGfn("<!-- Sentence end -->")
+				x -= wordSpacing
+			}
+			continue
+		}
 
-	for _, wordG := range wordsG {
 		svg += SVGfn("<!-- Glyphs for %v -->", wordG)
 
-		wl := wordLineSVG(len(wordG))
+		wl := wordLineSVG(len(wordG), opts.angle)
 		wsvg := wl.svg
+		if opts.sentences == "mark" &&
+			i+1 < len(wordsG) && wordsG[i+1].isSentenceEnd() {
+			wsvg += wl.sentenceEndSVG()
+		}
 		for i, syllable := range wordG {
 			offx, offy := wl.offsetFor(i)
 			wsvg += movef(offx, offy,
-				syllableToSVG(syllable))
+				syllableToSVG(syllable, opts))
+
+			if r, ok := stressMarkRadius[syllable.stress()]; ok && opts.stress {
+				// Mark the stressed syllable on the word line.
+				wsvg += SVGfn(
+					`<circle cx="%g" cy="%g" r="%g" fill="currentcolor" />`+
+						` <!-- Stress: %v -->`,
+					offx, offy, r, syllable.stress())
+			}
 		}
 
-		svg += movef(x, topMargin,
-			color("orange", wsvg))
+		svg += movef(x, y,
+			st.group(st.line, st.line, st.width, wsvg))
 
 		x -= wl.LenX()
 		x -= wordSpacing
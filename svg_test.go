@@ -1,6 +1,18 @@
 package main
 
-import "testing"
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var updateGolden = flag.Bool("update-golden", false,
+	"write the golden files, instead of comparing with them")
 
 func TestSVGf(t *testing.T) {
 	type Case struct {
@@ -42,3 +54,86 @@ func expectSVGfPanic(t *testing.T, i int, f string, args []interface{}) {
 	}()
 	SVGf(f, args...)
 }
+
+// TestAngleGolden checks the SVG for steep word lines, in both directions,
+// against the goldens in test/golden/angle. The canvas must fit them: word
+// lines going up must not go above the top, and the syllables of the ones
+// going down must not be cut at the bottom.
+func TestAngleGolden(t *testing.T) {
+	words := []string{"Entrapta", "Glimmer"}
+	for _, angle := range []int{30, -maxAngle} {
+		opts := Options{syllables: "auto", angle: angle, sentences: "none"}
+		svg, err := genSVG(words, opts, false)
+		if err != nil {
+			t.Fatalf("%d: genSVG error: %v", angle, err)
+		}
+
+		sc, err := parseScene(svg)
+		if err != nil {
+			t.Fatalf("%d: parseScene error: %v", angle, err)
+		}
+		min, max := sceneBounds(sc)
+		if min.x < 0 || min.y < 0 || max.x > sc.width || max.y > sc.height {
+			t.Errorf("%d: shapes from %v to %v, outside the %vx%v canvas",
+				angle, min, max, sc.width, sc.height)
+		}
+
+		path := filepath.Join("test", "golden", "angle",
+			fmt.Sprintf("%d.svg", angle))
+		if *updateGolden {
+			if err := os.WriteFile(path, []byte(svg), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		expected, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(string(expected), svg); diff != "" {
+			t.Errorf("%d: differs from %s (-expected +got):\n%s", angle,
+				path, diff)
+		}
+	}
+}
+
+func TestSyllableStress(t *testing.T) {
+	g := func(st Stress) Glyph { return Glyph{stress: st} }
+	cases := []struct {
+		syllable Syllable
+		expected Stress
+	}{
+		{Syllable{}, unstressed},
+		{Syllable{g(primaryStress), g(primaryStress)}, primaryStress},
+		{Syllable{g(secondaryStress)}, secondaryStress},
+		// Covers more than one syllable of the pronunciation.
+		{Syllable{g(unstressed), g(primaryStress)}, unstressed},
+	}
+	for _, c := range cases {
+		if got := c.syllable.stress(); got != c.expected {
+			t.Errorf("%v: got %v, expected %v", c.syllable, got,
+				c.expected)
+		}
+	}
+}
+
+// With manual syllables, a word is a single syllable, so only the glyphs of
+// the stressed syllable of the pronunciation are wider.
+func TestManualSyllableStress(t *testing.T) {
+	opts := Options{syllables: "manual", angle: defaultAngle,
+		sentences: "none", stress: true}
+	svg, err := genSVG([]string{"about"}, opts, false)
+	if err != nil {
+		t.Fatalf("genSVG error: %v", err)
+	}
+	for _, s := range []string{
+		`stroke-width="0.5"`, `stroke-width="0.8"`,
+	} {
+		if !strings.Contains(svg, s) {
+			t.Errorf("SVG does not contain %q", s)
+		}
+	}
+	if strings.Contains(svg, "<!-- Stress:") {
+		t.Errorf("unexpected stress mark on the word line")
+	}
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// # Syllabification
+//
+// The dictionaries give us the pronunciation of a word as a sequence of IPA
+// symbols, but they don't tell us where the syllables are (except for the
+// stress marks, which appear at the beginning of the stressed syllables).
+//
+// To find the syllables, we use the sonority sequencing principle together
+// with the maximal onset principle:
+//
+//   - Every vowel (or diphthong) is the nucleus of a syllable.
+//   - The consonants between two nuclei are split so that the second
+//     syllable gets the longest valid onset. An onset is valid if it rises in
+//     sonority towards the nucleus (e.g. "tɹ", "pl"), with the usual exception
+//     of "s" + stop clusters (e.g. "stɹ" in "extra").
+//   - Stress marks and "." are explicit syllable boundaries, so when present
+//     they take precedence over the heuristics above.
+//
+// This is not perfect (English syllabification is famously contentious), but
+// it's good enough for visual purposes.
+
+// Syllable modes, which control how words are split into syllables.
+var syllableModes = map[string]string{
+	"manual": `split only where there is a "/"`,
+	"anchor": `split where there is a "/", moved to the closest syllable`,
+	"auto":   `split on every syllable`,
+}
+
+// Sonority classes of the IPA consonants. Anything that isn't here and isn't
+// a vowel is considered to have the lowest sonority.
+const (
+	sonStop = iota + 1
+	sonAffricate
+	sonFricative
+	sonNasal
+	sonLiquid
+	sonGlide
+)
+
+var ipaSonority = map[string]int{
+	"p": sonStop, "b": sonStop, "t": sonStop, "d": sonStop,
+	"k": sonStop, "g": sonStop, "ɡ": sonStop, "ʔ": sonStop,
+
+	"tʃ": sonAffricate, "dʒ": sonAffricate,
+
+	"f": sonFricative, "v": sonFricative, "θ": sonFricative,
+	"ð": sonFricative, "s": sonFricative, "z": sonFricative,
+	"ʃ": sonFricative, "ʒ": sonFricative, "h": sonFricative,
+	"x": sonFricative, "β": sonFricative, "ɣ": sonFricative,
+	"ʝ": sonFricative,
+
+	"m": sonNasal, "n": sonNasal, "ɲ": sonNasal, "ŋ": sonNasal,
+
+	"l": sonLiquid, "ɫ": sonLiquid, "ʎ": sonLiquid, "ɹ": sonLiquid,
+	"ɾ": sonLiquid, "r": sonLiquid,
+
+	"j": sonGlide, "w": sonGlide,
+}
+
+// IPA vowels. Diphthongs are handled by ipaSegments, which groups them into
+// a single segment.
+const ipaVowels = "aeiouyæɐɑɒɔəɘɛɜɝɞɨɪʉʊʌʏøœɤɯ"
+
+// Second half of diphthongs that are not in ipaToGlyphs2, and so appear as
+// two separate vowels (e.g. "eɪ" in "eighty").
+const ipaOffglides = "ɪʊ"
+
+func isIPAVowel(seg string) bool {
+	r := []rune(seg)
+	return len(r) > 0 && strings.ContainsRune(ipaVowels, r[0])
+}
+
+// Stress of a syllable, as given by the stress marks in the IPA.
+type Stress int
+
+const (
+	unstressed Stress = iota
+	secondaryStress
+	primaryStress
+)
+
+func (s Stress) String() string {
+	switch s {
+	case primaryStress:
+		return "primary"
+	case secondaryStress:
+		return "secondary"
+	}
+	return ""
+}
+
+// An IPA syllable, as a sequence of IPA segments.
+type ipaSyllable struct {
+	segments []string
+	stress   Stress
+}
+
+func (s ipaSyllable) String() string {
+	return strings.Join(s.segments, "")
+}
+
+// ipaSegments splits an IPA string into segments, each corresponding to a
+// single glyph. The two-symbol sequences from ipaToGlyphs2 (e.g. "tʃ", "aɪ")
+// are kept together.
+// Stress marks and syllable separators are returned as their own segments.
+func ipaSegments(ipa string) []string {
+	ipaR := []rune(ipa)
+	segs := []string{}
+	for i := 0; i < len(ipaR); i++ {
+		if i+1 < len(ipaR) {
+			s := string(ipaR[i]) + string(ipaR[i+1])
+			if _, ok := ipaToGlyphs2[s]; ok {
+				segs = append(segs, s)
+				i++
+				continue
+			}
+		}
+		segs = append(segs, string(ipaR[i]))
+	}
+	return segs
+}
+
+// Syllable marks, which indicate a syllable boundary, and the stress of the
+// syllable that follows.
+var syllableMarks = map[string]Stress{
+	"ˈ": primaryStress,
+	"ˌ": secondaryStress,
+	".": unstressed,
+}
+
+// syllabifyIPA splits the IPA pronunciation of a word into syllables.
+// The syllable marks (stress and ".") are removed from the result, and the
+// stress is recorded in the syllables instead.
+func syllabifyIPA(ipa string) []ipaSyllable {
+	// Separate the syllable marks from the segments, remembering where they
+	// were, as they are hints of syllable boundaries.
+	segs := []string{}
+	marked := map[int]bool{}
+	stress := map[int]Stress{}
+	for _, seg := range ipaSegments(ipa) {
+		if st, ok := syllableMarks[seg]; ok {
+			marked[len(segs)] = true
+			stress[len(segs)] = max(stress[len(segs)], st)
+			continue
+		}
+		segs = append(segs, seg)
+	}
+
+	// Find the nuclei. Adjacent vowels are separate nuclei, unless they form
+	// a diphthong.
+	nuclei := []int{}
+	for i, seg := range segs {
+		if !isIPAVowel(seg) {
+			continue
+		}
+		if i > 0 && isIPAVowel(segs[i-1]) && !marked[i] &&
+			strings.Contains(ipaOffglides, seg) {
+			continue
+		}
+		nuclei = append(nuclei, i)
+	}
+
+	// Find where each syllable starts, by looking at the consonants between
+	// each pair of nuclei.
+	starts := []int{0}
+	for k := 1; k < len(nuclei); k++ {
+		prev, next := nuclei[k-1]+1, nuclei[k]
+		for prev < next && isIPAVowel(segs[prev]) {
+			// Skip over the second half of the diphthongs.
+			prev++
+		}
+
+		start := -1
+		for j := next; j >= prev; j-- {
+			if marked[j] {
+				start = j
+				break
+			}
+		}
+		if start < 0 {
+			start = next
+			for start > prev && validOnset(segs[start-1:next]) {
+				start--
+			}
+		}
+		starts = append(starts, start)
+	}
+
+	syllables := []ipaSyllable{}
+	for i, start := range starts {
+		end := len(segs)
+		if i+1 < len(starts) {
+			end = starts[i+1]
+		}
+		if end <= start {
+			continue
+		}
+		syllables = append(syllables, ipaSyllable{
+			segments: segs[start:end],
+			stress:   stress[start],
+		})
+	}
+	return syllables
+}
+
+// validOnset returns true if the given consonants can begin a syllable.
+func validOnset(cs []string) bool {
+	switch len(cs) {
+	case 0:
+		return true
+	case 1:
+		// "ŋ" can't begin a syllable (e.g. "sɪŋɝ" is "sɪŋ.ɝ").
+		return cs[0] != "ŋ"
+	}
+
+	// "s" + stop or nasal clusters, e.g. "street", "small".
+	if cs[0] == "s" {
+		son := ipaSonority[cs[1]]
+		if son == sonStop || son == sonNasal {
+			return validOnset(cs[1:])
+		}
+	}
+
+	// Otherwise, we only allow a consonant followed by a liquid or glide,
+	// with rising sonority (e.g. "tɹ", "pl", "kw", "fj").
+	if len(cs) > 2 {
+		return false
+	}
+	first, second := ipaSonority[cs[0]], ipaSonority[cs[1]]
+	return second >= sonLiquid && first < second
+}
+
+// anchorSlashes finds where to split the glyphs, given the indices of the
+// slashes in the original word (see findSlashes), and the glyph indices at
+// which each syllable begins.
+// Each slash is mapped proportionally to the glyphs, and then moved to the
+// closest syllable boundary.
+func anchorSlashes(nglyphs int, slashes []int, wlen int, bounds []int) []int {
+	if len(bounds) == 0 || wlen == 0 {
+		return nil
+	}
+
+	splits := []int{}
+	for i, idx := range slashes {
+		// The slash indices include the previous slashes, which are not in
+		// the word.
+		f := float64(idx-i) / float64(wlen)
+		gidx := f * float64(nglyphs)
+
+		best := bounds[0]
+		for _, b := range bounds[1:] {
+			if math.Abs(float64(b)-gidx) < math.Abs(float64(best)-gidx) {
+				best = b
+			}
+		}
+		if len(splits) > 0 && splits[len(splits)-1] >= best {
+			continue
+		}
+		splits = append(splits, best)
+	}
+	return splits
+}
+
+// splitGlyphs splits the glyphs into syllables at the given indices, which
+// must be sorted.
+func splitGlyphs(glyphs []Glyph, splits []int) Word {
+	word := Word{}
+	prev := 0
+	for _, idx := range splits {
+		if idx <= prev || idx >= len(glyphs) {
+			continue
+		}
+		word = append(word, glyphs[prev:idx])
+		prev = idx
+	}
+	if prev < len(glyphs) {
+		word = append(word, glyphs[prev:])
+	}
+	return word
+}
+
+// checkSyllableMode returns an error if the mode is not a known syllable
+// mode.
+func checkSyllableMode(mode string) error {
+	if _, ok := syllableModes[mode]; !ok {
+		return fmt.Errorf("unknown syllable mode %q", mode)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSyllabifyIPA(t *testing.T) {
+	cases := []struct {
+		ipa      string
+		expected []string
+	}{
+		{"", []string{}},
+		{"ʃ", []string{"ʃ"}},
+		{"ˈʃi", []string{"ʃi"}},
+		{"ɪntɹæpta", []string{"ɪn", "tɹæp", "ta"}},
+		{"əˈbaʊt", []string{"ə", "baʊt"}},
+		{"ˈɛkstɹə", []string{"ɛk", "stɹə"}},
+		{"ˈɛnəmi", []string{"ɛ", "nə", "mi"}},
+		{"iθiɹiə", []string{"i", "θi", "ɹi", "ə"}},
+		{"ˈeɪti", []string{"eɪ", "ti"}},
+		{"ˈsɪŋɝ", []string{"sɪŋ", "ɝ"}},
+		{"ˈnaɪnˈtin", []string{"naɪn", "tin"}},
+		{"pɝfjuma", []string{"pɝ", "fju", "ma"}},
+		{"kæt.ɹa", []string{"kæt", "ɹa"}},
+	}
+	for _, c := range cases {
+		got := []string{}
+		for _, s := range syllabifyIPA(c.ipa) {
+			got = append(got, s.String())
+		}
+		if diff := cmp.Diff(c.expected, got); diff != "" {
+			t.Errorf("syllabifyIPA(%q) mismatch:\n%s", c.ipa, diff)
+		}
+	}
+}
+
+func TestSyllabifyIPAStress(t *testing.T) {
+	cases := []struct {
+		ipa      string
+		expected []Stress
+	}{
+		{"ʃi", []Stress{unstressed}},
+		{"ˈʃi", []Stress{primaryStress}},
+		{"əˈbaʊt", []Stress{unstressed, primaryStress}},
+		{"təˈmɑˌtoʊ", []Stress{unstressed, primaryStress, secondaryStress}},
+		{"kæt.ɹa", []Stress{unstressed, unstressed}},
+	}
+	for _, c := range cases {
+		got := []Stress{}
+		for _, s := range syllabifyIPA(c.ipa) {
+			got = append(got, s.stress)
+		}
+		if diff := cmp.Diff(c.expected, got); diff != "" {
+			t.Errorf("syllabifyIPA(%q) stress mismatch:\n%s", c.ipa, diff)
+		}
+	}
+}
+
+func TestAnchorSlashes(t *testing.T) {
+	cases := []struct {
+		nglyphs  int
+		slashes  []int
+		wlen     int
+		bounds   []int
+		expected []int
+	}{
+		// No syllables to anchor to.
+		{4, []int{2}, 4, nil, nil},
+
+		// "en/trap/ta" -> ɪn.tɹæp.ta
+		{8, []int{2, 7}, 8, []int{2, 6}, []int{2, 6}},
+
+		// Slash at the beginning and at the end.
+		{8, []int{0}, 8, []int{2, 6}, []int{2}},
+		{8, []int{8}, 8, []int{2, 6}, []int{6}},
+
+		// Two slashes that go to the same syllable.
+		{8, []int{1, 3}, 8, []int{2, 6}, []int{2}},
+
+		// Ties go to the earliest syllable.
+		{8, []int{4}, 8, []int{2, 6}, []int{2}},
+	}
+	for i, c := range cases {
+		got := anchorSlashes(c.nglyphs, c.slashes, c.wlen, c.bounds)
+		if diff := cmp.Diff(c.expected, got); diff != "" {
+			t.Errorf("%d: anchorSlashes mismatch:\n%s", i, diff)
+		}
+	}
+}
+
+func TestSplitGlyphs(t *testing.T) {
+	s := mkS("SH", "fEEt", "R", "All")
+	cases := []struct {
+		splits   []int
+		expected Word
+	}{
+		{nil, Word{s}},
+		{[]int{2}, Word{s[:2], s[2:]}},
+		{[]int{0, 2, 2, 4}, Word{s[:2], s[2:]}},
+		{[]int{1, 2, 3}, Word{s[:1], s[1:2], s[2:3], s[3:]}},
+	}
+	for i, c := range cases {
+		got := splitGlyphs(s, c.splits)
+		if got.String() != c.expected.String() {
+			t.Errorf("%d: splitGlyphs(%v) = %v, expected %v",
+				i, c.splits, got, c.expected)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"image/color"
+	"io"
+	"strings"
+)
+
+// # TikZ
+//
+// The "tikz" command writes the words as a TikZ picture, for LaTeX
+// documents. The shapes are the ones of the SVG (see wordsScene), with its
+// transforms already applied, so the picture is the same: word lines right
+// to left, rotated lines and stacked syllables included.
+//
+// The units are millimetres, and the picture has the size of the SVG, so it
+// takes the same space on the page. By default only the picture is
+// written, to include with \input (TikZ must be loaded); with -standalone
+// it's a complete document, which can be compiled on its own. The
+// background of the style, if any, fills the picture.
+//
+// The output backend is named after the extension, "tex", so -o picks it
+// for .tex files from any command, and the HTTP server has it too.
+//
+// latex/firstones.sty has a \firstones{words} command, which runs this
+// through shell escape and caches the pictures.
+
+var standaloneFlag = flag.Bool("standalone", false,
+	"in tikz, write a complete LaTeX document instead of only the picture")
+
+// tikzCmd implements the "tikz" command: it writes the words as TikZ to the
+// output file given with -o (in the format of its extension), or to w.
+func tikzCmd(w io.Writer, words []string, opts Options) error {
+	doc, err := renderDocument(words, opts, false)
+	if err != nil {
+		return err
+	}
+	return writeDocument(w, doc, "tex")
+}
+
+// tikzBackend writes the words of the document as a TikZ picture, or as a
+// complete document with -standalone.
+type tikzBackend struct{}
+
+func (tikzBackend) contentType() string {
+	return "application/x-tex"
+}
+
+func (tikzBackend) write(w io.Writer, doc *document) error {
+	sc, err := doc.wordsScene()
+	if err != nil {
+		return err
+	}
+	if doc.opts.outline.enabled {
+		wsvg, err := outlineSVG(doc.wsvg, doc.width, doc.height,
+			doc.opts)
+		if err != nil {
+			return err
+		}
+		sc, err = wordsScene(wsvg, doc.width, doc.height)
+		if err != nil {
+			return err
+		}
+	}
+	bg, _ := parseColor(doc.opts.style.resolved().background,
+		color.NRGBA{})
+
+	buf := &bytes.Buffer{}
+	if *standaloneFlag {
+		buf.WriteString("\\documentclass[tikz]{standalone}\n" +
+			"\\begin{document}\n")
+	}
+	writeTikZ(buf, sc, bg, strings.Join(doc.words, " "))
+	if *standaloneFlag {
+		buf.WriteString("\\end{document}\n")
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// Points per line, when writing paths, to keep the lines short.
+const tikzPointsPerLine = 6
+
+// writeTikZ writes the scene as a tikzpicture environment, with the label
+// in a comment before it. If the background color is not transparent, the
+// bounding box is filled with it.
+func writeTikZ(buf *bytes.Buffer, sc *scene, bg color.NRGBA, label string) {
+	// Like in the SVG, Y goes down.
+	fmt.Fprintf(buf, "%% %s\n", label)
+	fmt.Fprintf(buf, "\\begin{tikzpicture}[x=1mm, y=-1mm, miter limit=%d]\n",
+		miterLimit)
+
+	// The colors are defined once, in order of appearance. They're local to
+	// the picture.
+	colors := map[color.NRGBA]string{}
+	name := func(c color.NRGBA) string {
+		c.A = 255
+		n, ok := colors[c]
+		if !ok {
+			n = fmt.Sprintf("firstones%d", len(colors))
+			colors[c] = n
+			fmt.Fprintf(buf, "\\definecolor{%s}{RGB}{%d,%d,%d}\n", n, c.R,
+				c.G, c.B)
+		}
+		return n
+	}
+
+	box := ""
+	if bg.A > 0 {
+		box = "[fill=" + name(bg)
+		if bg.A < 255 {
+			box += ", fill opacity=" + pdfNum(float64(bg.A)/255)
+		}
+		box += "]"
+	}
+	fmt.Fprintf(buf, "\\useasboundingbox%s (0,0) rectangle (%s,%s);\n",
+		box, pdfNum(sc.width), pdfNum(sc.height))
+
+	for _, s := range sc.shapes {
+		fill, stroke := s.hasFill(), s.hasStroke()
+		if !fill && !stroke {
+			continue
+		}
+		style := []string{}
+		if fill {
+			style = append(style, "fill="+name(s.fill))
+			if s.fill.A < 255 {
+				style = append(style,
+					"fill opacity="+pdfNum(float64(s.fill.A)/255))
+			}
+		}
+		if stroke {
+			style = append(style, "draw="+name(s.stroke),
+				"line width="+pdfNum(s.strokeWidth)+"mm")
+			if s.stroke.A < 255 {
+				style = append(style,
+					"draw opacity="+pdfNum(float64(s.stroke.A)/255))
+			}
+			if c, ok := tikzLineCaps[s.linecap]; ok {
+				style = append(style, "line cap="+c)
+			}
+			if j, ok := tikzLineJoins[s.linejoin]; ok {
+				style = append(style, "line join="+j)
+			}
+		}
+		fmt.Fprintf(buf, "\\path[%s]", strings.Join(style, ", "))
+
+		if s.circle {
+			fmt.Fprintf(buf, "\n  %s circle[radius=%s];\n",
+				tikzPoint(s.center), pdfNum(s.r))
+			continue
+		}
+		for _, sp := range s.subpaths {
+			for i, p := range sp.points {
+				switch {
+				case i == 0:
+					buf.WriteString("\n  ")
+				case i%tikzPointsPerLine == 0:
+					buf.WriteString(" --\n  ")
+				default:
+					buf.WriteString(" -- ")
+				}
+				buf.WriteString(tikzPoint(p))
+			}
+			if sp.closed {
+				buf.WriteString(" -- cycle")
+			}
+		}
+		buf.WriteString(";\n")
+	}
+	buf.WriteString("\\end{tikzpicture}\n")
+}
+
+// TikZ line cap and join styles, by their SVG name. The defaults (butt,
+// and miter) are left out, and so are unknown names.
+var (
+	tikzLineCaps  = map[string]string{"round": "round", "square": "rect"}
+	tikzLineJoins = map[string]string{"round": "round", "bevel": "bevel"}
+)
+
+// tikzPoint returns the point as TikZ coordinates. Like PDF, TeX doesn't
+// support exponents, so we use the same format.
+func tikzPoint(p point) string {
+	return "(" + pdfNum(p.x) + "," + pdfNum(p.y) + ")"
+}
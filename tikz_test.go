@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestWriteTikZ(t *testing.T) {
+	orange := color.NRGBA{255, 165, 0, 255}
+	sc := &scene{width: 20, height: 10, shapes: []shape{
+		{circle: true, center: point{5, 5}, r: 1.5, fill: orange},
+		{subpaths: []subpath{
+			{points: []point{{1, 1}, {2, 1}, {3, 1}, {4, 1}, {5, 1}, {6, 1},
+				{7, 1}}},
+			{points: []point{{1, 2}, {2, 3}, {1, 3}}, closed: true},
+		}, stroke: color.NRGBA{0, 0, 255, 128}, strokeWidth: 0.25,
+			linecap: "square", linejoin: "miter"},
+		// Not painted.
+		{circle: true, center: point{1, 1}, r: 1},
+	}}
+
+	buf := &bytes.Buffer{}
+	writeTikZ(buf, sc, color.NRGBA{}, "a b")
+	expected := `% a b
+\begin{tikzpicture}[x=1mm, y=-1mm, miter limit=4]
+\useasboundingbox (0,0) rectangle (20,10);
+\definecolor{firstones0}{RGB}{255,165,0}
+\path[fill=firstones0]
+  (5,5) circle[radius=1.5];
+\definecolor{firstones1}{RGB}{0,0,255}
+\path[draw=firstones1, line width=0.25mm, draw opacity=0.502, line cap=rect]
+  (1,1) -- (2,1) -- (3,1) -- (4,1) -- (5,1) -- (6,1) --
+  (7,1)
+  (1,2) -- (2,3) -- (1,3) -- cycle;
+\end{tikzpicture}
+`
+	if got := buf.String(); got != expected {
+		t.Errorf("got:\n%s\nexpected:\n%s", got, expected)
+	}
+}
+
+func TestWriteTikZBackground(t *testing.T) {
+	sc := &scene{width: 20, height: 10, shapes: []shape{
+		{circle: true, center: point{5, 5}, r: 1.5,
+			fill: color.NRGBA{255, 255, 255, 255}},
+	}}
+
+	buf := &bytes.Buffer{}
+	writeTikZ(buf, sc, color.NRGBA{0, 0, 0, 255}, "a")
+	expected := `% a
+\begin{tikzpicture}[x=1mm, y=-1mm, miter limit=4]
+\definecolor{firstones0}{RGB}{0,0,0}
+\useasboundingbox[fill=firstones0] (0,0) rectangle (20,10);
+\definecolor{firstones1}{RGB}{255,255,255}
+\path[fill=firstones1]
+  (5,5) circle[radius=1.5];
+\end{tikzpicture}
+`
+	if got := buf.String(); got != expected {
+		t.Errorf("got:\n%s\nexpected:\n%s", got, expected)
+	}
+
+	buf.Reset()
+	writeTikZ(buf, sc, color.NRGBA{0, 0, 0, 128}, "a")
+	if !strings.Contains(buf.String(), "\\useasboundingbox["+
+		"fill=firstones0, fill opacity=0.502] (0,0)") {
+		t.Errorf("unexpected translucent background:\n%s", buf.String())
+	}
+}
+
+// The background of the style fills the picture.
+func TestTikZBackendTheme(t *testing.T) {
+	opts := Options{syllables: "manual", angle: defaultAngle,
+		sentences: "none", style: themes["dark"]}
+	doc, err := renderDocument([]string{"hi"}, opts, false)
+	if err != nil {
+		t.Fatalf("renderDocument error: %v", err)
+	}
+	buf := &bytes.Buffer{}
+	if err := outputBackends["tex"].write(buf, doc); err != nil {
+		t.Fatalf("tex error: %v", err)
+	}
+	if !strings.Contains(buf.String(), "\\useasboundingbox[fill=") {
+		t.Errorf("no background with the dark theme:\n%s", buf.String())
+	}
+}
+
+func TestTikZWords(t *testing.T) {
+	opts := Options{syllables: "manual", angle: defaultAngle,
+		sentences: "none"}
+	wsvg, w, h, err := wordsToSVG([]string{"hi"}, opts)
+	if err != nil {
+		t.Fatalf("wordsToSVG error: %v", err)
+	}
+	sc, err := wordsScene(wsvg, w, h)
+	if err != nil {
+		t.Fatalf("wordsScene error: %v", err)
+	}
+	buf := &bytes.Buffer{}
+	writeTikZ(buf, sc, color.NRGBA{}, "hi")
+	tikz := buf.String()
+
+	// The word line has a line and two dots, and "hi" is one syllable with
+	// a connector and 2 glyphs, all in the same color.
+	if n := strings.Count(tikz, `\path[`); n != 6 {
+		t.Errorf("got %d paths, expected 6", n)
+	}
+	if n := strings.Count(tikz, `\definecolor`); n != 1 {
+		t.Errorf("got %d colors, expected 1", n)
+	}
+	if n := strings.Count(tikz, "circle[radius="); n != 2 {
+		t.Errorf("got %d circles, expected 2", n)
+	}
+	if !strings.HasSuffix(tikz, "\\end{tikzpicture}\n") {
+		t.Errorf("unexpected end: %q", tikz[len(tikz)-30:])
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// # Tokenizer
+//
+// The input can be real sentences ("Hello, world! How are you?"), so before
+// converting the words we split them, remove the punctuation, and find where
+// the sentences end.
+//
+// Apostrophes are kept inside words ("don't"), and at the beginning or end
+// if the dictionary has the word like that ("'bout", "'cause", "actors'").
+// Otherwise they are treated like quotes, and removed.
+//
+// The end of a sentence is a separate token (sentenceEnd), which is rendered
+// according to the sentence mode (see sentenceModes).
+
+// Token used to indicate the end of a sentence.
+const sentenceEnd = "."
+
+// Sentence modes, how to render the end of a sentence.
+var sentenceModes = map[string]string{
+	"none": "ignore the end of sentences",
+	"gap":  "leave an extra gap between sentences",
+	"mark": "mark the end of sentences on the word line",
+}
+
+func checkSentenceMode(mode string) error {
+	if _, ok := sentenceModes[mode]; !ok {
+		return fmt.Errorf("unknown sentence mode %q", mode)
+	}
+	return nil
+}
+
+const (
+	// Punctuation that we remove from the beginning of words.
+	// Apostrophes are handled separately.
+	leadingPunct = `"“”„«»‹›([{¿¡—–`
+
+	// Punctuation that we remove from the end of words.
+	trailingPunct = `"“”„«»‹›)]},;:—–` + sentenceTerminators
+
+	// Punctuation that ends a sentence.
+	sentenceTerminators = ".!?…"
+)
+
+// Apostrophes that we treat like "'".
+var apostrophes = strings.NewReplacer("’", "'", "‘", "'", "ʼ", "'")
+
+// tokenize splits the text into words, removing the punctuation, and adding
+// a sentenceEnd token at the end of each sentence.
+func tokenize(text string) []string {
+	tokens := []string{}
+	endSentence := func() {
+		if len(tokens) > 0 && tokens[len(tokens)-1] != sentenceEnd {
+			tokens = append(tokens, sentenceEnd)
+		}
+	}
+
+	for _, field := range strings.Fields(apostrophes.Replace(text)) {
+		word, ends := trimPunct(field)
+		if word != "" {
+			tokens = append(tokens, word)
+		}
+		if ends {
+			endSentence()
+		}
+	}
+	return tokens
+}
+
+// trimPunct removes the punctuation around the word, and returns whether it
+// ended a sentence.
+func trimPunct(word string) (string, bool) {
+	ends := false
+	for {
+		for word != "" {
+			r, size := utf8.DecodeRuneInString(word)
+			if r == '\'' || !strings.ContainsRune(leadingPunct, r) {
+				break
+			}
+			word = word[size:]
+		}
+
+		for word != "" {
+			r, size := utf8.DecodeLastRuneInString(word)
+			if r == '\'' || !strings.ContainsRune(trailingPunct, r) ||
+				r == '.' && isAbbrev(word) {
+				break
+			}
+			if strings.ContainsRune(sentenceTerminators, r) {
+				ends = true
+			}
+			word = word[:len(word)-size]
+		}
+
+		// Apostrophes around the word are kept only if the dictionary has
+		// the word like that. Otherwise, we remove them and go again, in
+		// case they were quotes around more punctuation.
+		if inAnyDict(word) {
+			break
+		}
+		trimmed := strings.TrimSuffix(strings.TrimPrefix(word, "'"), "'")
+		if trimmed == word {
+			break
+		}
+		word = trimmed
+	}
+
+	return word, ends
+}
+
+// inAnyDict returns true if the word is in any of the dictionaries
+// (including the user dictionary).
+func inAnyDict(word string) bool {
+	lower := strings.ToLower(word)
+	for _, dict := range IPADicts {
+		if _, ok := dict[word]; ok {
+			return true
+		}
+		if _, ok := dict[lower]; ok {
+			return true
+		}
+	}
+	return userDict.Load().has(word)
+}
+
+// isAbbrev returns true if the word (with its trailing ".") is an
+// abbreviation we know about (see textExpanders).
+func isAbbrev(word string) bool {
+	// Remove the language prefix, if any.
+	if _, w, ok := splitPrefix(word); ok {
+		word = w
+	}
+	key := strings.ToLower(strings.TrimSuffix(word, "."))
+	for _, ex := range textExpanders {
+		if _, ok := ex.abbrevs[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// limitWords returns the tokens, with at most n words (not counting the
+// sentence ends).
+func limitWords(tokens []string, n int) []string {
+	for i, t := range tokens {
+		if t == sentenceEnd {
+			continue
+		}
+		if n == 0 {
+			return tokens[:i]
+		}
+		n--
+	}
+	return tokens
+}
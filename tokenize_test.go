@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTokenize(t *testing.T) {
+	cases := []struct {
+		text     string
+		expected []string
+	}{
+		{"", []string{}},
+		{"hello world", []string{"hello", "world"}},
+		{"Hello, world!", []string{"Hello", "world", "."}},
+		{"Hi. How are you?", []string{"Hi", ".", "How", "are", "you", "."}},
+		{"Wait... what?!", []string{"Wait", ".", "what", "."}},
+		{"¿Qué? ¡Sí!", []string{"Qué", ".", "Sí", "."}},
+		{`He said "hello" (twice)`,
+			[]string{"He", "said", "hello", "twice"}},
+		{"— dash —", []string{"dash"}},
+
+		// Apostrophes.
+		{"don't", []string{"don't"}},
+		{"don’t", []string{"don't"}},
+		{"'bout time, 'cause", []string{"'bout", "time", "'cause"}},
+		{"the actors' guild", []string{"the", "actors'", "guild"}},
+		{"'quoted' words", []string{"quoted", "words"}},
+		{"'hello!'", []string{"hello", "."}},
+
+		// Abbreviations, numbers and prefixes keep their punctuation.
+		{"Dr. Who", []string{"Dr.", "Who"}},
+		{"pi is 3.14.", []string{"pi", "is", "3.14", "."}},
+		{"at 10:30, es:hola.", []string{"at", "10:30", "es:hola", "."}},
+		{"SH-fEEt/R-All", []string{"SH-fEEt/R-All"}},
+	}
+	for _, c := range cases {
+		got := tokenize(c.text)
+		if diff := cmp.Diff(c.expected, got); diff != "" {
+			t.Errorf("tokenize(%q) mismatch (-expected +got):\n%s",
+				c.text, diff)
+		}
+	}
+}
+
+func TestLimitWords(t *testing.T) {
+	tokens := []string{"a", ".", "b", "c", ".", "d"}
+	got := limitWords(tokens, 3)
+	expected := []string{"a", ".", "b", "c", "."}
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Errorf("limitWords mismatch (-expected +got):\n%s", diff)
+	}
+}
+
+func TestSentenceEnds(t *testing.T) {
+	words := tokenize("Hi. Bye.")
+	for mode, expected := range map[string]int{"none": 2, "gap": 4} {
+		wordsG, err := wordsToGlyphs(words, Options{sentences: mode})
+		if err != nil {
+			t.Fatalf("wordsToGlyphs(%q): %v", mode, err)
+		}
+		if len(wordsG) != expected {
+			t.Errorf("sentences=%s: got %d words, expected %d: %v",
+				mode, len(wordsG), expected, wordsG)
+		}
+	}
+}
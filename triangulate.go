@@ -0,0 +1,453 @@
+package main
+
+import (
+	"cmp"
+	"math"
+	"slices"
+)
+
+// # Triangulation
+//
+// To make solids (see stlCmd) we need to fill polygons with triangles. This
+// is a port of the ear clipping algorithm of the earcut library
+// (https://github.com/mapbox/earcut), without its z-order hashing: our
+// polygons are small enough.
+//
+// The holes are joined to the outer ring through "bridges", and then the
+// ears (triangles with no other points inside) are cut off one by one. For
+// rings that are not quite simple, there are some fallbacks to still get
+// a reasonable result.
+//
+// The triangles only use the points of the rings (no new ones), so they
+// fit exactly with other faces that share those points.
+
+// A node of the circular doubly linked list of points being triangulated.
+type earNode struct {
+	// Index of the point, to tell apart the copies made when splitting.
+	i int
+	p point
+
+	prev, next *earNode
+}
+
+// triangulate returns the triangles that fill the polygon with the given
+// outer ring and holes (in any direction). The triangles have positive
+// area (see ringArea), except for some that have none, which keep the
+// edges matching those of the rings.
+func triangulate(outer []point, holes [][]point) [][3]point {
+	pts := []point{}
+	ring := func(ring []point, clockwise bool) *earNode {
+		start := len(pts)
+		pts = append(pts, ring...)
+		return earLinkedList(pts, start, len(pts), clockwise)
+	}
+
+	node := ring(outer, true)
+	if node == nil || node.next == node.prev {
+		return nil
+	}
+
+	// The holes are joined from left to right.
+	queue := []*earNode{}
+	for _, h := range holes {
+		if l := ring(h, false); l != nil {
+			queue = append(queue, earLeftmost(l))
+		}
+	}
+	slices.SortStableFunc(queue, func(a, b *earNode) int {
+		return cmp.Or(cmp.Compare(a.p.x, b.p.x), cmp.Compare(a.p.y, b.p.y))
+	})
+	idx := [][3]int{}
+	for _, h := range queue {
+		node = earEliminateHole(h, node, &idx)
+	}
+	earcutLinked(node, &idx, 0)
+
+	tris := make([][3]point, 0, len(idx))
+	for _, t := range idx {
+		tris = append(tris, [3]point{pts[t[0]], pts[t[1]], pts[t[2]]})
+	}
+	return tris
+}
+
+// earLinkedList creates the circular list with the points from start to
+// end, in the given direction.
+func earLinkedList(pts []point, start, end int, clockwise bool) *earNode {
+	var last *earNode
+	if clockwise == (earSignedArea(pts[start:end]) > 0) {
+		for i := start; i < end; i++ {
+			last = earInsert(i, pts[i], last)
+		}
+	} else {
+		for i := end - 1; i >= start; i-- {
+			last = earInsert(i, pts[i], last)
+		}
+	}
+	if last != nil && last.p == last.next.p {
+		earRemove(last)
+		last = last.next
+	}
+	return last
+}
+
+// earSignedArea is positive for the rings that earcut calls clockwise.
+func earSignedArea(pts []point) float64 {
+	sum := 0.0
+	for i, j := 0, len(pts)-1; i < len(pts); j, i = i, i+1 {
+		sum += (pts[j].x - pts[i].x) * (pts[i].y + pts[j].y)
+	}
+	return sum
+}
+
+func earInsert(i int, p point, last *earNode) *earNode {
+	n := &earNode{i: i, p: p}
+	if last == nil {
+		n.prev, n.next = n, n
+	} else {
+		n.next, n.prev = last.next, last
+		last.next.prev = n
+		last.next = n
+	}
+	return n
+}
+
+func earRemove(n *earNode) {
+	n.next.prev = n.prev
+	n.prev.next = n.next
+}
+
+// earFilter removes duplicated and collinear points. For the collinear
+// ones, it adds the (flat) triangle to tris, so the edges of the points
+// that are removed are still covered.
+func earFilter(start, end *earNode, tris *[][3]int) *earNode {
+	if start == nil {
+		return start
+	}
+	if end == nil {
+		end = start
+	}
+	p := start
+	for {
+		again := false
+		if p.p == p.next.p || earArea(p.prev, p, p.next) == 0 {
+			if p.p != p.next.p && p.p != p.prev.p {
+				*tris = append(*tris, [3]int{p.prev.i, p.i, p.next.i})
+			}
+			earRemove(p)
+			p = p.prev
+			end = p
+			if p == p.next {
+				break
+			}
+			again = true
+		} else {
+			p = p.next
+		}
+		if !again && p == end {
+			break
+		}
+	}
+	return end
+}
+
+// earcutLinked cuts the ears of the list, adding the triangles to tris.
+// If it gets stuck, it tries again after filtering points (pass 1), fixing
+// small self-intersections (pass 2), and splitting the polygon in two.
+func earcutLinked(ear *earNode, tris *[][3]int, pass int) {
+	if ear == nil {
+		return
+	}
+	stop := ear
+	for ear.prev != ear.next {
+		prev, next := ear.prev, ear.next
+		if earIsEar(ear) {
+			*tris = append(*tris, [3]int{prev.i, ear.i, next.i})
+			earRemove(ear)
+			ear = next.next
+			stop = next.next
+			continue
+		}
+		ear = next
+		if ear == stop {
+			switch pass {
+			case 0:
+				earcutLinked(earFilter(ear, nil, tris), tris, 1)
+			case 1:
+				ear = earCureIntersections(earFilter(ear, nil, tris), tris)
+				earcutLinked(ear, tris, 2)
+			case 2:
+				earSplit(ear, tris)
+			}
+			break
+		}
+	}
+}
+
+// earIsEar returns true if the triangle of the node and its neighbours is
+// convex, and has no other point inside.
+func earIsEar(ear *earNode) bool {
+	a, b, c := ear.prev, ear, ear.next
+	if earArea(a, b, c) >= 0 {
+		return false
+	}
+	for p := c.next; p != a; p = p.next {
+		// Points at a (there are copies, from the bridges) don't count.
+		if p.p != a.p && earInTriangle(a.p, b.p, c.p, p.p) &&
+			earArea(p.prev, p, p.next) >= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// earCureIntersections cuts the triangles where two consecutive segments
+// cross.
+func earCureIntersections(start *earNode, tris *[][3]int) *earNode {
+	p := start
+	for {
+		a, b := p.prev, p.next.next
+		if a.p != b.p && earIntersects(a, p, p.next, b) &&
+			earLocallyInside(a, b) && earLocallyInside(b, a) {
+			*tris = append(*tris, [3]int{a.i, p.i, b.i})
+			earRemove(p)
+			earRemove(p.next)
+			p, start = b, b
+		}
+		p = p.next
+		if p == start {
+			break
+		}
+	}
+	return earFilter(p, nil, tris)
+}
+
+// earSplit looks for a valid diagonal that splits the polygon in two, and
+// triangulates each of them.
+func earSplit(start *earNode, tris *[][3]int) {
+	a := start
+	for {
+		for b := a.next.next; b != a.prev; b = b.next {
+			if a.i != b.i && earValidDiagonal(a, b) {
+				c := earSplitPolygon(a, b)
+				a = earFilter(a, a.next, tris)
+				c = earFilter(c, c.next, tris)
+				earcutLinked(a, tris, 0)
+				earcutLinked(c, tris, 0)
+				return
+			}
+		}
+		a = a.next
+		if a == start {
+			return
+		}
+	}
+}
+
+// earEliminateHole joins the hole to the outer ring, with a bridge.
+func earEliminateHole(hole, outer *earNode, tris *[][3]int) *earNode {
+	bridge := earHoleBridge(hole, outer)
+	if bridge == nil {
+		return outer
+	}
+	reverse := earSplitPolygon(bridge, hole)
+	earFilter(reverse, reverse.next, tris)
+	return earFilter(bridge, bridge.next, tris)
+}
+
+// earHoleBridge finds a point of the outer ring that can be joined to the
+// hole's leftmost point, using David Eberly's algorithm.
+func earHoleBridge(hole, outer *earNode) *earNode {
+	h := hole.p
+	qx := math.Inf(-1)
+	var m *earNode
+
+	// Find a segment intersected by a ray from the hole's leftmost point
+	// to the left.
+	p := outer
+	for {
+		if h.y <= p.p.y && h.y >= p.next.p.y && p.next.p.y != p.p.y {
+			x := p.p.x + (h.y-p.p.y)*(p.next.p.x-p.p.x)/(p.next.p.y-p.p.y)
+			if x <= h.x && x > qx {
+				qx = x
+				m = p
+				if p.p.x >= p.next.p.x {
+					m = p.next
+				}
+				if x == h.x {
+					// The hole touches the outer segment.
+					return m
+				}
+			}
+		}
+		p = p.next
+		if p == outer {
+			break
+		}
+	}
+	if m == nil {
+		return nil
+	}
+
+	// Look for points inside the triangle of the hole point, the
+	// intersection and the endpoint. If there are none, that's the
+	// bridge; otherwise, use the point with the minimum angle with the
+	// ray.
+	stop := m
+	mp := m.p
+	tanMin := math.Inf(1)
+	p = m
+	for {
+		if h.x >= p.p.x && p.p.x >= mp.x && h.x != p.p.x {
+			a, c := point{qx, h.y}, point{h.x, h.y}
+			if h.y < mp.y {
+				a, c = c, a
+			}
+			if earInTriangle(a, mp, c, p.p) {
+				tan := math.Abs(h.y-p.p.y) / (h.x - p.p.x)
+				if earLocallyInside(p, hole) && (tan < tanMin ||
+					(tan == tanMin && (p.p.x > m.p.x || (p.p.x == m.p.x &&
+						earSectorContainsSector(m, p))))) {
+					m = p
+					tanMin = tan
+				}
+			}
+		}
+		p = p.next
+		if p == stop {
+			break
+		}
+	}
+	return m
+}
+
+func earSectorContainsSector(m, p *earNode) bool {
+	return earArea(m.prev, m, p.prev) < 0 && earArea(p.next, m, m.prev) < 0
+}
+
+func earLeftmost(start *earNode) *earNode {
+	left := start
+	for p := start.next; p != start; p = p.next {
+		if p.p.x < left.p.x || (p.p.x == left.p.x && p.p.y < left.p.y) {
+			left = p
+		}
+	}
+	return left
+}
+
+// earInTriangle returns true if p is inside the triangle a, b, c.
+func earInTriangle(a, b, c, p point) bool {
+	return (c.x-p.x)*(a.y-p.y) >= (a.x-p.x)*(c.y-p.y) &&
+		(a.x-p.x)*(b.y-p.y) >= (b.x-p.x)*(a.y-p.y) &&
+		(b.x-p.x)*(c.y-p.y) >= (c.x-p.x)*(b.y-p.y)
+}
+
+// earValidDiagonal returns true if the diagonal between a and b is inside
+// the polygon, and doesn't cross any of its segments.
+func earValidDiagonal(a, b *earNode) bool {
+	if a.next.i == b.i || a.prev.i == b.i || earIntersectsPolygon(a, b) {
+		return false
+	}
+	if earLocallyInside(a, b) && earLocallyInside(b, a) &&
+		earMiddleInside(a, b) &&
+		(earArea(a.prev, a, b.prev) != 0 || earArea(a, b.prev, b) != 0) {
+		return true
+	}
+	return a.p == b.p && earArea(a.prev, a, a.next) > 0 &&
+		earArea(b.prev, b, b.next) > 0
+}
+
+// earArea is the signed area of the triangle, negative if it's convex (in
+// the direction of the outer ring).
+func earArea(p, q, r *earNode) float64 {
+	return (q.p.y-p.p.y)*(r.p.x-q.p.x) - (q.p.x-p.p.x)*(r.p.y-q.p.y)
+}
+
+func earSign(v float64) int {
+	switch {
+	case v > 0:
+		return 1
+	case v < 0:
+		return -1
+	}
+	return 0
+}
+
+// earIntersects returns true if the segments p1-q1 and p2-q2 intersect.
+func earIntersects(p1, q1, p2, q2 *earNode) bool {
+	o1 := earSign(earArea(p1, q1, p2))
+	o2 := earSign(earArea(p1, q1, q2))
+	o3 := earSign(earArea(p2, q2, p1))
+	o4 := earSign(earArea(p2, q2, q1))
+	switch {
+	case o1 != o2 && o3 != o4:
+		return true
+	case o1 == 0 && earOnSegment(p1.p, p2.p, q1.p),
+		o2 == 0 && earOnSegment(p1.p, q2.p, q1.p),
+		o3 == 0 && earOnSegment(p2.p, p1.p, q2.p),
+		o4 == 0 && earOnSegment(p2.p, q1.p, q2.p):
+		return true
+	}
+	return false
+}
+
+// earOnSegment returns true if q is in the box of the segment p-r (for
+// collinear points, that means it's on the segment).
+func earOnSegment(p, q, r point) bool {
+	return q.x <= math.Max(p.x, r.x) && q.x >= math.Min(p.x, r.x) &&
+		q.y <= math.Max(p.y, r.y) && q.y >= math.Min(p.y, r.y)
+}
+
+func earIntersectsPolygon(a, b *earNode) bool {
+	p := a
+	for {
+		if p.i != a.i && p.next.i != a.i && p.i != b.i && p.next.i != b.i &&
+			earIntersects(p, p.next, a, b) {
+			return true
+		}
+		p = p.next
+		if p == a {
+			return false
+		}
+	}
+}
+
+// earLocallyInside returns true if the diagonal a-b is inside the polygon
+// near a.
+func earLocallyInside(a, b *earNode) bool {
+	if earArea(a.prev, a, a.next) < 0 {
+		return earArea(a, b, a.next) >= 0 && earArea(a, a.prev, b) >= 0
+	}
+	return earArea(a, b, a.prev) < 0 || earArea(a, a.next, b) < 0
+}
+
+// earMiddleInside returns true if the middle of the diagonal a-b is inside
+// the polygon.
+func earMiddleInside(a, b *earNode) bool {
+	inside := false
+	m := point{(a.p.x + b.p.x) / 2, (a.p.y + b.p.y) / 2}
+	p := a
+	for {
+		if (p.p.y > m.y) != (p.next.p.y > m.y) && p.next.p.y != p.p.y &&
+			m.x < (p.next.p.x-p.p.x)*(m.y-p.p.y)/(p.next.p.y-p.p.y)+p.p.x {
+			inside = !inside
+		}
+		p = p.next
+		if p == a {
+			return inside
+		}
+	}
+}
+
+// earSplitPolygon links a and b with a diagonal, splitting the polygon in
+// two. It returns the copy of b, in the second polygon.
+func earSplitPolygon(a, b *earNode) *earNode {
+	a2 := &earNode{i: a.i, p: a.p}
+	b2 := &earNode{i: b.i, p: b.p}
+	an, bp := a.next, b.prev
+
+	a.next, b.prev = b, a
+	a2.next, an.prev = an, a2
+	b2.next, a2.prev = a2, b2
+	bp.next, b2.prev = b2, bp
+	return b2
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/text/language"
+)
+
+// # User dictionary
+//
+// Users can provide their own dictionary (with the -user-dict flag), to add
+// words that are not in the built-in dictionaries (e.g. names), or to
+// override the ones that are.
+//
+// The format is similar to the built-in dictionaries, one word per line:
+//
+//	word<TAB>/ipa/
+//	word<TAB>SH-fEEt-R-All
+//	es:word<TAB>/ipa/
+//
+// The pronunciation can be given as IPA (between slashes), or directly as
+// glyphs, like in the input.
+// Words without a language prefix apply to all languages. Words with a
+// prefix only apply to that language (and its variants, e.g. "en:" applies
+// to "en-GB" too).
+// Empty lines and lines starting with "#" are ignored.
+
+type userDictEntry struct {
+	// Only one of them is set.
+	ipa      string
+	phonemes string
+}
+
+type UserDict struct {
+	path string
+
+	// To detect changes in the file.
+	modTime time.Time
+	size    int64
+
+	// Language -> word -> entry. The language is "" for the entries that
+	// apply to all languages.
+	entries map[string]map[string]userDictEntry
+}
+
+// The user dictionary in use, nil if there is none.
+// It is an atomic pointer because the HTTP server reloads it when the file
+// changes.
+var userDict atomic.Pointer[UserDict]
+
+// loadUserDict loads the user dictionary from the given path.
+func loadUserDict(path string) (*UserDict, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	d := &UserDict{
+		path:    path,
+		modTime: fi.ModTime(),
+		size:    fi.Size(),
+		entries: map[string]map[string]userDictEntry{},
+	}
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		word, pron, ok := strings.Cut(line, "\t")
+		word, pron = strings.TrimSpace(word), strings.TrimSpace(pron)
+		if !ok || word == "" || pron == "" {
+			return nil, fmt.Errorf("%s:%d: expected word<TAB>pronunciation",
+				path, n)
+		}
+
+		lang := ""
+		if l, w, ok := strings.Cut(word, ":"); ok {
+			tag, err := language.Parse(l)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: invalid language %q: %v",
+					path, n, l, err)
+			}
+			lang, word = tag.String(), w
+		}
+
+		entry := userDictEntry{}
+		if strings.HasPrefix(pron, "/") {
+			// IPA, we keep the first pronunciation like in the built-in
+			// dictionaries.
+			slspl := strings.Split(pron, "/")
+			entry.ipa = strings.TrimSpace(slspl[1])
+			if entry.ipa == "" {
+				return nil, fmt.Errorf("%s:%d: empty IPA", path, n)
+			}
+		} else {
+			// Glyphs, check they are valid so we find out early.
+			if _, err := phonemesToGlyphs(pron); err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", path, n, err)
+			}
+			entry.phonemes = pron
+		}
+
+		if d.entries[lang] == nil {
+			d.entries[lang] = map[string]userDictEntry{}
+		}
+		// Like in the built-in dictionaries, the words are lowercase.
+		d.entries[lang][strings.ToLower(word)] = entry
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// Len returns the number of words in the dictionary.
+func (d *UserDict) Len() int {
+	n := 0
+	for _, words := range d.entries {
+		n += len(words)
+	}
+	return n
+}
+
+// lookup the word in the dictionary, for the given language.
+// The more specific language entries take precedence over the general ones.
+// It is safe to call on a nil dictionary.
+func (d *UserDict) lookup(word, lang string) (userDictEntry, bool) {
+	if e, ok := d.lookupLang(word, lang); ok {
+		return e, true
+	}
+	return d.lookupIn(word, "")
+}
+
+// lookupLang is like lookup, but only considers the entries specific to the
+// language (or its base language), not the general ones.
+func (d *UserDict) lookupLang(word, lang string) (userDictEntry, bool) {
+	tag, err := language.Parse(lang)
+	if err != nil {
+		return userDictEntry{}, false
+	}
+	if e, ok := d.lookupIn(word, tag.String()); ok {
+		return e, true
+	}
+	if base, conf := tag.Base(); conf != language.No {
+		return d.lookupIn(word, base.String())
+	}
+	return userDictEntry{}, false
+}
+
+// lookupIn looks up the word in the entries for exactly the given language,
+// ignoring case.
+func (d *UserDict) lookupIn(word, lang string) (userDictEntry, bool) {
+	if d == nil {
+		return userDictEntry{}, false
+	}
+	e, ok := d.entries[lang][strings.ToLower(word)]
+	return e, ok
+}
+
+// has returns true if the word is in the dictionary, for any language.
+// It is safe to call on a nil dictionary.
+func (d *UserDict) has(word string) bool {
+	if d == nil {
+		return false
+	}
+	for lang := range d.entries {
+		if _, ok := d.lookupIn(word, lang); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// changed returns true if the file changed since it was loaded.
+func (d *UserDict) changed() bool {
+	fi, err := os.Stat(d.path)
+	if err != nil {
+		// If the file is gone or we can't access it, we keep the old one.
+		return false
+	}
+	return !fi.ModTime().Equal(d.modTime) || fi.Size() != d.size
+}
+
+// userDictReloader periodically checks if the user dictionary changed, and
+// reloads it if so. If the new one has errors, the old one is kept.
+func userDictReloader(interval time.Duration) {
+	for range time.Tick(interval) {
+		d := userDict.Load()
+		if d == nil || !d.changed() {
+			continue
+		}
+
+		nd, err := loadUserDict(d.path)
+		if err != nil {
+			log.Printf("Error reloading user dictionary: %v", err)
+
+			// Update the file information, so we don't keep trying (and
+			// logging) until it changes again.
+			if fi, err := os.Stat(d.path); err == nil {
+				cd := *d
+				cd.modTime, cd.size = fi.ModTime(), fi.Size()
+				userDict.Store(&cd)
+			}
+			continue
+		}
+
+		userDict.Store(nd)
+		log.Printf("Reloaded user dictionary %q (%d words)", nd.path, nd.Len())
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeUserDict(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "user.txt")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestUserDict(t *testing.T) {
+	path := writeUserDict(t, `# Comment.
+
+Adora	SH-fEEt-R-All
+swiftwind	/ˈswɪftwɪnd/, /ˈswɪftwaɪnd/
+es:hola	/ˈola/
+en-GB:tomato	/təˈmɑtəʊ/
+`)
+	d, err := loadUserDict(path)
+	if err != nil {
+		t.Fatalf("loadUserDict: %v", err)
+	}
+	if d.Len() != 4 {
+		t.Errorf("expected 4 words, got %d", d.Len())
+	}
+
+	cases := []struct {
+		word, lang string
+		expected   userDictEntry
+		ok         bool
+	}{
+		{"Adora", "en", userDictEntry{phonemes: "SH-fEEt-R-All"}, true},
+		{"Adora", "es", userDictEntry{phonemes: "SH-fEEt-R-All"}, true},
+		{"adora", "en", userDictEntry{phonemes: "SH-fEEt-R-All"}, true},
+		{"ADORA", "en", userDictEntry{phonemes: "SH-fEEt-R-All"}, true},
+		{"swiftwind", "en", userDictEntry{ipa: "ˈswɪftwɪnd"}, true},
+		{"hola", "es", userDictEntry{ipa: "ˈola"}, true},
+		{"hola", "es-MX", userDictEntry{ipa: "ˈola"}, true},
+		{"HOLA", "es", userDictEntry{ipa: "ˈola"}, true},
+		{"hola", "en", userDictEntry{}, false},
+		{"tomato", "en-GB", userDictEntry{ipa: "təˈmɑtəʊ"}, true},
+		{"tomato", "en", userDictEntry{}, false},
+		{"unknown", "en", userDictEntry{}, false},
+	}
+	for _, c := range cases {
+		got, ok := d.lookup(c.word, c.lang)
+		if got != c.expected || ok != c.ok {
+			t.Errorf("lookup(%q, %q) = %v, %v; expected %v, %v",
+				c.word, c.lang, got, ok, c.expected, c.ok)
+		}
+	}
+
+	// Lookups on a nil dictionary are fine.
+	var nd *UserDict
+	if _, ok := nd.lookup("hola", "es"); ok {
+		t.Errorf("lookup on nil dictionary found something")
+	}
+}
+
+func TestUserDictErrors(t *testing.T) {
+	cases := map[string]string{
+		"no tab":       "word /ipa/\n",
+		"empty ipa":    "word\t//\n",
+		"bad glyphs":   "word\tSH-xx\n",
+		"bad language": "@@:word\t/ipa/\n",
+	}
+	for name, content := range cases {
+		path := writeUserDict(t, content)
+		_, err := loadUserDict(path)
+		if err == nil {
+			t.Errorf("%s: expected error, got nil", name)
+		} else if !strings.Contains(err.Error(), path+":1:") {
+			t.Errorf("%s: error does not include location: %v", name, err)
+		}
+	}
+
+	if _, err := loadUserDict("/does/not/exist"); err == nil {
+		t.Errorf("expected error loading missing file")
+	}
+}
+
+func TestUserDictChanged(t *testing.T) {
+	path := writeUserDict(t, "a\tSH\n")
+	d, err := loadUserDict(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.changed() {
+		t.Errorf("dictionary changed right after loading")
+	}
+
+	os.WriteFile(path, []byte("a\tSH\nb\tR\n"), 0o644)
+	if !d.changed() {
+		t.Errorf("dictionary did not change after writing")
+	}
+
+	// Same size, but different modification time.
+	d, _ = loadUserDict(path)
+	os.Chtimes(path, time.Time{}, d.modTime.Add(time.Second))
+	if !d.changed() {
+		t.Errorf("dictionary did not change after touching")
+	}
+
+	// Missing files are not considered changed, so we keep the old one.
+	os.Remove(path)
+	if d.changed() {
+		t.Errorf("missing dictionary considered changed")
+	}
+}
+
+func TestLangWordToGlyphsUserDict(t *testing.T) {
+	defer userDict.Store(userDict.Load())
+
+	path := writeUserDict(t, "catra\tSH-All\nes:hola\t/ˈoːla/\n")
+	d, err := loadUserDict(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	userDict.Store(d)
+
+	cases := []struct{ word, lang, expected string }{
+		{"catra", "en", "SH-All"},
+		{"hola", "es", "All-L-sAd"},
+		{"hola", "en", "H-gO-L-fUn"},
+	}
+	for _, c := range cases {
+		w, err := langWordToGlyphs(c.word, c.lang, Options{})
+		if err != nil {
+			t.Errorf("langWordToGlyphs(%q, %q): %v", c.word, c.lang, err)
+			continue
+		}
+		if w.String() != c.expected {
+			t.Errorf("langWordToGlyphs(%q, %q) = %v, expected %v",
+				c.word, c.lang, w, c.expected)
+		}
+	}
+}